@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 
+	"bcdl-app/backend/events"
 	"bcdl-app/backend/models"
 	"bcdl-app/backend/playwright"
 	"bcdl-app/backend/services"
@@ -15,36 +18,83 @@ import (
 // App struct
 type App struct {
 	ctx        context.Context
+	stop       context.CancelFunc // Cancels ctx, stopping downloads and watches
 	pwService  *playwright.Service
-	scanner    *services.ScannerService
+	scanner    services.Scanner
+	fans       *services.FanScanner
 	downloader *services.DownloaderService
+	queue      *services.DownloadQueue
+	history    *services.DownloadHistory
+	watchList  *services.WatchList
+	session    *services.Session
+	bus        *events.Bus
 	scanCancel context.CancelFunc
+
+	mu     sync.Mutex
+	direct map[string]context.CancelFunc // Running DownloadAlbum calls by URL
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	pwService := playwright.NewService()
+	pwService.SetDiagnostics(services.DiagnosticsOptionsFromEnv())
+	history := services.NewDownloadHistoryFromEnv()
+	downloader := services.NewDownloaderService(pwService)
+	downloader.SetHistory(history)
+	scanner := services.WithHistory(services.NewScannerFromEnv(pwService), history)
+	queue := services.NewDownloadQueueFromEnv(downloader)
+	fans := services.NewFanScannerFromEnv()
+	downloader.SetPurchases(fans)
 	return &App{
 		pwService:  pwService,
-		scanner:    services.NewScannerService(pwService),
-		downloader: services.NewDownloaderService(pwService),
+		scanner:    scanner,
+		fans:       fans,
+		downloader: downloader,
+		queue:      queue,
+		history:    history,
+		watchList:  services.NewWatchListFromEnv(scanner, queue),
+		session:    services.NewSessionFromEnv(pwService, fans),
+		bus:        events.NewBus(),
+		direct:     make(map[string]context.CancelFunc),
 	}
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
+	ctx, a.stop = context.WithCancel(ctx)
 	a.ctx = ctx
 
+	a.bus.Subscribe(wailsBridge{ctx: ctx})
+	a.bus.Subscribe(events.Logger{})
+
 	// Initialize Playwright
 	if err := a.pwService.Init(); err != nil {
-		log.Printf("Failed to init Playwright: %v", err)
-		runtime.EventsEmit(a.ctx, "log:error", fmt.Sprintf("Failed to init Playwright: %v", err))
+		a.bus.Publish(events.Error{Message: fmt.Sprintf("Failed to init Playwright: %v", err)})
+	}
+
+	// Log in with the saved Bandcamp session, if any
+	if err := a.session.Load(); err != nil {
+		a.bus.Publish(events.Error{Message: fmt.Sprintf("Failed to load Bandcamp session: %v", err)})
 	}
+
+	// Restore and start the download queue
+	events.ForwardQueue(a.bus, a.queue)
+	if err := a.queue.Load(); err != nil {
+		a.bus.Publish(events.Error{Message: fmt.Sprintf("Failed to restore download queue: %v", err)})
+	}
+	a.queue.Start(ctx)
+
+	// Re-scan watched artists on their schedules
+	events.ForwardWatchList(a.bus, a.watchList)
+	go a.watchList.Run(ctx)
 }
 
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
+	// Stop running downloads so they clean up and are queued for next time
+	a.stop()
+	a.queue.Wait()
 	a.pwService.Close()
 }
 
@@ -62,31 +112,55 @@ func (a *App) ScanArtist(url string) ([]models.Album, error) {
 			a.scanCancel = nil
 		}()
 
-		runtime.EventsEmit(a.ctx, "scan:start", url)
-
-		albums, err := a.scanner.ScanArtist(scanCtx, url, func(album models.Album) {
-			log.Printf("Emitting scan:album_found for %s", album.Title)
-			runtime.EventsEmit(a.ctx, "scan:album_found", album)
-		})
-
-		if err != nil {
-			if err == context.Canceled {
-				log.Printf("Scan cancelled")
-				runtime.EventsEmit(a.ctx, "scan:stopped", len(albums))
-				return
-			}
-			log.Printf("Scan error: %v", err)
-			runtime.EventsEmit(a.ctx, "scan:error", err.Error())
-			return
-		}
-
-		log.Printf("Scan complete: found %d albums", len(albums))
-		runtime.EventsEmit(a.ctx, "scan:complete", albums)
+		events.RunScan(scanCtx, a.bus, a.scanner, url)
 	}()
 
 	return nil, nil
 }
 
+// ScanLabel scans a label's releases and groups them by the artists on its
+// roster. With includeArtists each artist's own page is scanned too. Results
+// arrive as scan:* events, ending with scan:label_complete
+func (a *App) ScanLabel(url string, includeArtists bool) error {
+	log.Printf("ScanLabel called with URL: %q", url)
+
+	go func() {
+		scanCtx, cancel := context.WithCancel(context.Background())
+		a.scanCancel = cancel
+		defer func() {
+			a.scanCancel = nil
+		}()
+
+		events.RunLabelScan(scanCtx, a.bus, a.scanner, url, includeArtists)
+	}()
+
+	return nil
+}
+
+// ScanFan lists the albums in a fan's collection, and their wishlist when
+// includeWishlist is set. fan is a username or fan page URL. Results arrive
+// as scan:* events
+func (a *App) ScanFan(fan string, includeWishlist bool) error {
+	log.Printf("ScanFan called with fan: %q", fan)
+
+	scanner := services.WithHistory(a.fans.Scanner(services.FanScanOptions{
+		Collection: true,
+		Wishlist:   includeWishlist,
+	}), a.history)
+
+	go func() {
+		scanCtx, cancel := context.WithCancel(context.Background())
+		a.scanCancel = cancel
+		defer func() {
+			a.scanCancel = nil
+		}()
+
+		events.RunScan(scanCtx, a.bus, scanner, fan)
+	}()
+
+	return nil
+}
+
 // StopScan cancels the currently running scan
 func (a *App) StopScan() error {
 	if a.scanCancel != nil {
@@ -98,30 +172,203 @@ func (a *App) StopScan() error {
 	return fmt.Errorf("no scan is currently running")
 }
 
-// DownloadAlbum downloads a single album
+// DownloadAlbum downloads a single album outside the queue. It can be
+// stopped with CancelDirectDownload
 func (a *App) DownloadAlbum(url string, downloadDir string, format string) error {
-	runtime.EventsEmit(a.ctx, "download:start", url)
+	a.mu.Lock()
+	if _, ok := a.direct[url]; ok {
+		a.mu.Unlock()
+		return fmt.Errorf("%s is already being downloaded", url)
+	}
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.direct[url] = cancel
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.direct, url)
+		a.mu.Unlock()
+		cancel()
+	}()
+
+	a.bus.Publish(events.DownloadStarted{URL: url})
 
-	progressCallback := func(msg string) {
-		runtime.EventsEmit(a.ctx, "download:progress", map[string]string{
-			"url":     url,
-			"message": msg,
-		})
+	progressCallback := func(p services.Progress) {
+		a.bus.Publish(events.DownloadProgress{URL: url, Progress: p})
 	}
 
-	err := a.downloader.DownloadAlbum(url, downloadDir, format, progressCallback)
+	err := a.downloader.DownloadAlbum(ctx, url, downloadDir, format, progressCallback)
+	if errors.Is(err, services.ErrAlreadyDownloaded) {
+		a.bus.Publish(events.DownloadSkipped{URL: url, Message: err.Error()})
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		a.bus.Publish(events.DownloadCancelled{URL: url})
+		return err
+	}
 	if err != nil {
-		runtime.EventsEmit(a.ctx, "download:error", map[string]string{
-			"url":   url,
-			"error": err.Error(),
-		})
+		a.bus.Publish(events.DownloadFailed{URL: url, Error: err.Error(), Kind: services.ErrorKind(err), Diagnostics: services.DiagnosticsDir(err)})
 		return err
 	}
 
-	runtime.EventsEmit(a.ctx, "download:complete", url)
+	a.bus.Publish(events.DownloadCompleted{URL: url})
+	return nil
+}
+
+// CancelDirectDownload stops a download started with DownloadAlbum
+func (a *App) CancelDirectDownload(url string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cancel, ok := a.direct[url]
+	if !ok {
+		return fmt.Errorf("no download of %s is running", url)
+	}
+	cancel()
+	return nil
+}
+
+// EnqueueDownload adds an album to the download queue
+func (a *App) EnqueueDownload(url string, downloadDir string, format string) (services.DownloadJob, error) {
+	return a.queue.Enqueue(url, downloadDir, format)
+}
+
+// EnqueueDownloadWithOptions adds an album to the download queue with its own
+// post-processing options
+func (a *App) EnqueueDownloadWithOptions(url string, downloadDir string, format string, opts services.PostProcessOptions) (services.DownloadJob, error) {
+	return a.queue.EnqueueWithOptions(url, downloadDir, format, services.DownloadOptions{PostProcess: &opts})
+}
+
+// ListDownloads returns all queued, running and finished downloads
+func (a *App) ListDownloads() []services.DownloadJob {
+	return a.queue.Jobs()
+}
+
+// PauseDownload pauses a queued or running download
+func (a *App) PauseDownload(id string) error {
+	return a.queue.Pause(id)
+}
+
+// ResumeDownload puts a paused download back in the queue
+func (a *App) ResumeDownload(id string) error {
+	return a.queue.Resume(id)
+}
+
+// CancelDownload cancels a download
+func (a *App) CancelDownload(id string) error {
+	return a.queue.Cancel(id)
+}
+
+// RemoveDownload drops a finished download from the queue
+func (a *App) RemoveDownload(id string) error {
+	return a.queue.Remove(id)
+}
+
+// ClearFinishedDownloads drops every finished download from the queue
+func (a *App) ClearFinishedDownloads() int {
+	return a.queue.ClearFinished()
+}
+
+// ReorderDownload moves a download to a new position in the queue
+func (a *App) ReorderDownload(id string, index int) error {
+	return a.queue.Reorder(id, index)
+}
+
+// ListHistory returns every completed download
+func (a *App) ListHistory() []services.HistoryEntry {
+	return a.history.Entries()
+}
+
+// ForgetDownload removes an album from the history so it can be downloaded again
+func (a *App) ForgetDownload(url string) error {
+	return a.history.Forget(url)
+}
+
+// WatchArtist adds an artist to the watch list. New free and NYP releases are
+// queued with the given format and destination when autoDownload is set
+func (a *App) WatchArtist(url string, downloadDir string, format string, schedule string, autoDownload bool) (services.WatchedArtist, error) {
+	return a.watchList.Add(services.WatchedArtist{
+		URL:          url,
+		Dir:          downloadDir,
+		Format:       format,
+		Schedule:     schedule,
+		AutoDownload: autoDownload,
+	})
+}
+
+// UnwatchArtist removes an artist from the watch list
+func (a *App) UnwatchArtist(id string) error {
+	return a.watchList.Remove(id)
+}
+
+// ListWatchedArtists returns the watch list
+func (a *App) ListWatchedArtists() []services.WatchedArtist {
+	return a.watchList.Artists()
+}
+
+// CheckWatchedArtist re-scans a watched artist right away
+func (a *App) CheckWatchedArtist(id string) (services.WatchReport, error) {
+	return a.watchList.Check(a.ctx, id)
+}
+
+// WatchReports returns the latest report of each watched artist
+func (a *App) WatchReports() []services.WatchReport {
+	return a.watchList.Reports()
+}
+
+// SetDownloadParallelism sets how many downloads run at the same time
+func (a *App) SetDownloadParallelism(n int) {
+	a.queue.SetParallelism(n)
+}
+
+// SetPostProcessing sets the default post-processing options: whether
+// downloads are extracted into a folder layout, the naming templates, and
+// whether the ZIP is deleted afterwards
+func (a *App) SetPostProcessing(opts services.PostProcessOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	a.downloader.SetPostProcess(opts)
 	return nil
 }
 
+// GetPostProcessing returns the default post-processing options
+func (a *App) GetPostProcessing() services.PostProcessOptions {
+	return a.downloader.PostProcess()
+}
+
+// ImportSession logs in with a cookies.txt or Playwright storage-state file
+// exported from a browser, and saves it for the next start
+func (a *App) ImportSession(path string) (services.SessionStatus, error) {
+	if err := a.session.Import(path); err != nil {
+		return services.SessionStatus{}, err
+	}
+	return a.session.Check(a.ctx)
+}
+
+// Login opens a browser window to log in to Bandcamp, saving the session
+// once the user has. Returns when they have, or after the login times out
+func (a *App) Login() (services.SessionStatus, error) {
+	if err := a.session.Login(a.ctx); err != nil {
+		return services.SessionStatus{}, err
+	}
+	return a.session.Check(a.ctx)
+}
+
+// CheckSession reports whether the Bandcamp session is still logged in
+func (a *App) CheckSession() (services.SessionStatus, error) {
+	return a.session.Check(a.ctx)
+}
+
+// SelectSessionFile opens a dialog to select a cookies.txt or storage-state
+// file for ImportSession
+func (a *App) SelectSessionFile() (string, error) {
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select Cookies or Session File",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Cookies (*.txt, *.json)", Pattern: "*.txt;*.json"},
+		},
+	})
+}
+
 // SelectFolder opens a dialog to select a folder
 func (a *App) SelectFolder() (string, error) {
 	selection, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/services"
+)
+
+// ForwardQueue publishes the queue's job changes and progress on the bus,
+// along with the download:* events the frontend uses
+func ForwardQueue(bus *Bus, queue *services.DownloadQueue) {
+	queue.OnUpdate(func(job services.DownloadJob) {
+		bus.Publish(QueueUpdated{Job: job})
+
+		switch job.Status {
+		case services.JobRunning:
+			bus.Publish(DownloadStarted{JobID: job.ID, URL: job.URL})
+		case services.JobDone:
+			bus.Publish(DownloadCompleted{JobID: job.ID, URL: job.URL})
+		case services.JobFailed:
+			bus.Publish(DownloadFailed{JobID: job.ID, URL: job.URL, Error: job.Error, Kind: job.ErrorKind, Diagnostics: job.Diagnostics})
+		case services.JobSkipped:
+			bus.Publish(DownloadSkipped{JobID: job.ID, URL: job.URL, Message: job.Message})
+		case services.JobCancelled:
+			bus.Publish(DownloadCancelled{JobID: job.ID, URL: job.URL})
+		}
+	})
+	queue.OnProgress(func(job services.DownloadJob, p services.Progress) {
+		bus.Publish(DownloadProgress{JobID: job.ID, URL: job.URL, Progress: p})
+	})
+}
+
+// ForwardWatchList publishes each watch list report, and the new releases it
+// found, on the bus
+func ForwardWatchList(bus *Bus, watchList *services.WatchList) {
+	watchList.OnReport(func(report services.WatchReport) {
+		bus.Publish(WatchScanned{Report: report})
+		if len(report.NewReleases) > 0 {
+			bus.Publish(NewReleases{ArtistURL: report.ArtistURL, Albums: report.NewReleases, Queued: report.Queued})
+		}
+	})
+}
+
+// RunScan scans an artist and publishes scan:* events as it goes. A cancelled
+// scan publishes ScanStopped and returns the albums found so far
+func RunScan(ctx context.Context, bus *Bus, scanner services.Scanner, url string) ([]models.Album, error) {
+	bus.Publish(ScanStarted{URL: url})
+
+	albums, err := scanner.ScanArtist(ctx, url, func(album models.Album) {
+		bus.Publish(AlbumFound{Album: album})
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			bus.Publish(ScanStopped{Count: len(albums)})
+		} else {
+			bus.Publish(ScanFailed{URL: url, Error: err.Error()})
+		}
+		return albums, err
+	}
+
+	bus.Publish(ScanCompleted{Albums: albums})
+	return albums, nil
+}
+
+// RunLabelScan scans a label like RunScan, then publishes the releases grouped
+// by artist
+func RunLabelScan(ctx context.Context, bus *Bus, scanner services.Scanner, url string, includeArtists bool) (models.Label, error) {
+	bus.Publish(ScanStarted{URL: url})
+
+	label, err := services.ScanLabel(ctx, scanner, url, includeArtists, func(album models.Album) {
+		bus.Publish(AlbumFound{Album: album})
+	})
+	var albums []models.Album
+	for _, artist := range label.Artists {
+		albums = append(albums, artist.Albums...)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			bus.Publish(ScanStopped{Count: len(albums)})
+		} else {
+			bus.Publish(ScanFailed{URL: url, Error: err.Error()})
+		}
+		return label, err
+	}
+
+	bus.Publish(ScanCompleted{Albums: albums})
+	bus.Publish(LabelScanCompleted{Label: label})
+	return label, nil
+}
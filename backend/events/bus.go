@@ -0,0 +1,75 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Subscriber receives every event published on a bus
+type Subscriber interface {
+	Handle(e Event)
+}
+
+// SubscriberFunc adapts a function to the Subscriber interface
+type SubscriberFunc func(e Event)
+
+func (f SubscriberFunc) Handle(e Event) { f(e) }
+
+// Bus delivers events to its subscribers in publish order, and to the
+// subscribers in the order they subscribed. Subscribers are called
+// synchronously, so slow ones should hand events off to a goroutine
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []subscription // Replaced rather than modified, so Publish can range over it unlocked
+	nextID int
+}
+
+type subscription struct {
+	id int
+	s  Subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a subscriber and returns a function that removes it again
+func (b *Bus) Subscribe(s Subscriber) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs = append(b.subs[:len(b.subs):len(b.subs)], subscription{id: id, s: s})
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subs {
+			if sub.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers e to every subscriber. A panicking subscriber is logged
+// and does not prevent delivery to the others
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		deliver(sub.s, e)
+	}
+}
+
+func deliver(s Subscriber, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Events: Subscriber panicked on %s: %v", e.Name(), r)
+		}
+	}()
+	s.Handle(e)
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/services"
+)
+
+// recorder collects the names of the events it handles
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recorder) Handle(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, e.Name())
+}
+
+func (r *recorder) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+// waitFor polls until the recorder has seen name
+func (r *recorder) waitFor(t *testing.T, name string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, got := range r.got() {
+			if got == name {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("never saw %s, got %v", name, r.got())
+}
+
+func TestBusSubscribe(t *testing.T) {
+	bus := NewBus()
+	var order []string
+	subscriber := func(name string) Subscriber {
+		return SubscriberFunc(func(e Event) { order = append(order, name+":"+e.Name()) })
+	}
+	bus.Subscribe(subscriber("a"))
+	unsubscribeB := bus.Subscribe(subscriber("b"))
+	bus.Subscribe(subscriber("c"))
+
+	bus.Publish(ScanStarted{})
+	unsubscribeB()
+	unsubscribeB() // Harmless the second time
+	bus.Publish(ScanStopped{})
+
+	want := []string{"a:scan:start", "b:scan:start", "c:scan:start", "a:scan:stopped", "c:scan:stopped"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("delivered %v, want %v", order, want)
+	}
+}
+
+func TestBusIsolatesPanics(t *testing.T) {
+	bus := NewBus()
+	bus.Subscribe(SubscriberFunc(func(e Event) { panic("broken subscriber") }))
+	r := &recorder{}
+	bus.Subscribe(r)
+
+	bus.Publish(ScanStarted{})
+	bus.Publish(ScanStopped{})
+	if got := r.got(); !reflect.DeepEqual(got, []string{"scan:start", "scan:stopped"}) {
+		t.Errorf("events after a panicking subscriber = %v", got)
+	}
+}
+
+func TestForwardQueue(t *testing.T) {
+	download := func(ctx context.Context, url string, downloadDir string, format string, opts services.DownloadOptions, onProgress services.ProgressCallback) error {
+		onProgress(services.Progress{Message: "working"})
+		switch url {
+		case "fail":
+			return fmt.Errorf("album: %w", services.ErrPaidOnly)
+		case "skip":
+			return fmt.Errorf("%w to /music", services.ErrAlreadyDownloaded)
+		case "wait":
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	for _, tc := range []struct {
+		url  string
+		want []string
+	}{
+		{"done", []string{"download:start", "download:progress", "download:complete"}},
+		{"fail", []string{"download:start", "download:progress", "download:error"}},
+		{"skip", []string{"download:start", "download:progress", "download:skipped"}},
+		{"wait", []string{"download:start", "download:progress", "download:cancelled"}},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			bus := NewBus()
+			r := &recorder{}
+			bus.Subscribe(r)
+			queue := services.NewDownloadQueue(services.DownloadFunc(download), "")
+			ForwardQueue(bus, queue)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer func() {
+				cancel()
+				queue.Wait()
+			}()
+			queue.Start(ctx)
+
+			job, err := queue.Enqueue(tc.url, "", "flac")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.url == "wait" {
+				r.waitFor(t, "download:progress")
+				if err := queue.Cancel(job.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			last := tc.want[len(tc.want)-1]
+			r.waitFor(t, last)
+
+			var got []string
+			for _, name := range r.got() {
+				if name != "queue:update" {
+					got = append(got, name)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("events = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestForwardWatchList(t *testing.T) {
+	scans := [][]models.Album{
+		{{URL: "https://artist.bandcamp.com/album/old"}},
+		{{URL: "https://artist.bandcamp.com/album/old"}},
+		{{URL: "https://artist.bandcamp.com/album/old"}, {URL: "https://artist.bandcamp.com/album/new"}},
+	}
+	calls := 0
+	scanner := services.ScanFunc(func(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+		albums := scans[calls]
+		calls++
+		return albums, nil
+	})
+	watchList := services.NewWatchList(scanner, nil, "")
+	bus := NewBus()
+	var reports []services.WatchReport
+	var releases []NewReleases
+	bus.Subscribe(SubscriberFunc(func(e Event) {
+		switch e := e.(type) {
+		case WatchScanned:
+			reports = append(reports, e.Report)
+		case NewReleases:
+			releases = append(releases, e)
+		}
+	}))
+	ForwardWatchList(bus, watchList)
+
+	artist, err := watchList.Add(services.WatchedArtist{URL: "https://artist.bandcamp.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range scans {
+		if _, err := watchList.Check(context.Background(), artist.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(reports) != len(scans) {
+		t.Errorf("published %d reports, want one per scan", len(reports))
+	}
+	if len(releases) != 1 || len(releases[0].Albums) != 1 || releases[0].Albums[0].URL != "https://artist.bandcamp.com/album/new" {
+		t.Errorf("new releases = %+v, want only the last scan's new album", releases)
+	}
+	if len(releases) == 1 && releases[0].ArtistURL != artist.URL {
+		t.Errorf("new releases for %q, want %q", releases[0].ArtistURL, artist.URL)
+	}
+}
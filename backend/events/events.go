@@ -0,0 +1,186 @@
+// Package events defines the typed events emitted while scanning and
+// downloading, and a bus that delivers them to pluggable subscribers such as
+// the Wails frontend, the log, SSE clients or webhooks.
+package events
+
+import (
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/services"
+)
+
+// Event is implemented by every event published on the bus
+type Event interface {
+	// Name is the wire name the frontend listens for, e.g. "scan:album_found"
+	Name() string
+	// Payload is the value sent along with the event. It matches what the
+	// frontend has always received for that name
+	Payload() interface{}
+}
+
+type ScanStarted struct {
+	URL string
+}
+
+func (e ScanStarted) Name() string         { return "scan:start" }
+func (e ScanStarted) Payload() interface{} { return e.URL }
+
+type AlbumFound struct {
+	Album models.Album
+}
+
+func (e AlbumFound) Name() string         { return "scan:album_found" }
+func (e AlbumFound) Payload() interface{} { return e.Album }
+
+type ScanCompleted struct {
+	Albums []models.Album
+}
+
+func (e ScanCompleted) Name() string         { return "scan:complete" }
+func (e ScanCompleted) Payload() interface{} { return e.Albums }
+
+// LabelScanCompleted carries a label's releases grouped by artist. It follows
+// the ScanCompleted event listing the same releases
+type LabelScanCompleted struct {
+	Label models.Label
+}
+
+func (e LabelScanCompleted) Name() string         { return "scan:label_complete" }
+func (e LabelScanCompleted) Payload() interface{} { return e.Label }
+
+type ScanStopped struct {
+	Count int // Albums found before the scan was stopped
+}
+
+func (e ScanStopped) Name() string         { return "scan:stopped" }
+func (e ScanStopped) Payload() interface{} { return e.Count }
+
+type ScanFailed struct {
+	URL   string
+	Error string
+}
+
+func (e ScanFailed) Name() string         { return "scan:error" }
+func (e ScanFailed) Payload() interface{} { return e.Error }
+
+type DownloadStarted struct {
+	JobID string
+	URL   string
+}
+
+func (e DownloadStarted) Name() string         { return "download:start" }
+func (e DownloadStarted) Payload() interface{} { return e.URL }
+
+type DownloadProgress struct {
+	JobID    string
+	URL      string
+	Progress services.Progress
+}
+
+func (e DownloadProgress) Name() string { return "download:progress" }
+func (e DownloadProgress) Payload() interface{} {
+	return map[string]interface{}{
+		"id":         e.JobID,
+		"url":        e.URL,
+		"message":    e.Progress.Message,
+		"phase":      e.Progress.Phase,
+		"bytesDone":  e.Progress.BytesDone,
+		"bytesTotal": e.Progress.BytesTotal,
+		"speed":      e.Progress.Speed,
+		"eta":        e.Progress.ETA,
+	}
+}
+
+type DownloadCompleted struct {
+	JobID string
+	URL   string
+}
+
+func (e DownloadCompleted) Name() string         { return "download:complete" }
+func (e DownloadCompleted) Payload() interface{} { return e.URL }
+
+type DownloadFailed struct {
+	JobID       string
+	URL         string
+	Error       string
+	Kind        string // Failure class from services.ErrorKind, may be empty
+	Diagnostics string // Folder with the failure's diagnostics bundle, may be empty
+}
+
+func (e DownloadFailed) Name() string { return "download:error" }
+func (e DownloadFailed) Payload() interface{} {
+	return map[string]string{
+		"url":         e.URL,
+		"error":       e.Error,
+		"kind":        e.Kind,
+		"diagnostics": e.Diagnostics,
+	}
+}
+
+// DownloadSkipped reports an album that was already in the download history
+type DownloadSkipped struct {
+	JobID   string
+	URL     string
+	Message string
+}
+
+func (e DownloadSkipped) Name() string { return "download:skipped" }
+func (e DownloadSkipped) Payload() interface{} {
+	return map[string]string{
+		"url":     e.URL,
+		"message": e.Message,
+	}
+}
+
+// DownloadCancelled reports a download stopped by the user before it finished
+type DownloadCancelled struct {
+	JobID string
+	URL   string
+}
+
+func (e DownloadCancelled) Name() string { return "download:cancelled" }
+func (e DownloadCancelled) Payload() interface{} {
+	return map[string]string{
+		"id":  e.JobID,
+		"url": e.URL,
+	}
+}
+
+type QueueUpdated struct {
+	Job services.DownloadJob
+}
+
+func (e QueueUpdated) Name() string         { return "queue:update" }
+func (e QueueUpdated) Payload() interface{} { return e.Job }
+
+// WatchScanned carries the report of every watch list re-scan
+type WatchScanned struct {
+	Report services.WatchReport
+}
+
+func (e WatchScanned) Name() string         { return "watch:report" }
+func (e WatchScanned) Payload() interface{} { return e.Report }
+
+// NewReleases lists the releases a watched artist published since the
+// previous scan
+type NewReleases struct {
+	ArtistURL string
+	Albums    []models.Album
+	Queued    []string // Download job IDs
+}
+
+func (e NewReleases) Name() string { return "watch:new_releases" }
+func (e NewReleases) Payload() interface{} {
+	return map[string]interface{}{
+		"artistUrl": e.ArtistURL,
+		"albums":    e.Albums,
+		"queued":    e.Queued,
+	}
+}
+
+// Error reports a failure that is not tied to a scan or download
+type Error struct {
+	Message string
+}
+
+func (e Error) Name() string         { return "log:error" }
+func (e Error) Payload() interface{} { return e.Message }
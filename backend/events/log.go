@@ -0,0 +1,50 @@
+package events
+
+import (
+	"log"
+)
+
+// Logger writes a one-line summary of each event to the standard logger.
+// Progress messages and album hits are skipped unless verbose is set
+type Logger struct {
+	Verbose bool
+}
+
+func (l Logger) Handle(e Event) {
+	switch e := e.(type) {
+	case ScanStarted:
+		log.Printf("Scan: Started %s", e.URL)
+	case AlbumFound:
+		if l.Verbose {
+			log.Printf("Scan: Found %s - %s (%s)", e.Album.Artist, e.Album.Title, e.Album.Status)
+		}
+	case ScanCompleted:
+		log.Printf("Scan: Complete: found %d albums", len(e.Albums))
+	case LabelScanCompleted:
+		log.Printf("Scan: Label %s has releases by %d artists", e.Label.Name, len(e.Label.Artists))
+	case ScanStopped:
+		log.Printf("Scan: Stopped after %d albums", e.Count)
+	case ScanFailed:
+		log.Printf("Scan: Error scanning %s: %s", e.URL, e.Error)
+	case DownloadStarted:
+		log.Printf("Download: Started %s", e.URL)
+	case DownloadProgress:
+		if l.Verbose {
+			log.Printf("Download: %s: [%s] %s", e.URL, e.Progress.Phase, e.Progress.Message)
+		}
+	case DownloadCompleted:
+		log.Printf("Download: Complete %s", e.URL)
+	case DownloadSkipped:
+		log.Printf("Download: Skipped %s: %s", e.URL, e.Message)
+	case DownloadCancelled:
+		log.Printf("Download: Cancelled %s", e.URL)
+	case DownloadFailed:
+		log.Printf("Download: Error downloading %s: %s", e.URL, e.Error)
+	case NewReleases:
+		for _, album := range e.Albums {
+			log.Printf("Watch: New release from %s: %s - %s (%s)", e.ArtistURL, album.Artist, album.Title, album.Status)
+		}
+	case Error:
+		log.Printf("Error: %s", e.Message)
+	}
+}
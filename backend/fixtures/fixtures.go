@@ -0,0 +1,95 @@
+// Package fixtures replays recorded Bandcamp pages and a fake Mail.tm API from
+// local HTTP servers, so scans and downloads can run without the network
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestFile is the file at the root of a fixture directory that lists its routes
+const ManifestFile = "routes.json"
+
+// BasePlaceholder stands for the replay server's URL in recorded bodies, so
+// absolute links point back at the server whatever port it listens on
+const BasePlaceholder = "{{base}}"
+
+// Manifest lists the recorded responses of a fixture directory
+type Manifest struct {
+	Routes    []Route    `json:"routes"`
+	Snapshots []Snapshot `json:"snapshots,omitempty"`
+}
+
+// Snapshot is the DOM of a page at one step of a recorded flow, after its
+// scripts ran. Snapshots are not served, they show what the flow saw
+type Snapshot struct {
+	Step string `json:"step"`
+	URL  string `json:"url"`
+	File string `json:"file"`
+}
+
+// Route is one recorded response
+type Route struct {
+	Method      string            `json:"method,omitempty"` // GET when empty
+	Path        string            `json:"path"`
+	Query       string            `json:"query,omitempty"`  // Raw query matched exactly; empty matches any query
+	Status      int               `json:"status,omitempty"` // 200 when empty
+	ContentType string            `json:"contentType,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	File        string            `json:"file"` // Body, relative to the fixture directory
+}
+
+// method returns the route's HTTP method, GET by default
+func (r Route) method() string {
+	if r.Method == "" {
+		return "GET"
+	}
+	return strings.ToUpper(r.Method)
+}
+
+// status returns the route's HTTP status, 200 by default
+func (r Route) status() int {
+	if r.Status == 0 {
+		return 200
+	}
+	return r.Status
+}
+
+// isText reports whether the body is text, in which BasePlaceholder is replaced
+func (r Route) isText() bool {
+	ct := strings.ToLower(r.ContentType)
+	return ct == "" || strings.HasPrefix(ct, "text/") || strings.Contains(ct, "json") || strings.Contains(ct, "javascript")
+}
+
+// Load reads the manifest of the fixture directory dir
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture manifest: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture manifest: %v", err)
+	}
+	for i, route := range m.Routes {
+		if route.Path == "" || route.File == "" {
+			return nil, fmt.Errorf("fixture route %d needs a path and a file", i+1)
+		}
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to the fixture directory dir
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write fixture manifest: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Message is an email delivered to a MailTM inbox
+type Message struct {
+	FromAddress string
+	FromName    string
+	Subject     string
+	Text        string
+	HTML        string
+}
+
+// MailTM fakes the parts of the Mail.tm API the mailbox provider uses:
+// domains, accounts, tokens and reading messages
+type MailTM struct {
+	domain string
+	srv    *httptest.Server
+
+	mu       sync.Mutex
+	accounts map[string]string    // Address -> password
+	ids      map[string]string    // Account ID -> address
+	created  []string             // Every address ever created, in order
+	tokens   map[string]string    // Token -> address
+	inboxes  map[string][]Message // Address -> messages, IDs are indexes
+}
+
+// NewMailTM starts a fake Mail.tm API handing out addresses at domain
+func NewMailTM(domain string) *MailTM {
+	m := &MailTM{
+		domain:   domain,
+		accounts: make(map[string]string),
+		ids:      make(map[string]string),
+		tokens:   make(map[string]string),
+		inboxes:  make(map[string][]Message),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /domains", m.handleDomains)
+	mux.HandleFunc("POST /accounts", m.handleAccounts)
+	mux.HandleFunc("DELETE /accounts/{id}", m.handleDeleteAccount)
+	mux.HandleFunc("POST /token", m.handleToken)
+	mux.HandleFunc("GET /messages", m.handleMessages)
+	mux.HandleFunc("GET /messages/{id}", m.handleMessage)
+	m.srv = httptest.NewServer(mux)
+	return m
+}
+
+// URL is the API's base URL, to pass to services.NewMailTMProvider
+func (m *MailTM) URL() string {
+	return m.srv.URL
+}
+
+// Close shuts the API down
+func (m *MailTM) Close() {
+	m.srv.Close()
+}
+
+// Deliver puts msg in the inbox of address, which needs no account
+func (m *MailTM) Deliver(address string, msg Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inboxes[address] = append(m.inboxes[address], msg)
+}
+
+// Accounts lists the addresses created so far, including deleted ones
+func (m *MailTM) Accounts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.created...)
+}
+
+// Open lists the addresses whose account hasn't been deleted
+func (m *MailTM) Open() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var addresses []string
+	for address := range m.accounts {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+func (m *MailTM) handleDomains(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"hydra:member": []map[string]string{{"domain": m.domain}},
+	})
+}
+
+type credentials struct {
+	Address  string `json:"address"`
+	Password string `json:"password"`
+}
+
+func (m *MailTM) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Address == "" {
+		http.Error(w, "bad account request", http.StatusBadRequest)
+		return
+	}
+	if !strings.HasSuffix(req.Address, "@"+m.domain) {
+		http.Error(w, "unknown domain", http.StatusUnprocessableEntity)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[req.Address]; ok {
+		http.Error(w, "address already used", http.StatusUnprocessableEntity)
+		return
+	}
+	m.accounts[req.Address] = req.Password
+	m.created = append(m.created, req.Address)
+	id := fmt.Sprintf("account-%d", len(m.created))
+	m.ids[id] = req.Address
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"id":      id,
+		"address": req.Address,
+	})
+}
+
+// handleDeleteAccount deletes an account along with its tokens and inbox.
+// Only the account's own token may delete it
+func (m *MailTM) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	address, ok := m.authorize(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := r.PathValue("id")
+	if m.ids[id] != address {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	delete(m.ids, id)
+	delete(m.accounts, address)
+	delete(m.inboxes, address)
+	for token, owner := range m.tokens {
+		if owner == address {
+			delete(m.tokens, token)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *MailTM) handleToken(w http.ResponseWriter, r *http.Request) {
+	var req credentials
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad token request", http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if password, ok := m.accounts[req.Address]; !ok || password != req.Password {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	token := fmt.Sprintf("token-%d", len(m.tokens)+1)
+	m.tokens[token] = req.Address
+	writeJSON(w, http.StatusOK, map[string]string{"token": token})
+}
+
+func (m *MailTM) handleMessages(w http.ResponseWriter, r *http.Request) {
+	address, ok := m.authorize(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := []map[string]interface{}{}
+	for i, msg := range m.inboxes[address] {
+		members = append(members, map[string]interface{}{
+			"id":      fmt.Sprint(i),
+			"from":    map[string]string{"address": msg.FromAddress, "name": msg.FromName},
+			"subject": msg.Subject,
+			"intro":   msg.Text,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"hydra:member": members})
+}
+
+func (m *MailTM) handleMessage(w http.ResponseWriter, r *http.Request) {
+	address, ok := m.authorize(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inbox := m.inboxes[address]
+	var index int
+	if _, err := fmt.Sscan(r.PathValue("id"), &index); err != nil || index < 0 || index >= len(inbox) {
+		http.NotFound(w, r)
+		return
+	}
+	msg := inbox[index]
+	html := []string{}
+	if msg.HTML != "" {
+		html = append(html, msg.HTML)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      fmt.Sprint(index),
+		"from":    map[string]string{"address": msg.FromAddress, "name": msg.FromName},
+		"subject": msg.Subject,
+		"html":    html,
+		"text":    msg.Text,
+	})
+}
+
+// authorize returns the address the request's bearer token belongs to
+func (m *MailTM) authorize(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	address, ok := m.tokens[token]
+	return address, ok
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,288 @@
+package fixtures
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// downloadPlaceholder stands in for downloaded files, which are too large to
+// keep and not ours to redistribute
+const downloadPlaceholder = "recorded download placeholder\n"
+
+// recordedTypes are the resource types whose responses are recorded
+var recordedTypes = map[string]bool{"document": true, "xhr": true, "fetch": true}
+
+// Recorder captures the documents and XHR/fetch responses of Playwright
+// pages, along with DOM snapshots, and saves them as a fixture directory the
+// Server replays. Every host seen is folded into the one replay server
+type Recorder struct {
+	pending sync.WaitGroup // Responses whose bodies are still being read
+
+	mu        sync.Mutex
+	pages     []pw.Page
+	responses []recordedResponse
+	index     map[string]int // "METHOD /path?query" -> responses index, the last response wins
+	hosts     map[string]bool
+	snapshots []recordedSnapshot
+	lastDOM   map[pw.Page][sha256.Size]byte // To skip snapshots of a DOM that didn't change
+}
+
+type recordedResponse struct {
+	route Route // File is chosen when saving
+	body  []byte
+}
+
+type recordedSnapshot struct {
+	step string
+	url  string
+	body []byte
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		index:   make(map[string]int),
+		hosts:   make(map[string]bool),
+		lastDOM: make(map[pw.Page][sha256.Size]byte),
+	}
+}
+
+// Attach records page's responses and snapshots its DOM whenever it loads.
+// It can be passed to playwright.Service.OnNewPage
+func (r *Recorder) Attach(page pw.Page) {
+	r.mu.Lock()
+	r.pages = append(r.pages, page)
+	r.mu.Unlock()
+
+	// Event handlers run on Playwright's dispatcher, which has to stay free
+	// to deliver the bodies they wait for
+	page.OnResponse(func(resp pw.Response) {
+		if !recordedTypes[resp.Request().ResourceType()] {
+			return
+		}
+		r.pending.Add(1)
+		go func() {
+			defer r.pending.Done()
+			r.record(resp)
+		}()
+	})
+	page.OnLoad(func(page pw.Page) {
+		r.pending.Add(1)
+		go func() {
+			defer r.pending.Done()
+			r.Snapshot(page, "load")
+		}()
+	})
+}
+
+func (r *Recorder) record(resp pw.Response) {
+	u, err := url.Parse(resp.URL())
+	if err != nil {
+		return
+	}
+	headers := resp.Headers()
+	route := Route{
+		Path:        u.Path,
+		ContentType: headers["content-type"],
+	}
+	if route.Path == "" {
+		route.Path = "/"
+	}
+	if u.RawQuery != "" {
+		// Scrubbed like the links in the recorded pages, which replay requests it
+		route.Query = strings.TrimPrefix(Scrub("?"+u.RawQuery), "?")
+	}
+	if method := resp.Request().Method(); method != "GET" {
+		route.Method = method
+	}
+	if status := resp.Status(); status != 200 {
+		route.Status = status
+	}
+	for _, name := range []string{"Location", "Content-Disposition"} {
+		if value := headers[strings.ToLower(name)]; value != "" {
+			if route.Headers == nil {
+				route.Headers = make(map[string]string)
+			}
+			route.Headers[name] = value
+		}
+	}
+
+	var body []byte
+	switch {
+	case strings.Contains(headers["content-disposition"], "attachment"):
+		body = []byte(downloadPlaceholder)
+	case route.Status >= 300 && route.Status < 400:
+		// Redirects have no body
+	default:
+		if body, err = resp.Body(); err != nil {
+			log.Printf("Recorder: Failed to read %s: %v", resp.URL(), err)
+			return
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hosts[u.Host] = true
+	key := route.method() + " " + route.Path + "?" + route.Query
+	if i, ok := r.index[key]; ok {
+		r.responses[i] = recordedResponse{route: route, body: body}
+		return
+	}
+	r.index[key] = len(r.responses)
+	r.responses = append(r.responses, recordedResponse{route: route, body: body})
+}
+
+// Snapshot saves the current DOM of page as the given step of the flow,
+// unless it is the same as the page's last snapshot
+func (r *Recorder) Snapshot(page pw.Page, step string) {
+	if page.IsClosed() {
+		return
+	}
+	content, err := page.Content()
+	if err != nil {
+		return // Navigating or closed in the meantime
+	}
+	sum := sha256.Sum256([]byte(content))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.lastDOM[page]; ok && last == sum {
+		return
+	}
+	r.lastDOM[page] = sum
+	r.snapshots = append(r.snapshots, recordedSnapshot{step: step, url: page.URL(), body: []byte(content)})
+}
+
+// SnapshotAll snapshots every open page
+func (r *Recorder) SnapshotAll(step string) {
+	r.mu.Lock()
+	pages := append([]pw.Page(nil), r.pages...)
+	r.mu.Unlock()
+	for _, page := range pages {
+		r.Snapshot(page, step)
+	}
+}
+
+// Save waits for pending responses and writes everything recorded so far to
+// the fixture directory dir, scrubbed of emails and tokens and with the
+// recorded hosts replaced by BasePlaceholder
+func (r *Recorder) Save(dir string) (*Manifest, error) {
+	r.pending.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(dir, "snapshots"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %v", err)
+	}
+
+	var hosts []string
+	for host := range r.hosts {
+		hosts = append(hosts, host)
+	}
+	rewriter := newHostRewriter(hosts)
+	clean := func(s string) string {
+		return Scrub(rewriter.Rewrite(s))
+	}
+
+	manifest := &Manifest{}
+	used := make(map[string]bool)
+	for _, resp := range r.responses {
+		route := resp.route
+		if location, ok := route.Headers["Location"]; ok {
+			route.Headers["Location"] = clean(location)
+		}
+		route.File = uniqueFile(used, routeFileName(route))
+		body := resp.body
+		if route.isText() {
+			body = []byte(clean(string(body)))
+		}
+		if err := os.WriteFile(filepath.Join(dir, route.File), body, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write fixture: %v", err)
+		}
+		manifest.Routes = append(manifest.Routes, route)
+	}
+
+	for i, snap := range r.snapshots {
+		file := fmt.Sprintf("snapshots/%02d-%s.html", i+1, slug(snap.step))
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(file)), []byte(clean(string(snap.body))), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write snapshot: %v", err)
+		}
+		manifest.Snapshots = append(manifest.Snapshots, Snapshot{Step: snap.step, URL: clean(snap.url), File: file})
+	}
+
+	if err := manifest.Save(dir); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// routeFileName names a route's body after its path, e.g. "album-name.html"
+// for /album/name
+func routeFileName(route Route) string {
+	name := slug(strings.Trim(route.Path, "/"))
+	if name == "" {
+		name = "index"
+	}
+	if route.method() != "GET" {
+		name = strings.ToLower(route.method()) + "-" + name
+	}
+
+	ext := path.Ext(route.Path)
+	ct := strings.ToLower(route.ContentType)
+	switch {
+	case ext != "" && len(ext) <= 5:
+		name = strings.TrimSuffix(name, slug(ext))
+		return strings.TrimSuffix(name, "-") + ext
+	case strings.Contains(ct, "html"):
+		ext = ".html"
+	case strings.Contains(ct, "json"):
+		ext = ".json"
+	case strings.Contains(ct, "javascript"):
+		ext = ".js"
+	case strings.HasPrefix(ct, "text/"):
+		ext = ".txt"
+	default:
+		ext = ".bin"
+	}
+	return name + ext
+}
+
+// uniqueFile returns name, numbered if it has been used already
+func uniqueFile(used map[string]bool, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	used[name] = true
+	return name
+}
+
+// slug lowercases s and joins its words with dashes, for file names
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(s) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 48 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package fixtures
+
+import (
+	"regexp"
+	"testing"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// fakeResponse is the part of a Playwright response the recorder reads
+type fakeResponse struct {
+	pw.Response
+	url     string
+	headers map[string]string
+	body    string
+}
+
+func (r *fakeResponse) URL() string                { return r.url }
+func (r *fakeResponse) Status() int                { return 200 }
+func (r *fakeResponse) Headers() map[string]string { return r.headers }
+func (r *fakeResponse) Body() ([]byte, error)      { return []byte(r.body), nil }
+func (r *fakeResponse) Request() pw.Request        { return fakeRequest{} }
+
+type fakeRequest struct{ pw.Request }
+
+func (fakeRequest) Method() string { return "GET" }
+
+func TestRecorderRoundTrip(t *testing.T) {
+	r := NewRecorder()
+	html := map[string]string{"content-type": "text/html"}
+	for _, resp := range []*fakeResponse{
+		{url: "https://artist.bandcamp.com/album/a", headers: html,
+			body: `<a href="https://artist.bandcamp.com/download?id=1&sig=abc">one</a> <a href="https://artist.bandcamp.com/download?id=2&sig=def">two</a>`},
+		{url: "https://artist.bandcamp.com/download?id=1&sig=abc", headers: html, body: "stale"},
+		{url: "https://artist.bandcamp.com/download?id=1&sig=xyz", headers: html, body: "first"}, // Same once scrubbed, the last one wins
+		{url: "https://artist.bandcamp.com/download?id=2&sig=def", headers: html, body: "second"},
+	} {
+		r.record(resp)
+	}
+
+	dir := t.TempDir()
+	manifest, err := r.Save(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Routes) != 3 {
+		t.Fatalf("recorded %d routes, want 3: %+v", len(manifest.Routes), manifest.Routes)
+	}
+	if query := manifest.Routes[1].Query; query != "id=1&sig="+Redacted {
+		t.Errorf("query = %q, want it recorded and scrubbed", query)
+	}
+
+	s, err := NewServer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Follow the replayed album page's links, each gets the page recorded for its query
+	_, album := get(t, s.URL()+"/album/a")
+	links := regexp.MustCompile(`href="([^"]+)"`).FindAllStringSubmatch(album, -1)
+	if len(links) != 2 {
+		t.Fatalf("album page = %q, want two links", album)
+	}
+	for i, want := range []string{"first", "second"} {
+		if status, body := get(t, links[i][1]); status != 200 || body != want {
+			t.Errorf("GET %s = %d %q, want %q", links[i][1], status, body, want)
+		}
+	}
+}
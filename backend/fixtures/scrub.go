@@ -0,0 +1,64 @@
+package fixtures
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ScrubbedEmail replaces the email addresses found in recorded pages
+const ScrubbedEmail = "fan@example.com"
+
+// Redacted replaces the tokens found in recorded pages
+const Redacted = "REDACTED"
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// Image names like logo@2x.png look like addresses but aren't
+	imageSuffix = regexp.MustCompile(`(?i)\.(png|jpe?g|gif|svg|webp)$`)
+	// Query parameters carrying signatures and tokens, such as the sig of
+	// the links in the download email
+	tokenParamPattern = regexp.MustCompile(`(?i)([?&](?:amp;)?(?:sig|fsig|token|access_token|id_token|crumb))=([^&"'\s<>\\]+)`)
+	// JSON string values, plain or HTML-escaped in an attribute, whose keys
+	// name a token, crumb, secret or session
+	tokenKeyPattern = regexp.MustCompile(`(?i)((?:"|&quot;)[a-z_]*(?:token|crumb|secret|session|sig)[a-z_]*(?:"|&quot;)\s*:\s*(?:"|&quot;))(.*?)("|&quot;)`)
+)
+
+// Scrub replaces email addresses and tokens in a recorded body
+func Scrub(body string) string {
+	body = emailPattern.ReplaceAllStringFunc(body, func(match string) string {
+		if imageSuffix.MatchString(match) {
+			return match
+		}
+		return ScrubbedEmail
+	})
+	body = tokenParamPattern.ReplaceAllString(body, "${1}="+Redacted)
+	return tokenKeyPattern.ReplaceAllString(body, "${1}"+Redacted+"${3}")
+}
+
+// hostRewriter replaces absolute URLs on the recorded hosts, plain or with
+// JSON-escaped slashes, with BasePlaceholder
+type hostRewriter struct {
+	replacer *strings.Replacer
+}
+
+func newHostRewriter(hosts []string) *hostRewriter {
+	// Longer hosts first so a host never matches inside a longer one
+	sorted := append([]string(nil), hosts...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	var pairs []string
+	for _, host := range sorted {
+		for _, scheme := range []string{"https", "http"} {
+			pairs = append(pairs,
+				scheme+"://"+host, BasePlaceholder,
+				scheme+`:\/\/`+host, BasePlaceholder,
+			)
+		}
+	}
+	return &hostRewriter{replacer: strings.NewReplacer(pairs...)}
+}
+
+func (h *hostRewriter) Rewrite(s string) string {
+	return h.replacer.Replace(s)
+}
@@ -0,0 +1,45 @@
+package fixtures
+
+import "testing"
+
+func TestScrub(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`<input value="user123@mail.tm">`, `<input value="fan@example.com">`},
+		{`<img src="/img/logo@2x.png">`, `<img src="/img/logo@2x.png">`},
+		{`https://bandcamp.com/download?from=email&id=1&sig=0a1b2c&ts=1`, `https://bandcamp.com/download?from=email&id=1&sig=REDACTED&ts=1`},
+		{`href="/download?id=1&amp;fsig=abc"`, `href="/download?id=1&amp;fsig=REDACTED"`},
+		{`{"crumb":"|api/x|1:abc","name":"Fan"}`, `{"crumb":"REDACTED","name":"Fan"}`},
+		{`data-blob="{&quot;session_token&quot;:&quot;s3cr3t&quot;}"`, `data-blob="{&quot;session_token&quot;:&quot;REDACTED&quot;}"`},
+	} {
+		if got := Scrub(tc.in); got != tc.want {
+			t.Errorf("Scrub(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestHostRewriter(t *testing.T) {
+	h := newHostRewriter([]string{"bandcamp.com", "artist.bandcamp.com"})
+	in := `<a href="https://artist.bandcamp.com/album/x">` + `{"url":"https:\/\/bandcamp.com\/download"}` + `<img src="https://f4.bcbits.com/img/a.jpg">`
+	want := `<a href="{{base}}/album/x">` + `{"url":"{{base}}\/download"}` + `<img src="https://f4.bcbits.com/img/a.jpg">`
+	if got := h.Rewrite(in); got != want {
+		t.Errorf("Rewrite = %q, want %q", got, want)
+	}
+}
+
+func TestRouteFileName(t *testing.T) {
+	for _, tc := range []struct {
+		route Route
+		want  string
+	}{
+		{Route{Path: "/album/some-album", ContentType: "text/html; charset=UTF-8"}, "album-some-album.html"},
+		{Route{Path: "/", ContentType: "text/html"}, "index.html"},
+		{Route{Method: "POST", Path: "/api/fancollection/1/collection_items", ContentType: "application/json"}, "post-api-fancollection-1-collection-items.json"},
+		{Route{Path: "/files/album.zip", ContentType: "application/zip"}, "files-album.zip"},
+	} {
+		if got := routeFileName(tc.route); got != tc.want {
+			t.Errorf("routeFileName(%s) = %q, want %q", tc.route.Path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package fixtures
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Server replays the routes of a fixture directory. Extra handlers can be
+// added for the parts of a flow that are not static, like the email form
+type Server struct {
+	dir      string
+	manifest *Manifest
+	srv      *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]http.HandlerFunc // "METHOD /path" -> handler
+	requests []string
+}
+
+// NewServer starts a server on a local port replaying the fixture directory dir
+func NewServer(dir string) (*Server, error) {
+	manifest, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		dir:      dir,
+		manifest: manifest,
+		handlers: make(map[string]http.HandlerFunc),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s, nil
+}
+
+// URL is the server's base URL, e.g. http://127.0.0.1:54321
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Handle serves method and path with h instead of the recorded routes
+func (s *Server) Handle(method string, path string, h http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+path] = h
+}
+
+// Requests lists the requests served so far as "METHOD /path?query"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	h := s.handlers[r.Method+" "+r.URL.Path]
+	s.mu.Unlock()
+	if h != nil {
+		h(w, r)
+		return
+	}
+
+	route, ok := s.match(r)
+	if !ok {
+		log.Printf("Fixtures: No route for %s %s", r.Method, r.URL.RequestURI())
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(route.File)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if route.isText() {
+		body = bytes.ReplaceAll(body, []byte(BasePlaceholder), []byte(s.URL()))
+	}
+
+	for name, value := range route.Headers {
+		w.Header().Set(name, value)
+	}
+	if route.ContentType != "" {
+		w.Header().Set("Content-Type", route.ContentType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(route.status())
+	w.Write(body)
+}
+
+// match finds the route for r, preferring one recorded with the same query
+// over one that matches any query
+func (s *Server) match(r *http.Request) (Route, bool) {
+	var fallback *Route
+	for i, route := range s.manifest.Routes {
+		if route.method() != r.Method || route.Path != r.URL.Path {
+			continue
+		}
+		if route.Query == r.URL.RawQuery {
+			return route, true
+		}
+		if route.Query == "" && fallback == nil {
+			fallback = &s.manifest.Routes[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return Route{}, false
+}
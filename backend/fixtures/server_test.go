@@ -0,0 +1,78 @@
+package fixtures
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServerReplaysRoutes(t *testing.T) {
+	dir := writeFixture(t, map[string]string{
+		ManifestFile: `{"routes": [
+			{"path": "/album/a", "contentType": "text/html", "file": "any.html"},
+			{"path": "/album/a", "query": "v=2", "contentType": "text/html", "file": "v2.html"},
+			{"path": "/gone", "status": 410, "contentType": "application/octet-stream", "file": "raw.bin"}
+		]}`,
+		"any.html": `<a href="{{base}}/album/b">b</a>`,
+		"v2.html":  "second version",
+		"raw.bin":  "{{base}}",
+	})
+	s, err := NewServer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if status, body := get(t, s.URL()+"/album/a?v=1"); status != 200 || body != `<a href="`+s.URL()+`/album/b">b</a>` {
+		t.Errorf("GET /album/a?v=1 = %d %q", status, body)
+	}
+	if _, body := get(t, s.URL()+"/album/a?v=2"); body != "second version" {
+		t.Errorf("GET /album/a?v=2 = %q, want the route recorded with that query", body)
+	}
+	if status, body := get(t, s.URL()+"/gone"); status != 410 || body != BasePlaceholder {
+		t.Errorf("GET /gone = %d %q, want 410 with the binary body untouched", status, body)
+	}
+	if status, _ := get(t, s.URL()+"/missing"); status != 404 {
+		t.Errorf("GET /missing = %d, want 404", status)
+	}
+
+	s.Handle("GET", "/missing", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "handled") })
+	if _, body := get(t, s.URL()+"/missing"); body != "handled" {
+		t.Errorf("GET /missing = %q, want the extra handler's", body)
+	}
+	if got := strings.Join(s.Requests(), ","); !strings.HasPrefix(got, "GET /album/a?v=1,GET /album/a?v=2") {
+		t.Errorf("requests = %s", got)
+	}
+}
+
+func TestLoadRejectsIncompleteRoutes(t *testing.T) {
+	dir := writeFixture(t, map[string]string{ManifestFile: `{"routes": [{"path": "/a"}]}`})
+	if _, err := Load(dir); err == nil {
+		t.Fatal("Load accepted a route without a file")
+	}
+}
@@ -1,12 +1,36 @@
 package models
 
 type Album struct {
-	Title    string `json:"title"`
-	Artist   string `json:"artist"`
-	CoverURL string `json:"coverUrl"`
-	URL      string `json:"url"`
-	IsFree   bool   `json:"isFree"`
-	IsNYP    bool   `json:"isNyp"` // Name Your Price
-	Price    string `json:"price"`
-	Status   string `json:"status"` // "free", "nyp", "paid"
+	ID           int64    `json:"id,omitempty"`   // Bandcamp's album or track id, when known
+	Type         string   `json:"type,omitempty"` // "album" or "track"
+	Title        string   `json:"title"`
+	Artist       string   `json:"artist"`
+	CoverURL     string   `json:"coverUrl"`
+	URL          string   `json:"url"`
+	Label        string   `json:"label,omitempty"`
+	Catalog      string   `json:"catalog,omitempty"`     // Catalog number
+	ReleaseDate  string   `json:"releaseDate,omitempty"` // YYYY-MM-DD
+	Tags         []string `json:"tags,omitempty"`
+	Tracks       []Track  `json:"tracks,omitempty"`
+	About        string   `json:"about,omitempty"`
+	Credits      string   `json:"credits,omitempty"`
+	MinimumPrice float64  `json:"minimumPrice,omitempty"`
+	Currency     string   `json:"currency,omitempty"` // ISO 4217, e.g. "EUR"
+	IsFree       bool     `json:"isFree"`
+	IsNYP        bool     `json:"isNyp"`                 // Name Your Price
+	Price        string   `json:"price"`                 // Human-readable, e.g. "7.00 EUR" or "Name your price"
+	Status       string   `json:"status"`                // "free", "nyp", "paid"
+	Downloaded   bool     `json:"downloaded"`            // Already in the download history
+	Owned        bool     `json:"owned,omitempty"`       // In the fan's collection
+	Wishlisted   bool     `json:"wishlisted,omitempty"`  // On the fan's wishlist
+	DownloadURL  string   `json:"downloadUrl,omitempty"` // The fan's download page for an owned item
+	ProbeError   string   `json:"probeError,omitempty"`  // Why the album page couldn't be checked, Status is then a guess
+}
+
+// Track is one entry of a release's track list
+type Track struct {
+	ID       int64   `json:"id,omitempty"`
+	Number   int     `json:"number"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"` // Seconds
 }
@@ -0,0 +1,17 @@
+package models
+
+// Label is a label's releases grouped by the artists on its roster
+type Label struct {
+	Name    string        `json:"name"`
+	URL     string        `json:"url"`
+	Artists []LabelArtist `json:"artists"`
+}
+
+// LabelArtist is an artist on a label's roster, or one credited on the
+// label's releases without a page of their own
+type LabelArtist struct {
+	Name   string  `json:"name"`
+	URL    string  `json:"url,omitempty"`
+	Albums []Album `json:"albums"`
+	Error  string  `json:"error,omitempty"` // Why the artist's own page couldn't be scanned
+}
@@ -0,0 +1,206 @@
+package playwright
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// DiagnosticsOptions sets what is kept of the pages of failed jobs
+type DiagnosticsOptions struct {
+	Dir   string // A bundle is saved here for each failure, "" for none
+	Trace bool   // Also record a Playwright trace of every job, saved with its bundles
+	Keep  int    // Bundles kept in Dir, the oldest are removed first; 0 keeps them all
+}
+
+// Files of a diagnostics bundle
+const (
+	ScreenshotFile = "screenshot.png"
+	PageFile       = "page.html"
+	URLFile        = "url.txt"
+	ConsoleFile    = "console.log"
+	ErrorFile      = "error.txt"
+	HARFile        = "network.har"
+	TraceFile      = "trace.zip" // Open with "npx playwright show-trace"
+)
+
+// SetDiagnostics sets where job pages save their diagnostics. It applies to
+// job pages opened afterwards
+func (s *Service) SetDiagnostics(opts DiagnosticsOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.diagnostics = opts
+}
+
+// JobPage is a page leased for one scan or download. Unless diagnostics are
+// off it records the console and the network traffic, and optionally a trace,
+// so a failure can be looked into afterwards
+type JobPage struct {
+	Page    playwright.Page
+	kind    string
+	lease   *Lease
+	opts    DiagnosticsOptions
+	har     *harRecorder
+	tracing bool
+
+	mu      sync.Mutex
+	console []string
+	closed  bool
+}
+
+// NewJobPage leases a page for a job of the given kind, e.g. "download",
+// waiting for a free browser context like Lease. Close it when the job is done
+func (s *Service) NewJobPage(ctx context.Context, kind string, leaseOpts LeaseOptions) (*JobPage, error) {
+	s.mu.Lock()
+	opts := s.diagnostics
+	s.mu.Unlock()
+
+	lease, err := s.Lease(ctx, leaseOpts)
+	if err != nil {
+		return nil, err
+	}
+	page := lease.Page
+	job := &JobPage{Page: page, kind: kind, lease: lease, opts: opts}
+
+	if opts.Dir != "" {
+		job.har = newHARRecorder(page)
+		page.OnConsole(func(msg playwright.ConsoleMessage) {
+			job.log(fmt.Sprintf("[%s] %s", msg.Type(), msg.Text()))
+		})
+		page.OnPageError(func(err error) {
+			job.log(fmt.Sprintf("[pageerror] %v", err))
+		})
+	}
+	if opts.Dir != "" && opts.Trace {
+		if err := lease.pooled.context.Tracing().Start(playwright.TracingStartOptions{
+			Screenshots: playwright.Bool(true),
+			Snapshots:   playwright.Bool(true),
+		}); err != nil {
+			log.Printf("Diagnostics: Failed to start tracing: %v", err)
+		} else {
+			job.tracing = true
+		}
+	}
+	return job, nil
+}
+
+func (j *JobPage) log(line string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.console = append(j.console, time.Now().Format("15:04:05.000")+" "+line)
+}
+
+// SaveDiagnostics saves a screenshot, the HTML and URL of the page, the
+// console log, the HAR and the trace into a new timestamped folder, and
+// returns the folder, or "" when diagnostics are off or the page was closed
+func (j *JobPage) SaveDiagnostics(cause error) (string, error) {
+	j.mu.Lock()
+	closed := j.closed
+	j.mu.Unlock()
+	if j.opts.Dir == "" || closed {
+		return "", nil
+	}
+	if err := os.MkdirAll(j.opts.Dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics folder: %v", err)
+	}
+	dir, err := os.MkdirTemp(j.opts.Dir, time.Now().Format("20060102-150405")+"-"+j.kind+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostics folder: %v", err)
+	}
+
+	// Save whatever can be had, a broken page may not give everything
+	if cause != nil {
+		writeFile(dir, ErrorFile, []byte(cause.Error()+"\n"))
+	}
+	writeFile(dir, URLFile, []byte(j.Page.URL()+"\n"))
+	if _, err := j.Page.Screenshot(playwright.PageScreenshotOptions{
+		Path:     playwright.String(filepath.Join(dir, ScreenshotFile)),
+		FullPage: playwright.Bool(true),
+		Timeout:  playwright.Float(10000),
+	}); err != nil {
+		log.Printf("Diagnostics: Failed to take screenshot: %v", err)
+	}
+	if html, err := j.Page.Content(); err == nil {
+		writeFile(dir, PageFile, []byte(html))
+	} else {
+		log.Printf("Diagnostics: Failed to read page: %v", err)
+	}
+
+	j.mu.Lock()
+	console := strings.Join(j.console, "\n")
+	j.mu.Unlock()
+	writeFile(dir, ConsoleFile, []byte(console+"\n"))
+	if har, err := j.har.JSON(); err == nil {
+		writeFile(dir, HARFile, har)
+	} else {
+		log.Printf("Diagnostics: Failed to encode HAR: %v", err)
+	}
+
+	if j.tracing {
+		tracing := j.lease.pooled.context.Tracing()
+		if err := tracing.Stop(filepath.Join(dir, TraceFile)); err != nil {
+			log.Printf("Diagnostics: Failed to save trace: %v", err)
+		}
+		// Keep tracing in case the page fails again
+		j.tracing = tracing.Start(playwright.TracingStartOptions{
+			Screenshots: playwright.Bool(true),
+			Snapshots:   playwright.Bool(true),
+		}) == nil
+	}
+
+	pruneBundles(j.opts.Dir, j.opts.Keep)
+	return dir, nil
+}
+
+// Close discards the trace and returns the page's lease. Calling it again
+// does nothing
+func (j *JobPage) Close() {
+	j.mu.Lock()
+	tracing := j.tracing
+	j.tracing = false
+	j.closed = true
+	j.mu.Unlock()
+
+	// The context outlives the lease, its trace must not run on into the next job
+	if tracing {
+		j.lease.pooled.context.Tracing().Stop()
+	}
+	j.lease.Return()
+}
+
+func writeFile(dir string, name string, data []byte) {
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		log.Printf("Diagnostics: Failed to write %s: %v", name, err)
+	}
+}
+
+// pruneBundles removes the oldest bundles in dir beyond keep. Bundle names
+// start with their timestamp, so they sort oldest first
+func pruneBundles(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var bundles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			bundles = append(bundles, entry.Name())
+		}
+	}
+	sort.Strings(bundles)
+	for len(bundles) > keep {
+		os.RemoveAll(filepath.Join(dir, bundles[0]))
+		bundles = bundles[1:]
+	}
+}
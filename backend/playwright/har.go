@@ -0,0 +1,205 @@
+package playwright
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// maxHAREntries caps the requests kept for a page's HAR
+const maxHAREntries = 5000
+
+// harRecorder builds a HAR of one page's requests from its events. Playwright
+// can record a HAR itself, but only for a whole context and only once it is
+// closed, which doesn't fit pooled contexts. Bodies are left out
+type harRecorder struct {
+	mu        sync.Mutex
+	responses map[playwright.Request]playwright.Response
+	entries   []harEntry
+}
+
+func newHARRecorder(page playwright.Page) *harRecorder {
+	h := &harRecorder{responses: make(map[playwright.Request]playwright.Response)}
+	// Only the fields the events carry are read, the handlers run on the
+	// dispatcher and must not wait on Playwright
+	page.OnResponse(func(resp playwright.Response) {
+		h.mu.Lock()
+		h.responses[resp.Request()] = resp
+		h.mu.Unlock()
+	})
+	page.OnRequestFinished(func(req playwright.Request) { h.add(req, "") })
+	page.OnRequestFailed(func(req playwright.Request) {
+		failure := "failed"
+		if err := req.Failure(); err != nil {
+			failure = err.Error()
+		}
+		h.add(req, failure)
+	})
+	return h
+}
+
+func (h *harRecorder) add(req playwright.Request, failure string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	resp := h.responses[req]
+	delete(h.responses, req)
+	if len(h.entries) >= maxHAREntries {
+		return
+	}
+
+	entry := harEntry{
+		StartedDateTime: time.Now(),
+		Time:            -1,
+		Request: harRequest{
+			Method:      req.Method(),
+			URL:         req.URL(),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     harHeaders(req.Headers()),
+			QueryString: harQuery(req.URL()),
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: harResponse{
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []harNameValue{},
+			Cookies:     []harNameValue{},
+			Content:     harContent{Size: -1},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Cache:        struct{}{},
+		Timings:      harTimings{Send: 0, Wait: -1, Receive: -1},
+		ResourceType: req.ResourceType(),
+		Error:        failure,
+	}
+	if resp != nil {
+		headers := resp.Headers()
+		entry.Response.Status = resp.Status()
+		entry.Response.StatusText = resp.StatusText()
+		entry.Response.Headers = harHeaders(headers)
+		entry.Response.Content.MimeType = headers["content-type"]
+		entry.Response.RedirectURL = headers["location"]
+	}
+	if timing := req.Timing(); timing != nil && timing.StartTime > 0 {
+		entry.StartedDateTime = time.UnixMilli(int64(timing.StartTime))
+		if timing.ResponseStart >= 0 && timing.RequestStart >= 0 {
+			entry.Timings.Wait = timing.ResponseStart - timing.RequestStart
+		}
+		if timing.ResponseEnd >= 0 && timing.ResponseStart >= 0 {
+			entry.Timings.Receive = timing.ResponseEnd - timing.ResponseStart
+		}
+		if timing.ResponseEnd >= 0 {
+			entry.Time = timing.ResponseEnd
+		}
+	}
+	h.entries = append(h.entries, entry)
+}
+
+// JSON returns the HAR recorded so far
+func (h *harRecorder) JSON() ([]byte, error) {
+	h.mu.Lock()
+	entries := append([]harEntry{}, h.entries...)
+	h.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	var har harFile
+	har.Log.Version = "1.2"
+	har.Log.Creator = harNameVersion{Name: "bcdl", Version: "1"}
+	har.Log.Entries = entries
+	return json.MarshalIndent(har, "", "  ")
+}
+
+func harHeaders(headers map[string]string) []harNameValue {
+	list := []harNameValue{}
+	for name, value := range headers {
+		list = append(list, harNameValue{Name: name, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func harQuery(rawURL string) []harNameValue {
+	list := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return list
+	}
+	for name, values := range u.Query() {
+		for _, value := range values {
+			list = append(list, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/
+type harFile struct {
+	Log struct {
+		Version string         `json:"version"`
+		Creator harNameVersion `json:"creator"`
+		Entries []harEntry     `json:"entries"`
+	} `json:"log"`
+}
+
+type harNameVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ResourceType    string      `json:"_resourceType,omitempty"`
+	Error           string      `json:"_error,omitempty"` // Why the request failed
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -0,0 +1,271 @@
+package playwright
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// DefaultMaxContexts is how many browser contexts may be open at once
+const DefaultMaxContexts = 8
+
+// Idle contexts are closed after a while, and every context after serving a
+// number of pages, so memory stays flat over long batch runs
+const (
+	contextIdleTimeout = time.Minute
+	contextMaxUses     = 50
+)
+
+// ErrPoolFull is returned when every browser context is leased and the
+// lease was asked not to wait
+var ErrPoolFull = errors.New("all browser contexts are in use")
+
+// LeaseOptions set up a leased page
+type LeaseOptions struct {
+	BlockResources bool // Abort images, media, fonts and analytics requests, e.g. while scanning
+	NoWait         bool // Fail with ErrPoolFull instead of waiting for a free context
+}
+
+// Lease is a page in a browser context of its own, borrowed from the
+// service's pool. Return it once done, which closes the page and keeps the
+// context for the next lease
+type Lease struct {
+	Page    playwright.Page
+	service *Service
+	pooled  *pooledContext
+	once    sync.Once
+}
+
+// pooledContext is a browser context kept open between leases
+type pooledContext struct {
+	context    playwright.BrowserContext
+	generation int // Of the session it was created with
+	uses       int
+	idle       *time.Timer // Closes it once idle too long
+}
+
+// contextPool hands out browser contexts, at most cap(slots) at a time
+type contextPool struct {
+	slots chan struct{} // Holds one value per leased context
+
+	mu         sync.Mutex
+	idle       []*pooledContext
+	generation int // Bumped when the session changes
+	closed     bool
+}
+
+func newContextPool(max int) *contextPool {
+	if max < 1 {
+		max = DefaultMaxContexts
+	}
+	return &contextPool{slots: make(chan struct{}, max)}
+}
+
+// SetMaxContexts caps how many browser contexts are open at once. Call it
+// before Init
+func (s *Service) SetMaxContexts(n int) {
+	s.pool = newContextPool(n)
+}
+
+// Lease opens a page, reusing an idle browser context when there is one. It
+// waits for a context to be returned while all of them are leased, unless
+// opts.NoWait is set, or until ctx is done
+func (s *Service) Lease(ctx context.Context, opts LeaseOptions) (*Lease, error) {
+	if s.browser == nil {
+		return nil, fmt.Errorf("browser not initialized")
+	}
+	if opts.NoWait {
+		select {
+		case s.pool.slots <- struct{}{}:
+		default:
+			return nil, ErrPoolFull
+		}
+	} else {
+		select {
+		case s.pool.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	pooled, page, err := s.leasePage()
+	if err != nil {
+		<-s.pool.slots
+		return nil, err
+	}
+	if opts.BlockResources {
+		if err := blockResources(page); err != nil {
+			log.Printf("Playwright: Failed to block resources: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	hooks := s.pageHooks
+	s.mu.Unlock()
+	for _, hook := range hooks {
+		hook(page)
+	}
+	return &Lease{Page: page, service: s, pooled: pooled}, nil
+}
+
+// leasePage opens a page in an idle context, or in a new one if there is
+// none or the idle one turns out to be broken
+func (s *Service) leasePage() (*pooledContext, playwright.Page, error) {
+	if pooled := s.pool.take(); pooled != nil {
+		if page, err := pooled.context.NewPage(); err == nil {
+			pooled.uses++
+			return pooled, page, nil
+		}
+		pooled.context.Close()
+	}
+
+	s.pool.mu.Lock()
+	generation := s.pool.generation
+	s.pool.mu.Unlock()
+	context, err := s.browser.NewContext(s.contextOptions())
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create context: %v", err)
+	}
+	page, err := context.NewPage()
+	if err != nil {
+		context.Close()
+		return nil, nil, fmt.Errorf("could not create page: %v", err)
+	}
+	return &pooledContext{context: context, generation: generation, uses: 1}, page, nil
+}
+
+// Return closes the page and hands its context back to the pool. Calling it
+// again does nothing
+func (l *Lease) Return() {
+	l.once.Do(func() {
+		l.Page.Close()
+		l.service.pool.put(l.pooled)
+		<-l.service.pool.slots
+	})
+}
+
+// take pops the most recently used idle context, closing the ones left from
+// an earlier session, or returns nil
+func (p *contextPool) take() *pooledContext {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.idle) > 0 {
+		pooled := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		pooled.idle.Stop()
+		if pooled.generation == p.generation {
+			return pooled
+		}
+		go pooled.context.Close()
+	}
+	return nil
+}
+
+// put keeps a returned context for the next lease, or closes it when it has
+// served enough pages or belongs to an earlier session
+func (p *contextPool) put(pooled *pooledContext) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || pooled.generation != p.generation || pooled.uses >= contextMaxUses {
+		go pooled.context.Close()
+		return
+	}
+	pooled.idle = time.AfterFunc(contextIdleTimeout, func() { p.expire(pooled) })
+	p.idle = append(p.idle, pooled)
+}
+
+// expire closes a context that stayed idle too long, unless it was leased
+// again in the meantime
+func (p *contextPool) expire(pooled *pooledContext) {
+	p.mu.Lock()
+	for i, idle := range p.idle {
+		if idle == pooled {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			p.mu.Unlock()
+			pooled.context.Close()
+			return
+		}
+	}
+	p.mu.Unlock()
+}
+
+// reset closes the idle contexts so new leases start from the current
+// session. Leased contexts are closed when they are returned
+func (p *contextPool) reset() {
+	p.mu.Lock()
+	p.generation++
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, pooled := range idle {
+		pooled.idle.Stop()
+		pooled.context.Close()
+	}
+}
+
+// close closes the idle contexts and every context returned afterwards
+func (p *contextPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.reset()
+}
+
+// blockedResourceTypes are not needed to read a page
+var blockedResourceTypes = map[string]bool{
+	"image": true,
+	"media": true,
+	"font":  true,
+}
+
+// analyticsHosts are trackers, blocked along with their subdomains
+var analyticsHosts = []string{
+	"google-analytics.com",
+	"googletagmanager.com",
+	"googlesyndication.com",
+	"doubleclick.net",
+	"facebook.net",
+	"scorecardresearch.com",
+	"quantserve.com",
+	"hotjar.com",
+	"segment.io",
+	"nr-data.net",
+	"clarity.ms",
+	"bat.bing.com",
+}
+
+// blockResources aborts the page's requests for images, media, fonts and
+// analytics
+func blockResources(page playwright.Page) error {
+	return page.Route("**/*", func(route playwright.Route) {
+		req := route.Request()
+		if blockedResourceTypes[req.ResourceType()] || isAnalytics(req.URL()) {
+			route.Abort("blockedbyclient")
+			return
+		}
+		route.Continue()
+	})
+}
+
+// isAnalytics reports whether rawURL points at one of analyticsHosts
+func isAnalytics(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, blocked := range analyticsHosts {
+		if host == blocked || strings.HasSuffix(host, "."+blocked) {
+			return true
+		}
+	}
+	return false
+}
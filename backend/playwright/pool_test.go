@@ -0,0 +1,84 @@
+package playwright
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsAnalytics(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://www.google-analytics.com/g/collect?v=2":   true,
+		"https://www.googletagmanager.com/gtag/js?id=G-1":  true,
+		"https://connect.facebook.net/en_US/fbevents.js":   true,
+		"https://bam.nr-data.net/1/abc":                    true,
+		"https://artist.bandcamp.com/album/name":           false,
+		"https://f4.bcbits.com/img/a123_16.jpg":            false,
+		"https://notgoogle-analytics.com/collect":          false,
+		"https://google-analytics.com.example.org/collect": false,
+		"::not a url": false,
+	} {
+		if got := isAnalytics(url); got != want {
+			t.Errorf("isAnalytics(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestLeaseReusesAndCapsContexts(t *testing.T) {
+	if os.Getenv("BCDL_BROWSER_TESTS") != "1" {
+		t.Skip("set BCDL_BROWSER_TESTS=1 to run the browser flows")
+	}
+	svc := NewService()
+	svc.SetOutput(io.Discard)
+	svc.SetMaxContexts(2)
+	if err := svc.Init(); err != nil {
+		t.Fatalf("failed to start Playwright: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	ctx := context.Background()
+
+	first, err := svc.Lease(ctx, LeaseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Lease(ctx, LeaseOptions{BlockResources: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Lease(ctx, LeaseOptions{NoWait: true}); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("third lease err = %v, want ErrPoolFull", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := svc.Lease(waitCtx, LeaseOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting lease err = %v, want context.DeadlineExceeded", err)
+	}
+
+	reused := first.pooled.context
+	first.Return()
+	first.Return() // Returning twice is harmless
+	if !first.Page.IsClosed() {
+		t.Errorf("returned page is still open")
+	}
+	third, err := svc.Lease(ctx, LeaseOptions{NoWait: true})
+	if err != nil {
+		t.Fatalf("lease after a return: %v", err)
+	}
+	if third.pooled.context != reused {
+		t.Errorf("lease opened a new context instead of reusing the returned one")
+	}
+
+	// A new session retires the contexts of the old one
+	svc.SetSession(nil)
+	third.Return()
+	second.Return()
+	svc.pool.mu.Lock()
+	idle := len(svc.pool.idle)
+	svc.pool.mu.Unlock()
+	if idle != 0 {
+		t.Errorf("%d contexts of the old session kept idle", idle)
+	}
+}
@@ -2,20 +2,45 @@ package playwright
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 
 	"github.com/playwright-community/playwright-go"
 )
 
+// UserAgent is the desktop Chrome user agent sent with every request
+const UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
 type Service struct {
 	pw      *playwright.Playwright
 	browser playwright.Browser
+	output  io.Writer
+	pool    *contextPool
+
+	mu          sync.Mutex
+	session     *playwright.OptionalStorageState // Cookies and local storage every new page starts with
+	pageHooks   []func(playwright.Page)
+	diagnostics DiagnosticsOptions
 }
 
+// NewService creates the service. BCDL_BROWSER_CONTEXTS caps how many
+// browser contexts are open at once
 func NewService() *Service {
-	return &Service{}
+	maxContexts, _ := strconv.Atoi(os.Getenv("BCDL_BROWSER_CONTEXTS"))
+	return &Service{
+		output: os.Stdout,
+		pool:   newContextPool(maxContexts),
+	}
+}
+
+// SetOutput redirects the output of the Playwright driver and browser
+// installer, which is stdout by default
+func (s *Service) SetOutput(w io.Writer) {
+	s.output = w
 }
 
 func (s *Service) Init() error {
@@ -30,19 +55,25 @@ func (s *Service) Init() error {
 		log.Printf("Found local browsers folder: %s (Portable Mode)", localBrowsersPath)
 		os.Setenv("PLAYWRIGHT_BROWSERS_PATH", localBrowsersPath)
 		portableMode = true
+	} else if path := os.Getenv("PLAYWRIGHT_BROWSERS_PATH"); path != "" {
+		// Browsers bundled elsewhere, e.g. for offline test runs
+		if _, err := os.Stat(path); err == nil {
+			log.Printf("Using browsers from PLAYWRIGHT_BROWSERS_PATH: %s (Portable Mode)", path)
+			portableMode = true
+		}
 	}
 
 	// Install driver and browsers only if NOT in portable mode
 	if !portableMode {
 		log.Println("Installing Playwright browsers...")
-		if err := playwright.Install(); err != nil {
+		if err := playwright.Install(&playwright.RunOptions{Stdout: s.output}); err != nil {
 			return fmt.Errorf("could not install playwright browsers: %v", err)
 		}
 	} else {
 		log.Println("Using bundled browsers (Portable Mode)")
 	}
 
-	s.pw, err = playwright.Run()
+	s.pw, err = playwright.Run(&playwright.RunOptions{Stdout: s.output})
 	if err != nil {
 		return fmt.Errorf("could not start playwright: %v", err)
 	}
@@ -72,29 +103,26 @@ func (s *Service) Init() error {
 	return nil
 }
 
-func (s *Service) NewPage() (playwright.Page, error) {
-	if s.browser == nil {
-		return nil, fmt.Errorf("browser not initialized")
-	}
+// OnNewPage calls fn with every page leased from now on, before it is
+// handed out, e.g. to record its traffic
+func (s *Service) OnNewPage(fn func(playwright.Page)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageHooks = append(s.pageHooks, fn)
+}
 
-	// Create context with user agent to avoid detection
-	context, err := s.browser.NewContext(playwright.BrowserNewContextOptions{
-		UserAgent:       playwright.String("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+// contextOptions are the options every browser context is created with
+func (s *Service) contextOptions() playwright.BrowserNewContextOptions {
+	// User agent set to avoid detection
+	return playwright.BrowserNewContextOptions{
+		UserAgent:       playwright.String(UserAgent),
 		AcceptDownloads: playwright.Bool(true), // Added AcceptDownloads
-	})
-	if err != nil {
-		return nil, fmt.Errorf("could not create context: %v", err)
-	}
-
-	page, err := context.NewPage()
-	if err != nil {
-		return nil, fmt.Errorf("could not create page: %v", err)
+		StorageState:    s.Session(),
 	}
-
-	return page, nil
 }
 
 func (s *Service) Close() {
+	s.pool.close()
 	if s.browser != nil {
 		s.browser.Close()
 	}
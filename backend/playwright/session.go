@@ -0,0 +1,229 @@
+package playwright
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// LoginURL is the page the interactive login starts on
+const LoginURL = "https://bandcamp.com/login"
+
+// SessionCookie is the cookie Bandcamp sets once a fan has logged in
+const SessionCookie = "identity"
+
+// ParseSession reads a Playwright storage-state JSON file or a Netscape
+// cookies.txt as exported by browser extensions, telling them apart by
+// their first character
+func ParseSession(data []byte) (*playwright.OptionalStorageState, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("session file is empty")
+	}
+
+	if trimmed[0] == '{' {
+		var state playwright.OptionalStorageState
+		if err := json.Unmarshal(trimmed, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse storage state: %v", err)
+		}
+		return &state, nil
+	}
+
+	cookies, err := parseCookiesTxt(trimmed)
+	if err != nil {
+		return nil, err
+	}
+	return &playwright.OptionalStorageState{Cookies: cookies}, nil
+}
+
+// parseCookiesTxt reads the Netscape cookie file format: one cookie per line
+// with tab-separated domain, include-subdomains flag, path, secure flag,
+// expiry and name/value. Lines starting with "#HttpOnly_" are HttpOnly
+// cookies, other "#" lines are comments
+func parseCookiesTxt(data []byte) ([]playwright.OptionalCookie, error) {
+	var cookies []playwright.OptionalCookie
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimRight(lines.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "") // Empty value
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookies.txt line %d: expected 7 tab-separated fields, got %d", n, len(fields))
+		}
+
+		domain := fields[0]
+		if strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+		expires, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookies.txt line %d: bad expiry %q", n, fields[4])
+		}
+		if expires <= 0 {
+			expires = -1 // Session cookie
+		}
+
+		cookies = append(cookies, playwright.OptionalCookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   playwright.String(domain),
+			Path:     playwright.String(fields[2]),
+			Expires:  playwright.Float(expires),
+			HttpOnly: playwright.Bool(httpOnly),
+			Secure:   playwright.Bool(strings.EqualFold(fields[3], "TRUE")),
+		})
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cookies.txt: %v", err)
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("no cookies found in cookies.txt")
+	}
+	return cookies, nil
+}
+
+// HTTPCookies returns the unexpired cookies of state for use with net/http
+func HTTPCookies(state *playwright.OptionalStorageState) []*http.Cookie {
+	if state == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, c := range state.Cookies {
+		cookie := &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"}
+		if c.Domain != nil {
+			cookie.Domain = *c.Domain
+		}
+		if c.Path != nil && *c.Path != "" {
+			cookie.Path = *c.Path
+		}
+		if c.Expires != nil && *c.Expires > 0 {
+			sec, frac := math.Modf(*c.Expires)
+			cookie.Expires = time.Unix(int64(sec), int64(frac*1e9))
+			if cookie.Expires.Before(now) {
+				continue
+			}
+		}
+		if c.Secure != nil {
+			cookie.Secure = *c.Secure
+		}
+		if c.HttpOnly != nil {
+			cookie.HttpOnly = *c.HttpOnly
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies
+}
+
+// SessionCookieValue returns the value of Bandcamp's login cookie in state,
+// or "" when it has none or it has expired
+func SessionCookieValue(state *playwright.OptionalStorageState) string {
+	for _, c := range HTTPCookies(state) {
+		if c.Name == SessionCookie && c.Value != "" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// SetSession makes pages created from now on start with the cookies and
+// local storage of state. nil goes back to anonymous pages
+func (s *Service) SetSession(state *playwright.OptionalStorageState) {
+	s.mu.Lock()
+	s.session = state
+	s.mu.Unlock()
+	s.pool.reset()
+}
+
+// Session returns the state new pages start with, nil when anonymous
+func (s *Service) Session() *playwright.OptionalStorageState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session
+}
+
+// Login opens a visible browser window on Bandcamp's login page and waits
+// until the user has logged in, closes the window or ctx is done. The
+// logged-in state becomes the session and is returned so it can be saved
+func (s *Service) Login(ctx context.Context) (*playwright.OptionalStorageState, error) {
+	if s.pw == nil {
+		return nil, fmt.Errorf("playwright not initialized")
+	}
+
+	// The shared browser is headless, the login needs a window of its own
+	browser, err := s.pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
+		Headless: playwright.Bool(false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not launch browser: %v", err)
+	}
+	defer browser.Close()
+
+	browserCtx, err := browser.NewContext(playwright.BrowserNewContextOptions{
+		UserAgent: playwright.String(UserAgent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create context: %v", err)
+	}
+	page, err := browserCtx.NewPage()
+	if err != nil {
+		return nil, fmt.Errorf("could not create page: %v", err)
+	}
+	if _, err := page.Goto(LoginURL); err != nil {
+		return nil, fmt.Errorf("could not open login page: %v", err)
+	}
+	log.Println("Waiting for login in the browser window...")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if page.IsClosed() {
+			return nil, fmt.Errorf("login window closed before logging in")
+		}
+		cookies, err := browserCtx.Cookies("https://bandcamp.com")
+		if err != nil {
+			return nil, fmt.Errorf("login window closed before logging in")
+		}
+		for _, c := range cookies {
+			if c.Name == SessionCookie && c.Value != "" {
+				state, err := browserCtx.StorageState()
+				if err != nil {
+					return nil, fmt.Errorf("could not read session: %v", err)
+				}
+				session := state.ToOptionalStorageState()
+				s.SetSession(session)
+				log.Println("Logged in to Bandcamp")
+				return session, nil
+			}
+		}
+	}
+}
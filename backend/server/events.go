@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"bcdl-app/backend/events"
+)
+
+// event is a named payload, mirroring the events the GUI receives
+type event struct {
+	Name string
+	Data []byte
+}
+
+// hub fans events out to every connected SSE client
+type hub struct {
+	mu      sync.Mutex
+	clients map[chan event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		clients: make(map[chan event]struct{}),
+	}
+}
+
+// Handle sends a bus event to all clients. Slow clients drop events rather
+// than blocking the scanner or downloader
+func (h *hub) Handle(e events.Event) {
+	name := e.Name()
+	data, err := json.Marshal(e.Payload())
+	if err != nil {
+		log.Printf("Server: Failed to encode %s event: %v", name, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- event{Name: name, Data: data}:
+		default:
+		}
+	}
+}
+
+func (h *hub) subscribe() chan event {
+	ch := make(chan event, 64)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	delete(h.clients, ch)
+	h.mu.Unlock()
+}
+
+// handleEvents streams events as Server-Sent Events until the client disconnects
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := s.hub.subscribe()
+	defer s.hub.unsubscribe(ch)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Name, ev.Data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
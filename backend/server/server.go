@@ -0,0 +1,370 @@
+// Package server exposes the scanner and download queue over a local
+// HTTP/JSON API, with progress events streamed as Server-Sent Events.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"bcdl-app/backend/events"
+	"bcdl-app/backend/services"
+)
+
+// maxSessionSize bounds the session files accepted by POST /api/session
+const maxSessionSize = 1 << 20
+
+// Server drives the same operations as the GUI's App methods
+type Server struct {
+	scanner services.Scanner
+	fans    *services.FanScanner
+	queue   *services.DownloadQueue
+	history *services.DownloadHistory
+	watch   *services.WatchList
+	session *services.Session
+	bus     *events.Bus
+	token   string
+	hub     *hub
+
+	mu         sync.Mutex
+	baseCtx    context.Context // Parent of scans, cancelled on shutdown
+	scanCancel context.CancelFunc
+}
+
+// New creates a server that streams every event published on bus to its SSE
+// clients. A non-empty token requires clients to send
+// "Authorization: Bearer <token>"
+func New(scanner services.Scanner, fans *services.FanScanner, queue *services.DownloadQueue, history *services.DownloadHistory, watch *services.WatchList, session *services.Session, bus *events.Bus, token string) *Server {
+	s := &Server{
+		scanner: scanner,
+		fans:    fans,
+		queue:   queue,
+		history: history,
+		watch:   watch,
+		session: session,
+		bus:     bus,
+		token:   token,
+		hub:     newHub(),
+		baseCtx: context.Background(),
+	}
+	bus.Subscribe(s.hub)
+	return s
+}
+
+// Handler returns the API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/scan", s.handleScan)
+	mux.HandleFunc("POST /api/scan/stop", s.handleStopScan)
+	mux.HandleFunc("POST /api/downloads", s.handleEnqueue)
+	mux.HandleFunc("GET /api/downloads", s.handleListDownloads)
+	mux.HandleFunc("DELETE /api/downloads", s.handleClearDownloads)
+	mux.HandleFunc("DELETE /api/downloads/{id}", s.handleRemoveDownload)
+	mux.HandleFunc("POST /api/downloads/{id}/pause", s.handleJobAction)
+	mux.HandleFunc("POST /api/downloads/{id}/resume", s.handleJobAction)
+	mux.HandleFunc("POST /api/downloads/{id}/cancel", s.handleJobAction)
+	mux.HandleFunc("POST /api/downloads/{id}/reorder", s.handleReorder)
+	mux.HandleFunc("GET /api/history", s.handleListHistory)
+	mux.HandleFunc("DELETE /api/history", s.handleForgetHistory)
+	mux.HandleFunc("GET /api/watch", s.handleListWatched)
+	mux.HandleFunc("POST /api/watch", s.handleWatch)
+	mux.HandleFunc("DELETE /api/watch/{id}", s.handleUnwatch)
+	mux.HandleFunc("POST /api/watch/{id}/check", s.handleCheckWatched)
+	mux.HandleFunc("GET /api/watch/report", s.handleWatchReport)
+	mux.HandleFunc("GET /api/session", s.handleCheckSession)
+	mux.HandleFunc("POST /api/session", s.handleImportSession)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	return s.authenticate(mux)
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	s.mu.Lock()
+	s.baseCtx = ctx
+	s.mu.Unlock()
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Server: Listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// authenticate checks the bearer token. EventSource cannot set headers, so
+// the token is also accepted as a ?token= query parameter
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleScan starts a scan in the background; results arrive as scan:* events
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL      string `json:"url"`
+		Label    bool   `json:"label"`    // Group the results by the label's roster
+		Artists  bool   `json:"artists"`  // Also scan each roster artist's page
+		Fan      bool   `json:"fan"`      // The URL is a fan's collection
+		Wishlist bool   `json:"wishlist"` // With fan, include the wishlist
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("url is required"))
+		return
+	}
+
+	s.mu.Lock()
+	if s.scanCancel != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Errorf("a scan is already running"))
+		return
+	}
+	scanCtx, cancel := context.WithCancel(s.baseCtx)
+	s.scanCancel = cancel
+	s.mu.Unlock()
+
+	scanner := s.scanner
+	if req.Fan {
+		scanner = services.WithHistory(s.fans.Scanner(services.FanScanOptions{Collection: true, Wishlist: req.Wishlist}), s.history)
+	}
+	go s.runScan(scanCtx, scanner, req.URL, req.Label, req.Artists)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started", "url": req.URL})
+}
+
+func (s *Server) runScan(ctx context.Context, scanner services.Scanner, url string, label bool, includeArtists bool) {
+	defer func() {
+		s.mu.Lock()
+		s.scanCancel()
+		s.scanCancel = nil
+		s.mu.Unlock()
+	}()
+
+	if label {
+		events.RunLabelScan(ctx, s.bus, scanner, url, includeArtists)
+		return
+	}
+	events.RunScan(ctx, s.bus, scanner, url)
+}
+
+func (s *Server) handleStopScan(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	cancel := s.scanCancel
+	s.mu.Unlock()
+
+	if cancel == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("no scan is currently running"))
+		return
+	}
+	cancel()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "stopping"})
+}
+
+func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL           string                       `json:"url"`
+		Dir           string                       `json:"dir"`
+		Format        string                       `json:"format"`
+		PostProcess   *services.PostProcessOptions `json:"postProcess"`
+		IgnoreHistory bool                         `json:"ignoreHistory"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if req.Format == "" {
+		req.Format = "flac"
+	}
+
+	opts := services.DownloadOptions{PostProcess: req.PostProcess, IgnoreHistory: req.IgnoreHistory}
+	job, err := s.queue.EnqueueWithOptions(req.URL, req.Dir, req.Format, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) handleListDownloads(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.queue.Jobs())
+}
+
+func (s *Server) handleClearDownloads(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]int{"removed": s.queue.ClearFinished()})
+}
+
+func (s *Server) handleRemoveDownload(w http.ResponseWriter, r *http.Request) {
+	if err := s.queue.Remove(r.PathValue("id")); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJobAction dispatches pause, resume and cancel on the last path segment
+func (s *Server) handleJobAction(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	action := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	var err error
+	switch action {
+	case "pause":
+		err = s.queue.Pause(id)
+	case "resume":
+		err = s.queue.Resume(id)
+	case "cancel":
+		err = s.queue.Cancel(id)
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReorder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Index int `json:"index"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if err := s.queue.Reorder(r.PathValue("id"), req.Index); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.history.Entries())
+}
+
+// handleForgetHistory removes ?url= from the history so it can be downloaded again
+func (s *Server) handleForgetHistory(w http.ResponseWriter, r *http.Request) {
+	albumURL := r.URL.Query().Get("url")
+	if albumURL == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("url is required"))
+		return
+	}
+	if err := s.history.Forget(albumURL); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListWatched(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.watch.Artists())
+}
+
+// handleWatch adds an artist to the watch list. autoDownload defaults to true
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	req := services.WatchedArtist{AutoDownload: true}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if req.Format == "" {
+		req.Format = "flac"
+	}
+
+	artist, err := s.watch.Add(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, artist)
+}
+
+func (s *Server) handleUnwatch(w http.ResponseWriter, r *http.Request) {
+	if err := s.watch.Remove(r.PathValue("id")); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCheckWatched re-scans a watched artist and returns the report. A
+// failed scan still returns its report, which carries the error
+func (s *Server) handleCheckWatched(w http.ResponseWriter, r *http.Request) {
+	report, err := s.watch.Check(r.Context(), r.PathValue("id"))
+	if err != nil && report.ArtistID == "" {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleWatchReport returns the latest report of each watched artist
+func (s *Server) handleWatchReport(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.watch.Reports())
+}
+
+func (s *Server) handleCheckSession(w http.ResponseWriter, r *http.Request) {
+	status, err := s.session.Check(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleImportSession logs in with the cookies.txt or storage-state file in
+// the request body, and returns the resulting status
+func (s *Server) handleImportSession(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxSessionSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if err := s.session.ImportData(data); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.handleCheckSession(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
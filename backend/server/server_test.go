@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bcdl-app/backend/events"
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/services"
+)
+
+const testArtist = "https://artist.bandcamp.com"
+
+// testServer is a server whose scans block until stopped and whose queue
+// is never started, so jobs stay where the test puts them
+type testServer struct {
+	*Server
+	url string
+	bus *events.Bus
+}
+
+func newTestServer(t *testing.T, token string) *testServer {
+	t.Helper()
+	scanner := services.ScanFunc(func(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+		if url == testArtist {
+			return []models.Album{{URL: testArtist + "/album/one"}}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	download := services.DownloadFunc(func(ctx context.Context, url string, downloadDir string, format string, opts services.DownloadOptions, onProgress services.ProgressCallback) error {
+		return nil
+	})
+	queue := services.NewDownloadQueue(download, "")
+	bus := events.NewBus()
+	s := New(scanner, services.NewFanScanner(), queue, services.NewDownloadHistory(""),
+		services.NewWatchList(scanner, queue, ""), services.NewSession(nil, nil, ""), bus, token)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.baseCtx = ctx
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	return &testServer{Server: s, url: srv.URL, bus: bus}
+}
+
+// do sends a request with the token, if any, and returns the status and body
+func (ts *testServer) do(t *testing.T, method string, path string, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.url+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ts.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestAuthentication(t *testing.T) {
+	ts := newTestServer(t, "secret")
+	for _, tc := range []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"missing", "/api/downloads", "", http.StatusUnauthorized},
+		{"wrong bearer", "/api/downloads", "Bearer nope", http.StatusUnauthorized},
+		{"not bearer", "/api/downloads", "Basic secret", http.StatusUnauthorized},
+		{"wrong query", "/api/downloads?token=nope", "", http.StatusUnauthorized},
+		{"bearer", "/api/downloads", "Bearer secret", http.StatusOK},
+		{"query", "/api/downloads?token=secret", "", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", ts.url+tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Error("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+
+	open := newTestServer(t, "")
+	if status, _ := open.do(t, "GET", "/api/downloads", ""); status != http.StatusOK {
+		t.Errorf("status without a configured token = %d, want 200", status)
+	}
+}
+
+func TestDownloadRoutes(t *testing.T) {
+	ts := newTestServer(t, "secret")
+	enqueue := func(url string) services.DownloadJob {
+		t.Helper()
+		status, body := ts.do(t, "POST", "/api/downloads", `{"url": "`+url+`"}`)
+		if status != http.StatusAccepted {
+			t.Fatalf("enqueue status = %d: %s", status, body)
+		}
+		var job services.DownloadJob
+		if err := json.Unmarshal([]byte(body), &job); err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+	first := enqueue(testArtist + "/album/one")
+	second := enqueue(testArtist + "/album/two")
+	if first.Format != "flac" {
+		t.Errorf("format = %q, want the flac default", first.Format)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/downloads", `{"dir": "/music"}`, http.StatusBadRequest},
+		{"POST", "/api/downloads", `not json`, http.StatusBadRequest},
+		{"GET", "/api/downloads", "", http.StatusOK},
+		{"POST", "/api/downloads/" + first.ID + "/pause", "", http.StatusNoContent},
+		{"POST", "/api/downloads/" + first.ID + "/pause", "", http.StatusConflict},
+		{"POST", "/api/downloads/" + first.ID + "/resume", "", http.StatusNoContent},
+		{"POST", "/api/downloads/" + second.ID + "/reorder", `{"index": 0}`, http.StatusNoContent},
+		{"POST", "/api/downloads/" + second.ID + "/reorder", `{`, http.StatusBadRequest},
+		{"POST", "/api/downloads/missing/reorder", `{"index": 0}`, http.StatusConflict},
+		{"DELETE", "/api/downloads/" + first.ID, "", http.StatusConflict}, // Not finished
+		{"POST", "/api/downloads/" + first.ID + "/cancel", "", http.StatusNoContent},
+		{"POST", "/api/downloads/" + first.ID + "/cancel", "", http.StatusConflict},
+		{"DELETE", "/api/downloads/" + first.ID, "", http.StatusNoContent},
+		{"DELETE", "/api/downloads/" + first.ID, "", http.StatusConflict},
+		{"DELETE", "/api/downloads", "", http.StatusOK},
+		{"GET", "/api/history", "", http.StatusOK},
+		{"DELETE", "/api/history", "", http.StatusBadRequest},
+		{"DELETE", "/api/history?url=" + testArtist + "/album/one", "", http.StatusNoContent},
+	} {
+		if status, body := ts.do(t, tc.method, tc.path, tc.body); status != tc.want {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, status, tc.want, body)
+		}
+	}
+
+	jobs := ts.queue.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != second.ID {
+		t.Errorf("jobs = %+v, want only the second", jobs)
+	}
+}
+
+func TestWatchRoutes(t *testing.T) {
+	ts := newTestServer(t, "")
+	status, body := ts.do(t, "POST", "/api/watch", `{"url": "`+testArtist+`"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("watch status = %d: %s", status, body)
+	}
+	var artist services.WatchedArtist
+	if err := json.Unmarshal([]byte(body), &artist); err != nil {
+		t.Fatal(err)
+	}
+	if !artist.AutoDownload || artist.Format != "flac" {
+		t.Errorf("watched %+v, want autoDownload and flac by default", artist)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/watch", `{"url": "` + testArtist + `"}`, http.StatusBadRequest}, // Already watched
+		{"POST", "/api/watch", `{"schedule": "0 * * * *"}`, http.StatusBadRequest},
+		{"POST", "/api/watch", `[]`, http.StatusBadRequest},
+		{"GET", "/api/watch", "", http.StatusOK},
+		{"POST", "/api/watch/" + artist.ID + "/check", "", http.StatusOK},
+		{"POST", "/api/watch/missing/check", "", http.StatusConflict},
+		{"GET", "/api/watch/report", "", http.StatusOK},
+		{"DELETE", "/api/watch/missing", "", http.StatusNotFound},
+		{"DELETE", "/api/watch/" + artist.ID, "", http.StatusNoContent},
+	} {
+		if status, body := ts.do(t, tc.method, tc.path, tc.body); status != tc.want {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, status, tc.want, body)
+		}
+	}
+}
+
+func TestScanRoutes(t *testing.T) {
+	ts := newTestServer(t, "")
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/scan", `{}`, http.StatusBadRequest},
+		{"POST", "/api/scan/stop", "", http.StatusConflict},
+		{"POST", "/api/scan", `{"url": "https://slow.bandcamp.com"}`, http.StatusAccepted},
+		{"POST", "/api/scan", `{"url": "https://slow.bandcamp.com"}`, http.StatusConflict},
+		{"POST", "/api/scan/stop", "", http.StatusAccepted},
+		{"GET", "/api/session", "", http.StatusOK}, // Logged out, so Bandcamp isn't asked
+		{"POST", "/api/session", "", http.StatusBadRequest},
+	} {
+		if status, body := ts.do(t, tc.method, tc.path, tc.body); status != tc.want {
+			t.Errorf("%s %s = %d, want %d: %s", tc.method, tc.path, status, tc.want, body)
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	ts := newTestServer(t, "secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// EventSource can't set headers, so the token goes in the query
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.url+"/api/events?token=secret", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET /api/events = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// The handler subscribes after sending the headers
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ts.hub.mu.Lock()
+		n := len(ts.hub.clients)
+		ts.hub.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client never subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ts.bus.Publish(events.ScanStarted{URL: testArtist})
+	lines := bufio.NewScanner(resp.Body)
+	var got []string
+	for len(got) < 2 && lines.Scan() {
+		if lines.Text() != "" {
+			got = append(got, lines.Text())
+		}
+	}
+	want := []string{"event: scan:start", `data: "` + testArtist + `"`}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("stream = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DataDir returns the directory where bcdl keeps its state files, creating it
+// if needed. BCDL_DATA_DIR overrides the per-user config location
+func DataDir() (string, error) {
+	dir := os.Getenv("BCDL_DATA_DIR")
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("could not locate config dir: %v", err)
+		}
+		dir = filepath.Join(configDir, "bcdl")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create data dir: %v", err)
+	}
+	return dir, nil
+}
+
+// writeFileAtomic replaces path with data without leaving a truncated file
+// behind if the process dies mid-write
+func writeFileAtomic(path string, data []byte) error {
+	return writeFileAtomicPerm(path, data, 0644)
+}
+
+// writeFileAtomicPerm is writeFileAtomic for files that need other permissions
+func writeFileAtomicPerm(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
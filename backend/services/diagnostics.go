@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"bcdl-app/backend/playwright"
+)
+
+// DefaultDiagnosticsKeep is how many diagnostics bundles are kept by default
+const DefaultDiagnosticsKeep = 20
+
+// DiagnosticsOptionsFromEnv saves diagnostics bundles into the diagnostics
+// folder of the data dir, or BCDL_DIAGNOSTICS_DIR ("off" for none), keeping
+// the newest BCDL_DIAGNOSTICS_KEEP. BCDL_TRACE=true adds Playwright traces
+func DiagnosticsOptionsFromEnv() playwright.DiagnosticsOptions {
+	opts := playwright.DiagnosticsOptions{
+		Dir:  os.Getenv("BCDL_DIAGNOSTICS_DIR"),
+		Keep: DefaultDiagnosticsKeep,
+	}
+	switch opts.Dir {
+	case "off":
+		return playwright.DiagnosticsOptions{}
+	case "":
+		if dir, err := DataDir(); err == nil {
+			opts.Dir = filepath.Join(dir, "diagnostics")
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("BCDL_DIAGNOSTICS_KEEP")); err == nil && n >= 0 {
+		opts.Keep = n
+	}
+	if v, err := strconv.ParseBool(os.Getenv("BCDL_TRACE")); err == nil {
+		opts.Trace = v
+	}
+	return opts
+}
+
+// DiagnosedError is a failure whose diagnostics bundle was saved to Dir
+type DiagnosedError struct {
+	Err error
+	Dir string
+}
+
+func (e *DiagnosedError) Error() string {
+	return e.Err.Error() + " (diagnostics saved to " + e.Dir + ")"
+}
+
+func (e *DiagnosedError) Unwrap() error {
+	return e.Err
+}
+
+// DiagnosticsDir returns where the diagnostics of err were saved, or "" if
+// they weren't
+func DiagnosticsDir(err error) string {
+	var diagnosed *DiagnosedError
+	if errors.As(err, &diagnosed) {
+		return diagnosed.Dir
+	}
+	return ""
+}
+
+// saveDiagnostics saves the job page's diagnostics bundle for err and wraps
+// err with where it went. Cancellations and the outcomes a release simply
+// has, like being paid-only, are not worth a bundle
+func saveDiagnostics(ctx context.Context, job *playwright.JobPage, err error) error {
+	if err == nil || ctx.Err() != nil || DiagnosticsDir(err) != "" ||
+		errors.Is(err, ErrAlreadyDownloaded) || errors.Is(err, ErrPaidOnly) || errors.Is(err, ErrRegionBlocked) {
+		return err
+	}
+	dir, saveErr := job.SaveDiagnostics(err)
+	if saveErr != nil {
+		log.Printf("Diagnostics: %v", saveErr)
+		return err
+	}
+	if dir == "" {
+		return err
+	}
+	log.Printf("Diagnostics: Saved to %s", dir)
+	return &DiagnosedError{Err: err, Dir: dir}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosedError(t *testing.T) {
+	cause := selectorError("format selector not found (timeout)", nil)
+	err := fmt.Errorf("download failed: %w", &DiagnosedError{Err: cause, Dir: "/tmp/diagnostics/20261017-120000-download-1"})
+
+	if got := DiagnosticsDir(err); got != "/tmp/diagnostics/20261017-120000-download-1" {
+		t.Errorf("DiagnosticsDir = %q", got)
+	}
+	if !strings.Contains(err.Error(), "diagnostics saved to /tmp/diagnostics/20261017-120000-download-1") {
+		t.Errorf("error %q doesn't point at the bundle", err)
+	}
+	// The failure class shows through
+	if !errors.Is(err, ErrSelectorChanged) || ErrorKind(err) != "selector_changed" {
+		t.Errorf("ErrorKind = %q, want selector_changed", ErrorKind(err))
+	}
+	if got := DiagnosticsDir(cause); got != "" {
+		t.Errorf("DiagnosticsDir of an error without a bundle = %q", got)
+	}
+}
+
+func TestDiagnosticsOptionsFromEnv(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("BCDL_DATA_DIR", dataDir)
+	t.Setenv("BCDL_DIAGNOSTICS_DIR", "")
+	t.Setenv("BCDL_DIAGNOSTICS_KEEP", "")
+	t.Setenv("BCDL_TRACE", "")
+
+	opts := DiagnosticsOptionsFromEnv()
+	if opts.Dir != filepath.Join(dataDir, "diagnostics") || opts.Keep != DefaultDiagnosticsKeep || opts.Trace {
+		t.Errorf("defaults = %+v", opts)
+	}
+
+	t.Setenv("BCDL_DIAGNOSTICS_KEEP", "5")
+	t.Setenv("BCDL_TRACE", "true")
+	if opts := DiagnosticsOptionsFromEnv(); opts.Keep != 5 || !opts.Trace {
+		t.Errorf("with keep and trace set = %+v", opts)
+	}
+
+	t.Setenv("BCDL_DIAGNOSTICS_DIR", "off")
+	if opts := DiagnosticsOptionsFromEnv(); opts.Dir != "" || opts.Trace {
+		t.Errorf("turned off = %+v", opts)
+	}
+}
@@ -1,10 +1,17 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"bcdl-app/backend/playwright"
@@ -15,72 +22,279 @@ import (
 type DownloaderService struct {
 	pwService    *playwright.Service
 	tempEmailSvc *TempEmailService
+	client       *http.Client // Fetches the final file so its progress can be reported
+	retry        RetryPolicy  // For navigation, mail polling and the file transfer
+	profiles     *SiteProfileStore
+
+	mu          sync.Mutex
+	postProcess PostProcessOptions
+	history     *DownloadHistory
+	purchases   PurchaseFinder
 }
 
 func NewDownloaderService(pwService *playwright.Service) *DownloaderService {
 	return &DownloaderService{
 		pwService:    pwService,
 		tempEmailSvc: NewTempEmailService(),
+		client:       &http.Client{},
+		retry:        RetryPolicyFromEnv(),
+		profiles:     NewSiteProfileStoreFromEnv(),
+		postProcess:  PostProcessOptionsFromEnv(),
 	}
 }
 
-// ProgressCallback is a function that receives progress updates
-type ProgressCallback func(message string)
+// SetRetryPolicy sets how failed steps of a download are retried. Call it
+// before downloads start
+func (s *DownloaderService) SetRetryPolicy(policy RetryPolicy) {
+	s.retry = policy
+	s.tempEmailSvc.SetRetryPolicy(policy)
+}
+
+// SetTempEmailService replaces the mailbox providers used for email-gated
+// downloads. Call it before downloads start
+func (s *DownloaderService) SetTempEmailService(svc *TempEmailService) {
+	svc.SetRetryPolicy(s.retry)
+	s.tempEmailSvc = svc
+}
+
+// SetSiteProfiles sets where the selectors and timeouts of the download flow
+// come from. Call it before downloads start
+func (s *DownloaderService) SetSiteProfiles(profiles *SiteProfileStore) {
+	s.profiles = profiles
+}
+
+// SetPostProcess sets how downloads are organized once saved. It applies to
+// downloads started afterwards
+func (s *DownloaderService) SetPostProcess(opts PostProcessOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.postProcess = opts
+}
+
+// SetHistory makes the downloader record completed downloads in history and
+// skip albums it already has in the requested format
+func (s *DownloaderService) SetHistory(history *DownloadHistory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = history
+}
+
+// SetPurchases lets the downloader fetch releases the logged-in fan owns from
+// their collection's download pages
+func (s *DownloaderService) SetPurchases(purchases PurchaseFinder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purchases = purchases
+}
 
-func (s *DownloaderService) DownloadAlbum(url string, downloadDir string, format string, progress ProgressCallback) error {
+// PostProcess returns the current post-processing options
+func (s *DownloaderService) PostProcess() PostProcessOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.postProcess
+}
+
+// DownloadOptions are the settings of a single download
+type DownloadOptions struct {
+	PostProcess   *PostProcessOptions // Templates left empty fall back to the global ones
+	IgnoreHistory bool                // Download even if the history has it, replacing its entry
+}
+
+// DownloadAlbum downloads an album into downloadDir using the global
+// post-processing options. Cancelling ctx closes the page, which aborts
+// whatever step is in progress, and returns ctx.Err()
+func (s *DownloaderService) DownloadAlbum(ctx context.Context, url string, downloadDir string, format string, onProgress ProgressCallback) error {
+	return s.DownloadAlbumWithOptions(ctx, url, downloadDir, format, DownloadOptions{}, onProgress)
+}
+
+// DownloadAlbumWithOptions is DownloadAlbum with options for this download only
+func (s *DownloaderService) DownloadAlbumWithOptions(ctx context.Context, url string, downloadDir string, format string, opts DownloadOptions, onProgress ProgressCallback) (err error) {
+	progress := newProgressReporter(onProgress)
 	log.Printf("Downloader: Starting download for: %s", url)
-	progress(fmt.Sprintf("Starting download for: %s", url))
+	progress.Phase(PhaseNavigating, fmt.Sprintf("Starting download for: %s", url))
 
-	page, err := s.pwService.NewPage()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	history := s.history
+	purchases := s.purchases
+	s.mu.Unlock()
+	known := history // Where the flow looks for earlier downloads
+	if opts.IgnoreHistory {
+		known = nil
+	}
+	if err := checkHistory(known, url, 0, format, progress); err != nil {
+		return err
+	}
+
+	job, err := s.pwService.NewJobPage(ctx, "download", playwright.LeaseOptions{})
 	if err != nil {
 		return err
 	}
-	defer page.Close()
+	defer job.Close()
+	page := job.Page
+
+	stop := context.AfterFunc(ctx, func() { page.Close() })
+	defer stop()
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		err = saveDiagnostics(ctx, job, err)
+	}()
 
+	postOpts := s.PostProcess()
+	if opts.PostProcess != nil {
+		postOpts = opts.PostProcess.withDefaults(postOpts)
+	}
+	if err := postOpts.Validate(); err != nil {
+		return err
+	}
+
+	info := ReleaseInfo{}
+	profile := s.profiles.Current()
+	var savedPath string
+	if isPurchaseDownloadPage(url) {
+		savedPath, err = s.runPurchaseFlow(ctx, page, profile, url, downloadDir, format, &info, known, progress)
+	} else {
+		savedPath, err = s.runFlow(ctx, page, profile, url, downloadDir, format, &info, known, purchases, progress)
+	}
+	if err != nil {
+		return err
+	}
+
+	checksum, size, err := fileChecksum(savedPath)
+	if err != nil {
+		return fmt.Errorf("failed to read saved file: %v", err)
+	}
+
+	finalPath, err := postProcess(ctx, savedPath, downloadDir, format, info, postOpts, progress)
+	if err != nil {
+		return err
+	}
+
+	if history != nil {
+		if err := history.Record(HistoryEntry{
+			URL:      url,
+			AlbumID:  info.ID,
+			Artist:   info.Artist,
+			Title:    info.Album,
+			Format:   format,
+			Path:     finalPath,
+			Size:     size,
+			Checksum: checksum,
+		}); err != nil {
+			log.Printf("Downloader: Failed to record %s in history: %v", url, err)
+		}
+	}
+	progress.Message("Download complete!")
+	return nil
+}
+
+// checkHistory returns ErrAlreadyDownloaded if history has the album in format
+func checkHistory(history *DownloadHistory, url string, albumID int64, format string, progress *progressReporter) error {
+	if history == nil {
+		return nil
+	}
+	entry, ok := history.Lookup(url, albumID, format)
+	if !ok {
+		return nil
+	}
+	progress.Message(fmt.Sprintf("Already downloaded to %s, skipping", entry.Path))
+	return fmt.Errorf("%w to %s", ErrAlreadyDownloaded, entry.Path)
+}
+
+// runFlow walks the album page through to the saved file, filling in info
+// along the way, and returns where the file was saved
+func (s *DownloaderService) runFlow(ctx context.Context, page pw.Page, profile *SiteProfile, url string, downloadDir string, format string, info *ReleaseInfo, history *DownloadHistory, purchases PurchaseFinder, progress *progressReporter) (string, error) {
 	// Navigate to album page
-	if _, err := page.Goto(url, pw.PageGotoOptions{
+	if err := gotoPage(ctx, s.retry, page, url, pw.PageGotoOptions{
 		WaitUntil: pw.WaitUntilStateDomcontentloaded, // Relaxed from Networkidle
 	}); err != nil {
-		return fmt.Errorf("failed to navigate: %v", err)
+		return "", fmt.Errorf("failed to navigate: %w", err)
+	}
+	steps := profile.Download
+	if steps.RegionBlocked.count(page) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrRegionBlocked, url)
 	}
 
 	// Handle Cookie Banner (Critical for interaction)
-	// We use a more generic selector to catch the banner container or button
 	log.Printf("Downloader: Checking for cookie banner...")
-	cookieBtn := page.Locator("#onetrust-accept-btn-handler").First() // Try ID first (common on Bandcamp)
-	if count, _ := cookieBtn.Count(); count == 0 {
-		// Fallback to text search if ID not found
-		cookieBtn = page.Locator("button").Filter(pw.LocatorFilterOptions{HasText: "Accept all"}).First()
-	}
-
-	if err := cookieBtn.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(5000)}); err == nil {
-		progress("Found cookie banner, clicking 'Accept all'...")
+	if cookieBtn, err := steps.CookieAccept.locate(page); err == nil {
+		progress.Message("Found cookie banner, clicking 'Accept all'...")
 		log.Printf("Downloader: Clicking cookie button...")
 		if err := cookieBtn.Click(); err != nil {
 			log.Printf("Downloader: Failed to click cookie button: %v", err)
 		}
 
 		// Wait for banner to disappear/page to update
-		time.Sleep(1 * time.Second)
+		if err := sleepContext(ctx, time.Duration(steps.CookieSettle)); err != nil {
+			return "", err
+		}
 	} else {
 		log.Printf("Downloader: Cookie banner not found (timeout)")
 	}
 
 	// Get title
-	titleEl := page.Locator("h2.trackTitle").First()
-	if err := titleEl.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(10000)}); err != nil {
+	title := ""
+	if titleEl, err := steps.Title.locate(page); err != nil {
 		log.Printf("Downloader: Title element not found: %v", err)
+	} else {
+		title, _ = titleEl.InnerText()
+		title = strings.TrimSpace(title)
 	}
-	title, _ := titleEl.InnerText()
-	title = strings.TrimSpace(title)
 	log.Printf("Downloader: Processing album: %s", title)
-	progress(fmt.Sprintf("Processing album: %s", title))
+	progress.Message(fmt.Sprintf("Processing album: %s", title))
+	info.Album = title
 
 	// 1. Direct link check (optimization)
 	log.Printf("Downloader: Checking for direct download link...")
-	progress("Checking for direct download link...")
+	progress.Message("Checking for direct download link...")
+	status := "" // From data-tralbum, to tell paid releases from a changed layout
 	tralbumData, err := page.Locator("script[data-tralbum]").GetAttribute("data-tralbum")
 	if err == nil && tralbumData != "" {
+		if tralbum, err := parseTralbum(tralbumData); err == nil {
+			status = tralbum.Status()
+			info.ID = tralbum.ID
+			info.Artist = tralbum.Artist
+			info.Year = tralbum.ReleaseYear()
+			info.Tracks = tralbum.Tracks()
+			if tralbum.Current.Title != "" {
+				info.Album = tralbum.Current.Title
+			}
+		}
+		// The same album may have been downloaded through another URL, e.g. a custom domain
+		if info.ID != 0 {
+			if err := checkHistory(history, "", info.ID, format, progress); err != nil {
+				return "", err
+			}
+		}
+		ldJSON := page.Locator(`script[type="application/ld+json"]`).First()
+		if count, _ := ldJSON.Count(); count > 0 {
+			if raw, err := ldJSON.TextContent(); err == nil {
+				if data, err := parseLDJSON(raw); err == nil {
+					info.Label = data.Label()
+					info.Catalog = data.CatalogNumber()
+				}
+			}
+		}
+
+		// Albums the fan bought are fetched from their collection's download page
+		if purchases != nil {
+			downloadPage, err := purchases.PurchaseDownloadPage(ctx, url, info.ID)
+			if err != nil {
+				log.Printf("Downloader: Could not look up purchases: %v", err)
+			} else if downloadPage != "" {
+				progress.Message("Found album in your collection, opening its download page...")
+				if err := gotoPage(ctx, s.retry, page, downloadPage, pw.PageGotoOptions{}); err != nil {
+					return "", fmt.Errorf("failed to navigate to purchase download page: %w", err)
+				}
+				return s.handleDownloadPage(ctx, page, profile, downloadDir, format, progress)
+			}
+		}
+
 		// Simple string check to avoid full JSON parsing if possible, or use Evaluate for robust check
 		isFree, _ := page.Evaluate(`() => {
 			try {
@@ -90,70 +304,54 @@ func (s *DownloaderService) DownloadAlbum(url string, downloadDir string, format
 		}`)
 
 		if freePage, ok := isFree.(string); ok && freePage != "" {
-			progress("Found direct download link, skipping payment flow...")
-			if _, err := page.Goto(freePage); err != nil {
-				return fmt.Errorf("failed to navigate to free download page: %v", err)
+			progress.Message("Found direct download link, skipping payment flow...")
+			if err := gotoPage(ctx, s.retry, page, freePage, pw.PageGotoOptions{}); err != nil {
+				return "", fmt.Errorf("failed to navigate to free download page: %w", err)
 			}
-			return s.handleDownloadPage(page, downloadDir, format, progress)
+			return s.handleDownloadPage(ctx, page, profile, downloadDir, format, progress)
 		}
 	}
-	progress("No direct link found, proceeding with buy button...")
+	progress.Message("No direct link found, proceeding with buy button...")
 
-	// 2. Buy/Free button interaction
-	// Python uses regex: Buy|Free|Download. We'll use a broader selector and check visibility.
+	// 2. Buy/Free button interaction, trying the profile's selectors in order
 	log.Printf("Downloader: Looking for buy/download button...")
-	progress("Looking for buy/download button...")
-
-	// Try primary selector (works for most albums) - removed "button" tag constraint to support <a> tags
-	buyBtn := page.Locator("h4.ft.compound-button .download-link").First()
-
-	// Check if button exists and is visible
-	if err := buyBtn.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(3000)}); err != nil {
-		log.Printf("Downloader: Primary buy button selector failed, trying fallback...")
-
-		// Fallback: Look for "Buy Digital Album" or "name your price" text
-		buyBtn = page.Locator("text=Buy Digital Album").First()
-		if err := buyBtn.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(3000)}); err != nil {
-			buyBtn = page.Locator("text=name your price").First()
-			if err := buyBtn.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(3000)}); err != nil {
-				log.Printf("Downloader: No download button found (all selectors failed)")
-				progress("No download button found")
-				return fmt.Errorf("no download button found")
-			}
-		}
+	progress.Message("Looking for buy/download button...")
+	buyBtn, err := steps.BuyButton.locate(page)
+	if err != nil {
+		log.Printf("Downloader: No download button found (all selectors failed)")
+		progress.Message("No download button found")
+		return "", buyFlowError(status, "no download button found")
 	}
 	log.Printf("Downloader: Found buy/download button")
-	progress("Found buy/download button")
+	progress.Message("Found buy/download button")
 
-	progress("Clicking buy/download button...")
+	progress.Message("Clicking buy/download button...")
 	if err := buyBtn.Click(pw.LocatorClickOptions{Force: pw.Bool(true)}); err != nil {
-		return fmt.Errorf("failed to click buy button: %v", err)
+		return "", fmt.Errorf("failed to click buy button: %v", err)
 	}
-	progress("Buy button clicked, checking for price input...")
+	progress.Message("Buy button clicked, checking for price input...")
 
 	// 3. Price input (Name Your Price)
-	priceInput := page.Locator("input#userPrice")
 	log.Printf("Downloader: Waiting for price input field...")
-	progress("Waiting for price input field...")
-	if err := priceInput.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(5000)}); err == nil {
+	progress.Message("Waiting for price input field...")
+	if priceInput, err := steps.PriceInput.locate(page); err == nil {
 		log.Printf("Downloader: Price input found, setting to 0...")
-		progress("Price input found, setting to 0...")
+		progress.Message("Price input found, setting to 0...")
 		if err := priceInput.Fill("0"); err != nil {
-			return fmt.Errorf("failed to set price: %v", err)
+			return "", fmt.Errorf("failed to set price: %v", err)
 		}
 
 		// Click "download to your computer" link
 		// This link appears after typing 0
-		progress("Looking for 'download to your computer' link...")
-		downloadLink := page.Locator("a.download-panel-free-download-link")
-		if err := downloadLink.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(5000)}); err == nil {
-			progress("Found download link, clicking...")
+		progress.Message("Looking for 'download to your computer' link...")
+		if downloadLink, err := steps.FreeDownloadLink.locate(page); err == nil {
+			progress.Message("Found download link, clicking...")
 			if err := downloadLink.Click(pw.LocatorClickOptions{Force: pw.Bool(true)}); err != nil {
-				return fmt.Errorf("failed to click free download link: %v", err)
+				return "", fmt.Errorf("failed to click free download link: %v", err)
 			}
 
 			// Wait for page to load
-			progress("Waiting for page to load after clicking download link...")
+			progress.Message("Waiting for page to load after clicking download link...")
 			page.WaitForLoadState(pw.PageWaitForLoadStateOptions{
 				State: pw.LoadStateNetworkidle,
 			})
@@ -161,122 +359,218 @@ func (s *DownloaderService) DownloadAlbum(url string, downloadDir string, format
 			// Check if email form appeared FIRST (URL might not change!)
 			currentURL := page.URL()
 			log.Printf("Downloader: Current URL after click: %s", currentURL)
-			progress(fmt.Sprintf("Current URL after click: %s", currentURL))
+			progress.Message(fmt.Sprintf("Current URL after click: %s", currentURL))
 
-			emailInputCount, _ := page.Locator("input#fan_email_address").Count()
+			emailInputCount := steps.EmailInput.count(page)
 			log.Printf("Downloader: Email input count: %d", emailInputCount)
 
 			if emailInputCount > 0 {
-				progress(fmt.Sprintf("Email form detected (%d inputs found)", emailInputCount))
+				progress.Message(fmt.Sprintf("Email form detected (%d inputs found)", emailInputCount))
 				log.Printf("Downloader: Email form detected, starting temp email flow")
-				return s.handleEmailFlow(page, downloadDir, format, progress)
+				return s.handleEmailFlow(ctx, page, profile, downloadDir, format, progress)
 			} else if strings.Contains(currentURL, "download") {
-				progress("URL contains 'download' - proceeding to download page")
+				progress.Message("URL contains 'download' - proceeding to download page")
 				log.Printf("Downloader: URL contains 'download', proceeding to download page")
 				// Continue to download page handling
 			} else {
-				progress("No download page or email form detected - unexpected state")
+				progress.Message("No download page or email form detected - unexpected state")
 				log.Printf("Downloader: Unexpected state - no email form and URL doesn't contain 'download'")
 			}
 		} else {
 			// Check if email form is visible (alternative flow)
-			if count, _ := page.Locator("input#fan_email_address").Count(); count > 0 {
-				progress("Email required - using temp email flow...")
-				return s.handleEmailFlow(page, downloadDir, format, progress)
+			if steps.EmailInput.count(page) > 0 {
+				progress.Message("Email required - using temp email flow...")
+				return s.handleEmailFlow(ctx, page, profile, downloadDir, format, progress)
 			}
-			return fmt.Errorf("free download link not found after setting price")
+			return "", buyFlowError(status, "free download link not found after setting price")
 		}
 	}
 
 	// 4. Handle actual download page
-	return s.handleDownloadPage(page, downloadDir, format, progress)
+	return s.handleDownloadPage(ctx, page, profile, downloadDir, format, progress)
+}
+
+// buyFlowError explains why the buy flow found no way to download: releases
+// data-tralbum says are paid need buying, anything else suggests the page
+// changed
+func buyFlowError(status string, what string) error {
+	if status == "paid" {
+		return fmt.Errorf("%w: %s", ErrPaidOnly, what)
+	}
+	return selectorError(what, nil)
+}
+
+// runPurchaseFlow downloads from a download page of the fan's collection,
+// reading the release's details from the page itself
+func (s *DownloaderService) runPurchaseFlow(ctx context.Context, page pw.Page, profile *SiteProfile, url string, downloadDir string, format string, info *ReleaseInfo, history *DownloadHistory, progress *progressReporter) (string, error) {
+	if err := gotoPage(ctx, s.retry, page, url, pw.PageGotoOptions{
+		WaitUntil: pw.WaitUntilStateDomcontentloaded,
+	}); err != nil {
+		return "", fmt.Errorf("failed to navigate: %w", err)
+	}
+	if strings.Contains(page.URL(), "/login") {
+		return "", fmt.Errorf("download page requires logging in to Bandcamp")
+	}
+
+	item, _ := page.Evaluate(`() => {
+		try {
+			const data = JSON.parse(document.querySelector("#pagedata").getAttribute("data-blob"));
+			const item = (data.download_items || [])[0];
+			return item ? {id: item.item_id || item.tralbum_id || 0, title: item.title || "", artist: item.artist || ""} : null;
+		} catch (e) { return null; }
+	}`)
+	if item, ok := item.(map[string]interface{}); ok {
+		info.Album, _ = item["title"].(string)
+		info.Artist, _ = item["artist"].(string)
+		switch id := item["id"].(type) {
+		case int:
+			info.ID = int64(id)
+		case float64:
+			info.ID = int64(id)
+		}
+	}
+	if info.Album != "" {
+		progress.Message(fmt.Sprintf("Processing album: %s", info.Album))
+	}
+	if info.ID != 0 {
+		if err := checkHistory(history, "", info.ID, format, progress); err != nil {
+			return "", err
+		}
+	}
+
+	return s.handleDownloadPage(ctx, page, profile, downloadDir, format, progress)
 }
 
-// handleEmailFlow handles the temp email verification flow
-func (s *DownloaderService) handleEmailFlow(page pw.Page, downloadDir string, format string, progress ProgressCallback) error {
+// handleEmailFlow handles the temp email verification flow. Mailbox providers
+// are tried in order until Bandcamp accepts one of their addresses
+func (s *DownloaderService) handleEmailFlow(ctx context.Context, page pw.Page, profile *SiteProfile, downloadDir string, format string, progress *progressReporter) (string, error) {
+	var tempEmail string
+	var errs []string
+	for _, provider := range s.tempEmailSvc.Providers() {
+		email, err := s.submitEmailForm(ctx, page, profile, provider, progress)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Downloader: Mail provider %s failed: %v", provider.Name(), err)
+			progress.Message(fmt.Sprintf("Mail provider %s failed, trying next...", provider.Name()))
+			errs = append(errs, err.Error())
+			continue
+		}
+		tempEmail = email
+		break
+	}
+	if tempEmail == "" {
+		if len(errs) == 0 {
+			return "", fmt.Errorf("no mail providers configured")
+		}
+		return "", fmt.Errorf("failed to submit email form: %s", strings.Join(errs, "; "))
+	}
+
+	// Wait for download email, by default checking every 5 seconds for 2 minutes
+	progress.Phase(PhaseEmailWait, fmt.Sprintf("Waiting for download email at %s...", tempEmail))
+	downloadLink, err := s.tempEmailSvc.WaitForDownloadEmail(ctx, tempEmail, profile.Download.EmailPolls, time.Duration(profile.Download.EmailPollInterval))
+	if err != nil {
+		return "", fmt.Errorf("failed to receive download email: %w", err)
+	}
+
+	progress.Phase(PhaseNavigating, fmt.Sprintf("Received download link: %s", downloadLink))
+
+	// Navigate to download link
+	if err := gotoPage(ctx, s.retry, page, downloadLink, pw.PageGotoOptions{
+		WaitUntil: pw.WaitUntilStateNetworkidle,
+	}); err != nil {
+		return "", fmt.Errorf("failed to navigate to download link: %w", err)
+	}
+
+	// Continue with normal download flow
+	return s.handleDownloadPage(ctx, page, profile, downloadDir, format, progress)
+}
+
+// submitEmailForm fills the email form with an address from provider. An
+// address Bandcamp refuses leaves the form open, which is reported as an error
+func (s *DownloaderService) submitEmailForm(ctx context.Context, page pw.Page, profile *SiteProfile, provider MailboxProvider, progress *progressReporter) (string, error) {
 	// Generate temp email
-	tempEmail, err := s.tempEmailSvc.GenerateTempEmail()
+	tempEmail, err := s.tempEmailSvc.NewAddress(ctx, provider)
 	if err != nil {
-		return fmt.Errorf("failed to generate temp email: %v", err)
+		return "", fmt.Errorf("failed to generate temp email: %v", err)
 	}
-	progress(fmt.Sprintf("Generated temp email: %s", tempEmail))
+	progress.Message(fmt.Sprintf("Generated temp email: %s", tempEmail))
 
+	if err := fillEmailForm(page, profile, tempEmail, progress); err != nil {
+		s.tempEmailSvc.ReleaseAddress(tempEmail)
+		return "", err
+	}
+	return tempEmail, nil
+}
+
+// fillEmailForm enters tempEmail in the email form and submits it
+func fillEmailForm(page pw.Page, profile *SiteProfile, tempEmail string, progress *progressReporter) error {
 	// Fill email form
-	emailInput := page.Locator("input#fan_email_address")
+	steps := profile.Download
+	emailInput, err := steps.EmailInput.locate(page)
+	if err != nil {
+		return selectorError("email input not found", err)
+	}
 	if err := emailInput.Fill(tempEmail); err != nil {
 		return fmt.Errorf("failed to fill email: %v", err)
 	}
 
-	// Fill ZIP code (using a generic US ZIP)
-	zipInput := page.Locator("input[name='postcode'], input.postcode")
-	if err := zipInput.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(3000)}); err == nil {
-		progress("Filling ZIP code...")
-		zipInput.Fill("10001")
+	// Fill ZIP code (a generic US ZIP by default)
+	if zipInput, err := steps.PostcodeInput.locate(page); err == nil {
+		progress.Message("Filling ZIP code...")
+		zipInput.Fill(steps.Postcode)
 	}
 
 	// Click OK button
-	okBtn := page.Locator("button").Filter(pw.LocatorFilterOptions{HasText: "OK"}).First()
-	if err := okBtn.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(5000)}); err != nil {
-		return fmt.Errorf("OK button not found: %v", err)
+	okBtn, err := steps.EmailSubmit.locate(page)
+	if err != nil {
+		return selectorError("OK button not found", err)
 	}
 
-	progress("Submitting email form...")
+	progress.Message("Submitting email form...")
 	if err := okBtn.Click(pw.LocatorClickOptions{Force: pw.Bool(true)}); err != nil {
 		return fmt.Errorf("failed to click OK button: %v", err)
 	}
 
-	// Wait for download email (max 120 seconds, check every 5 seconds)
-	downloadLink, err := s.tempEmailSvc.WaitForDownloadEmail(tempEmail, 24, 5)
-	if err != nil {
-		return fmt.Errorf("failed to receive download email: %v", err)
-	}
-
-	progress(fmt.Sprintf("Received download link: %s", downloadLink))
-
-	// Navigate to download link
-	if _, err := page.Goto(downloadLink, pw.PageGotoOptions{
-		WaitUntil: pw.WaitUntilStateNetworkidle,
+	// The form is replaced by a "check your email" message once accepted
+	if err := emailInput.WaitFor(pw.LocatorWaitForOptions{
+		State:   pw.WaitForSelectorStateHidden,
+		Timeout: steps.EmailInput.timeoutMS(),
 	}); err != nil {
-		return fmt.Errorf("failed to navigate to download link: %v", err)
+		return fmt.Errorf("email address %s was not accepted", tempEmail)
 	}
-
-	// Continue with normal download flow
-	return s.handleDownloadPage(page, downloadDir, format, progress)
+	return nil
 }
 
-func (s *DownloaderService) handleDownloadPage(page pw.Page, downloadDir string, format string, progress ProgressCallback) error {
-	progress("Waiting for download page...")
+func (s *DownloaderService) handleDownloadPage(ctx context.Context, page pw.Page, profile *SiteProfile, downloadDir string, format string, progress *progressReporter) (string, error) {
+	progress.Phase(PhasePreparing, "Waiting for download page...")
 
 	// Wait for format selector
-	formatDropdown := page.Locator("#format-type, .format-type, .formats").First()
-	if err := formatDropdown.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(20000)}); err != nil {
-		return fmt.Errorf("format selector not found (timeout): %v", err)
+	formatDropdown, err := profile.Download.FormatSelect.locate(page)
+	if err != nil {
+		return "", selectorError("format selector not found (timeout)", err)
 	}
 
-	// Select format
-	tagName, _ := formatDropdown.Evaluate("el => el.tagName", nil)
-	if tagName == "SELECT" {
-		if _, err := formatDropdown.SelectOption(pw.SelectOptionValues{
-			Values: pw.StringSlice(strings.ToLower(format)),
-		}); err != nil {
-			// Fallback to MP3 320 if FLAC not found or error
-			progress("Requested format not found, trying MP3 320...")
-			formatDropdown.SelectOption(pw.SelectOptionValues{
-				Values: pw.StringSlice("mp3-320"),
-			})
-		}
-	} else {
-		// Custom dropdown
-		formatDropdown.Click()
-		page.Locator("li").Filter(pw.LocatorFilterOptions{HasText: format}).Click()
+	// Select format, or the profile's fallback if it isn't offered
+	selected := format
+	err = selectFormat(page, profile, formatDropdown, format)
+	fallback := profile.Download.FallbackFormat
+	if errors.Is(err, ErrSelectorChanged) && fallback != "" && !strings.EqualFold(fallback, format) {
+		progress.Message(fmt.Sprintf("Requested format not found, trying %s...", fallback))
+		selected = fallback
+		err = selectFormat(page, profile, formatDropdown, fallback)
 	}
-	progress(fmt.Sprintf("Selected format: %s", format))
+	if err != nil {
+		return "", err
+	}
+	progress.Message(fmt.Sprintf("Selected format: %s", selected))
 
 	// Find Download button
-	downloadBtn := page.Locator(".download-item-container a").Filter(pw.LocatorFilterOptions{HasText: "Download"}).First()
-	progress("Preparing download...")
-	if err := downloadBtn.WaitFor(pw.LocatorWaitForOptions{Timeout: pw.Float(60000)}); err != nil {
-		return fmt.Errorf("download button timeout: %v", err)
+	progress.Message("Preparing download...")
+	downloadBtn, err := profile.Download.DownloadButton.locate(page)
+	if err != nil {
+		return "", selectorError("download button timeout", err)
 	}
 
 	// Handle download
@@ -284,18 +578,130 @@ func (s *DownloaderService) handleDownloadPage(page pw.Page, downloadDir string,
 		return downloadBtn.Click()
 	})
 	if err != nil {
-		return fmt.Errorf("download failed to start: %v", err)
+		return "", fmt.Errorf("download failed to start: %v", err)
 	}
 
 	// Save file
 	suggestedFilename := download.SuggestedFilename()
-	savePath := filepath.Join(downloadDir, suggestedFilename)
+	savePath := uniquePath(filepath.Join(downloadDir, sanitizeFileName(suggestedFilename)))
+
+	progress.Phase(PhaseTransferring, fmt.Sprintf("Saving to: %s", savePath))
+	fetched := false
+	err = s.retry.Do(ctx, "downloading "+suggestedFilename, func() error {
+		resp, err := s.openDownload(ctx, page, download.URL())
+		if err != nil {
+			return err
+		}
+		if !fetched {
+			fetched = true
+			download.Cancel()
+		}
+		if err := saveResponse(resp, savePath, progress.fn); err != nil {
+			var pathErr *fs.PathError
+			if errors.As(err, &pathErr) {
+				return err // Disk errors won't go away on a retry
+			}
+			return networkError(ctx, err)
+		}
+		return nil
+	})
+	if !fetched && ctx.Err() == nil {
+		// Let the browser finish it instead, without byte-level progress
+		log.Printf("Downloader: Could not fetch %s directly, saving through the browser: %v", download.URL(), err)
+		stop := context.AfterFunc(ctx, func() { download.Cancel() })
+		defer stop()
+		if err := download.SaveAs(savePath); err != nil {
+			os.Remove(savePath)
+			return "", fmt.Errorf("failed to save file: %w", networkError(ctx, err))
+		}
+		return savePath, nil
+	}
+	if !fetched {
+		download.Cancel() // Cancelled before the transfer started
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
 
-	progress(fmt.Sprintf("Saving to: %s", savePath))
-	if err := download.SaveAs(savePath); err != nil {
-		return fmt.Errorf("failed to save file: %v", err)
+	return savePath, nil
+}
+
+// selectFormat picks format in the download page's format selector, either a
+// <select> or a custom dropdown of FormatOption entries
+func selectFormat(page pw.Page, profile *SiteProfile, dropdown pw.Locator, format string) error {
+	steps := profile.Download
+	tagName, _ := dropdown.Evaluate("el => el.tagName", nil)
+	if tagName == "SELECT" {
+		if _, err := dropdown.SelectOption(pw.SelectOptionValues{
+			Values: pw.StringSlice(strings.ToLower(format)),
+		}, pw.LocatorSelectOptionOptions{Timeout: steps.FormatOption.timeoutMS()}); err != nil {
+			return selectorError(fmt.Sprintf("format %s not offered", format), err)
+		}
+		return nil
 	}
 
-	progress("Download complete!")
+	if err := dropdown.Click(); err != nil {
+		return fmt.Errorf("failed to open format selector: %v", err)
+	}
+	option, err := steps.FormatOption.locateText(page, format)
+	if err != nil {
+		return selectorError(fmt.Sprintf("format %s not offered", format), err)
+	}
+	if err := option.Click(); err != nil {
+		return fmt.Errorf("failed to select format %s: %v", format, err)
+	}
 	return nil
 }
+
+// openDownload requests the file the browser started downloading, with the
+// page's cookies, so the transfer can be streamed and measured
+func (s *DownloaderService) openDownload(ctx context.Context, page pw.Page, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", playwright.UserAgent)
+
+	cookies, err := page.Context().Cookies(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %v", err)
+	}
+	for _, c := range cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, networkError(ctx, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, newHTTPError(resp, url)
+	}
+	return resp, nil
+}
+
+// saveResponse streams resp into path through a ".part" file, reporting
+// transfer progress as it goes
+func saveResponse(resp *http.Response, path string, report ProgressCallback) error {
+	defer resp.Body.Close()
+
+	partPath := path + ".part"
+	f, err := os.Create(partPath)
+	if err != nil {
+		return err
+	}
+
+	meter := newTransferMeter(resp.ContentLength, report)
+	if _, err := io.Copy(io.MultiWriter(f, meter), resp.Body); err != nil {
+		f.Close()
+		os.Remove(partPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(partPath)
+		return err
+	}
+	meter.Finish()
+	return os.Rename(partPath, path)
+}
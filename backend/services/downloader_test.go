@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcdl-app/backend/playwright"
+)
+
+// fixtureArchive is the file name the fixture download page serves
+const fixtureArchive = "Fixture Artist - Fixture Album.zip"
+
+func TestDownloadAlbumFlows(t *testing.T) {
+	browser := newTestBrowser(t)
+	mail := newFixtureMail(t)
+	site := newFixtureSite(t, mail)
+
+	for _, tc := range []struct {
+		name string
+		path string
+	}{
+		{"free", "/album/free-album"},
+		{"name your price", "/album/nyp-album"},
+		{"email", "/album/email-album"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			emailSvc := NewTempEmailService(NewMailTMProvider("mailtm", mail.URL()))
+			emailSvc.SetDownloadBaseURL(site.URL())
+			downloader := NewDownloaderService(browser)
+			downloader.SetRetryPolicy(noRetry)
+			downloader.SetTempEmailService(emailSvc)
+			downloader.SetPostProcess(PostProcessOptions{})
+			history := NewDownloadHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+			downloader.SetHistory(history)
+
+			dir := t.TempDir()
+			if err := downloader.DownloadAlbum(context.Background(), site.URL()+tc.path, dir, "flac", nil); err != nil {
+				t.Fatalf("DownloadAlbum: %v", err)
+			}
+
+			want, err := os.ReadFile(filepath.Join(siteFixtures, "album.zip"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, fixtureArchive))
+			if err != nil {
+				t.Fatalf("archive not saved: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("saved archive differs from the served one")
+			}
+			if entries := history.Entries(); len(entries) != 1 || entries[0].Artist != "Fixture Artist" {
+				t.Errorf("history = %+v, want one entry by Fixture Artist", entries)
+			}
+		})
+	}
+
+	if accounts := mail.Accounts(); len(accounts) != 1 {
+		t.Errorf("created %d mail accounts, want 1 for the email-gated album", len(accounts))
+	}
+}
+
+func TestDownloadAlbumIgnoreHistory(t *testing.T) {
+	browser := newTestBrowser(t)
+	site := newFixtureSite(t, nil)
+	downloader := NewDownloaderService(browser)
+	downloader.SetRetryPolicy(noRetry)
+	downloader.SetPostProcess(PostProcessOptions{})
+	history := NewDownloadHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	downloader.SetHistory(history)
+	url := site.URL() + "/album/free-album"
+	ctx := context.Background()
+
+	if err := downloader.DownloadAlbum(ctx, url, t.TempDir(), "flac", nil); err != nil {
+		t.Fatalf("DownloadAlbum: %v", err)
+	}
+	if err := downloader.DownloadAlbum(ctx, url, t.TempDir(), "flac", nil); !errors.Is(err, ErrAlreadyDownloaded) {
+		t.Fatalf("second DownloadAlbum err = %v, want ErrAlreadyDownloaded", err)
+	}
+
+	dir := t.TempDir()
+	if err := downloader.DownloadAlbumWithOptions(ctx, url, dir, "flac", DownloadOptions{IgnoreHistory: true}, nil); err != nil {
+		t.Fatalf("DownloadAlbumWithOptions: %v", err)
+	}
+	entries := history.Entries()
+	if len(entries) != 1 || entries[0].Path != filepath.Join(dir, fixtureArchive) {
+		t.Errorf("history = %+v, want the one entry pointing at the new download", entries)
+	}
+}
+
+func TestDownloadFailureSavesDiagnostics(t *testing.T) {
+	browser := newTestBrowser(t)
+	site := newFixtureSite(t, nil)
+	diagnostics := t.TempDir()
+	browser.SetDiagnostics(playwright.DiagnosticsOptions{Dir: diagnostics, Trace: true})
+	t.Cleanup(func() { browser.SetDiagnostics(playwright.DiagnosticsOptions{}) })
+
+	// A download page whose format selector moved
+	profilePath := filepath.Join(t.TempDir(), "site-profile.json")
+	if err := os.WriteFile(profilePath, []byte(`{"download": {"formatSelect": {"selectors": ["#moved"], "timeout": "1s"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	downloader := NewDownloaderService(browser)
+	downloader.SetRetryPolicy(noRetry)
+	downloader.SetSiteProfiles(NewSiteProfileStore(profilePath))
+	downloader.SetPostProcess(PostProcessOptions{})
+
+	err := downloader.DownloadAlbum(context.Background(), site.URL()+"/album/free-album", t.TempDir(), "flac", nil)
+	if !errors.Is(err, ErrSelectorChanged) {
+		t.Fatalf("err = %v, want ErrSelectorChanged", err)
+	}
+	dir := DiagnosticsDir(err)
+	if filepath.Dir(dir) != diagnostics {
+		t.Fatalf("diagnostics saved to %q, want a folder in %s", dir, diagnostics)
+	}
+	for _, name := range []string{playwright.ScreenshotFile, playwright.PageFile, playwright.URLFile, playwright.ConsoleFile, playwright.ErrorFile, playwright.HARFile, playwright.TraceFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("bundle is missing %s: %v", name, err)
+		}
+	}
+}
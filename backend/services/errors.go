@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// Failure classes. Scanner and downloader errors wrap one of these with %w so
+// callers can tell with errors.Is what went wrong and whether to retry
+var (
+	ErrPaidOnly        = errors.New("release must be bought")
+	ErrRegionBlocked   = errors.New("release is not available in your region")
+	ErrEmailTimeout    = errors.New("download email did not arrive")
+	ErrSelectorChanged = errors.New("page element not found, the page layout may have changed")
+	ErrNetwork         = errors.New("network error")
+	ErrRateLimited     = errors.New("rate limited by Bandcamp")
+)
+
+// errorKinds names the failure classes for clients of the JSON API and events
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{ErrAlreadyDownloaded, "already_downloaded"},
+	{ErrPaidOnly, "paid_only"},
+	{ErrRegionBlocked, "region_blocked"},
+	{ErrEmailTimeout, "email_timeout"},
+	{ErrSelectorChanged, "selector_changed"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrNetwork, "network"},
+	{context.Canceled, "cancelled"},
+	{context.DeadlineExceeded, "timeout"},
+}
+
+// ErrorKind returns the name of err's failure class, such as "paid_only", or
+// "" when it has none
+func ErrorKind(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return ""
+}
+
+// HTTPError is an unexpected HTTP status. 429 counts as ErrRateLimited and
+// 5xx as ErrNetwork
+type HTTPError struct {
+	StatusCode int
+	URL        string
+	RetryAfter time.Duration // From the Retry-After header, if any
+}
+
+func newHTTPError(resp *http.Response, url string) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		URL:        url,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP error %d for %s", e.StatusCode, e.URL)
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrNetwork:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// networkError marks a failed request as ErrNetwork, unless it failed
+// because ctx was cancelled
+func networkError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || errors.Is(err, ErrNetwork) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrNetwork, err)
+}
+
+// selectorError marks a page element that didn't show up as ErrSelectorChanged
+func selectorError(what string, err error) error {
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrSelectorChanged, what)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrSelectorChanged, what, err)
+}
+
+// navigationError classifies a failed page.Goto: timeouts and connection
+// errors are network errors
+func navigationError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if errors.Is(err, pw.ErrTimeout) || strings.Contains(err.Error(), "net::ERR_") {
+		return networkError(ctx, err)
+	}
+	return err
+}
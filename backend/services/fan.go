@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/playwright"
+
+	"golang.org/x/net/html"
+)
+
+// fanPageSize is how many items are requested per collection API call
+const fanPageSize = 100
+
+// FanScanOptions selects which of a fan's lists are scanned
+type FanScanOptions struct {
+	Collection bool `json:"collection"` // Items the fan bought or downloaded for free
+	Wishlist   bool `json:"wishlist"`
+}
+
+// FanScanner lists the albums in a fan's collection and wishlist at
+// bandcamp.com/<username>. Logged-in sessions of the same fan also get the
+// download page of each owned item
+type FanScanner struct {
+	client  *http.Client
+	baseURL string
+
+	mu        sync.Mutex
+	purchases *purchaseLoad // Latest listing for PurchaseDownloadPage, maybe still running
+}
+
+func NewFanScanner() *FanScanner {
+	jar, _ := cookiejar.New(nil)
+	return &FanScanner{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Jar:     jar,
+		},
+		baseURL: "https://bandcamp.com",
+	}
+}
+
+// NewFanScannerFromEnv creates a fan scanner logged in with the Bandcamp
+// "identity" cookie in BCDL_IDENTITY, if set
+func NewFanScannerFromEnv() *FanScanner {
+	scanner := NewFanScanner()
+	if identity := os.Getenv("BCDL_IDENTITY"); identity != "" {
+		scanner.SetCookies([]*http.Cookie{{Name: "identity", Value: identity}})
+	}
+	return scanner
+}
+
+// SetCookies adds session cookies sent with every request to bandcamp.com
+func (s *FanScanner) SetCookies(cookies []*http.Cookie) {
+	base, err := url.Parse(s.baseURL)
+	if err != nil {
+		return
+	}
+	s.client.Jar.SetCookies(base, cookies)
+
+	// The purchases may belong to someone else now
+	s.mu.Lock()
+	s.purchases = nil
+	s.mu.Unlock()
+}
+
+// fanPageData is the subset of the fan page's data-blob JSON we rely on
+type fanPageData struct {
+	FanData struct {
+		FanID    int64  `json:"fan_id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	} `json:"fan_data"`
+}
+
+// fanItem is one entry of the collection API's items
+type fanItem struct {
+	ItemType     string `json:"item_type"` // "album", "track" or "package"
+	ItemTitle    string `json:"item_title"`
+	ItemURL      string `json:"item_url"`
+	ItemArtID    int64  `json:"item_art_id"`
+	BandName     string `json:"band_name"`
+	TralbumID    int64  `json:"tralbum_id"`
+	TralbumType  string `json:"tralbum_type"` // "a" or "t"
+	SaleItemID   int64  `json:"sale_item_id"`
+	SaleItemType string `json:"sale_item_type"`
+}
+
+// fanItemsPage is a response of the collection API
+type fanItemsPage struct {
+	Items          []fanItem         `json:"items"`
+	MoreAvailable  bool              `json:"more_available"`
+	LastToken      string            `json:"last_token"`
+	RedownloadURLs map[string]string `json:"redownload_urls"` // Keyed by sale item, logged-in fans only
+	Error          bool              `json:"error"`
+	ErrorMessage   string            `json:"error_message"`
+}
+
+// ScanFan lists a fan's collection and/or wishlist. fan is a username or the
+// URL of the fan's page. Albums are flagged as owned or wishlisted; owned
+// albums carry their download page when the session belongs to the fan
+func (s *FanScanner) ScanFan(ctx context.Context, fan string, opts FanScanOptions, onAlbumFound func(models.Album)) ([]models.Album, error) {
+	username, err := fanUsername(fan)
+	if err != nil {
+		return nil, err
+	}
+
+	fanID, err := s.fanID(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("FanScanner: %s has fan id %d", username, fanID)
+
+	var albums []models.Album
+	index := make(map[string]int) // Album URL to position in albums
+	add := func(album models.Album) {
+		key := normalizeAlbumURL(album.URL)
+		if i, ok := index[key]; ok {
+			albums[i].Owned = albums[i].Owned || album.Owned
+			albums[i].Wishlisted = albums[i].Wishlisted || album.Wishlisted
+			if albums[i].DownloadURL == "" {
+				albums[i].DownloadURL = album.DownloadURL
+			}
+			return
+		}
+		index[key] = len(albums)
+		albums = append(albums, album)
+		if onAlbumFound != nil {
+			onAlbumFound(album)
+		}
+	}
+
+	if opts.Collection {
+		if err := s.scanItems(ctx, "collection_items", fanID, true, add); err != nil {
+			return albums, err
+		}
+	}
+	if opts.Wishlist {
+		if err := s.scanItems(ctx, "wishlist_items", fanID, false, add); err != nil {
+			return albums, err
+		}
+	}
+
+	log.Printf("FanScanner: Found %d albums for %s", len(albums), username)
+	return albums, nil
+}
+
+// fanID reads the fan's numeric id from their page
+func (s *FanScanner) fanID(ctx context.Context, username string) (int64, error) {
+	pageURL := s.baseURL + "/" + url.PathEscape(username)
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", playwright.UserAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch fan page: %w", networkError(ctx, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, newHTTPError(resp, pageURL)
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+	blob := findFirst(doc, byID("pagedata"))
+	if blob == nil {
+		return 0, fmt.Errorf("%s is not a fan page", pageURL)
+	}
+
+	var data fanPageData
+	if err := json.Unmarshal([]byte(attr(blob, "data-blob")), &data); err != nil {
+		return 0, fmt.Errorf("failed to parse fan page data: %v", err)
+	}
+	if data.FanData.FanID == 0 {
+		return 0, fmt.Errorf("%s is not a fan page", pageURL)
+	}
+	return data.FanData.FanID, nil
+}
+
+// scanItems pages through one of the collection APIs, the same one the fan
+// page's "view all" button uses, newest first
+func (s *FanScanner) scanItems(ctx context.Context, list string, fanID int64, owned bool, add func(models.Album)) error {
+	// Tokens are "<timestamp>::<type>::"; starting from now returns everything
+	token := fmt.Sprintf("%d::a::", time.Now().Unix())
+	for {
+		page, err := s.fetchItems(ctx, list, fanID, token)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range page.Items {
+			album := item.album()
+			album.Owned = owned
+			album.Wishlisted = !owned
+			if owned {
+				album.DownloadURL = page.RedownloadURLs[item.SaleItemType+fmt.Sprint(item.SaleItemID)]
+			}
+			add(album)
+		}
+
+		if !page.MoreAvailable || len(page.Items) == 0 || page.LastToken == token {
+			return nil
+		}
+		token = page.LastToken
+	}
+}
+
+func (s *FanScanner) fetchItems(ctx context.Context, list string, fanID int64, token string) (*fanItemsPage, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"fan_id":           fanID,
+		"older_than_token": token,
+		"count":            fanPageSize,
+	})
+	apiURL := s.baseURL + "/api/fancollection/1/" + list
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", playwright.UserAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", list, networkError(ctx, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp, apiURL)
+	}
+
+	var page fanItemsPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", list, err)
+	}
+	if page.Error {
+		return nil, fmt.Errorf("failed to fetch %s: %s", list, page.ErrorMessage)
+	}
+	return &page, nil
+}
+
+func (item fanItem) album() models.Album {
+	album := models.Album{
+		ID:     item.TralbumID,
+		Type:   "album",
+		Title:  item.ItemTitle,
+		Artist: item.BandName,
+		URL:    item.ItemURL,
+		Status: "paid",
+	}
+	if item.TralbumType == "t" {
+		album.Type = "track"
+	}
+	if item.ItemArtID != 0 {
+		album.CoverURL = fmt.Sprintf("https://f4.bcbits.com/img/a%010d_2.jpg", item.ItemArtID)
+	}
+	return album
+}
+
+// fanUsername accepts "name", "bandcamp.com/name" or a full fan page URL such
+// as https://bandcamp.com/name/wishlist
+func fanUsername(fan string) (string, error) {
+	fan = strings.TrimSpace(fan)
+	if !strings.Contains(fan, "/") {
+		if fan == "" {
+			return "", fmt.Errorf("fan username is required")
+		}
+		return fan, nil
+	}
+
+	if !strings.Contains(fan, "://") {
+		fan = "https://" + fan
+	}
+	u, err := url.Parse(fan)
+	if err != nil {
+		return "", fmt.Errorf("invalid fan URL %q: %v", fan, err)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if segments[0] == "" {
+		return "", fmt.Errorf("invalid fan URL %q: missing username", fan)
+	}
+	return segments[0], nil
+}
+
+// Scanner returns the fan scanner as a Scanner taking fan pages instead of
+// artist pages, so fan scans run wherever artist scans do
+func (s *FanScanner) Scanner(opts FanScanOptions) Scanner {
+	return ScanFunc(func(ctx context.Context, fan string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+		return s.ScanFan(ctx, fan, opts, onAlbumFound)
+	})
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"bcdl-app/backend/fixtures"
+	"bcdl-app/backend/playwright"
+)
+
+// siteFixtures is the recorded artist, album and download pages the offline
+// tests replay
+const siteFixtures = "testdata/site"
+
+// fixtureDomain is the domain the fake Mail.tm hands out addresses at
+const fixtureDomain = "fixture.test"
+
+// noRetry fails fast, the fixture server is either right or broken
+var noRetry = RetryPolicy{Attempts: 1}
+
+// newFixtureSite replays siteFixtures. Submitting the email form of the
+// email-gated album sends the download link to the address through mail
+func newFixtureSite(t *testing.T, mail *fixtures.MailTM) *fixtures.Server {
+	t.Helper()
+	site, err := fixtures.NewServer(siteFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(site.Close)
+
+	if mail != nil {
+		site.Handle("POST", "/email_download", func(w http.ResponseWriter, r *http.Request) {
+			address := r.FormValue("address")
+			link := site.URL() + "/download?id=" + r.FormValue("item_id")
+			mail.Deliver(address, fixtures.Message{
+				FromAddress: "noreply@bandcamp.com",
+				FromName:    "Bandcamp",
+				Subject:     "Your download from Fixture Artist",
+				Text:        "Download your album here: " + link,
+				HTML:        `<p><a href="` + link + `">Download</a></p>`,
+			})
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	return site
+}
+
+// newFixtureMail starts a fake Mail.tm API
+func newFixtureMail(t *testing.T) *fixtures.MailTM {
+	t.Helper()
+	mail := fixtures.NewMailTM(fixtureDomain)
+	t.Cleanup(mail.Close)
+	return mail
+}
+
+// newTestBrowser starts Playwright for the tests that drive the browser
+// flows. They only run with BCDL_BROWSER_TESTS=1, using the browsers in
+// PLAYWRIGHT_BROWSERS_PATH when set
+func newTestBrowser(t *testing.T) *playwright.Service {
+	t.Helper()
+	if os.Getenv("BCDL_BROWSER_TESTS") != "1" {
+		t.Skip("set BCDL_BROWSER_TESTS=1 to run the browser flows")
+	}
+	svc := playwright.NewService()
+	svc.SetOutput(io.Discard)
+	if err := svc.Init(); err != nil {
+		t.Fatalf("failed to start Playwright: %v", err)
+	}
+	t.Cleanup(svc.Close)
+	return svc
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bcdl-app/backend/models"
+)
+
+// ErrAlreadyDownloaded is returned when an album is already in the history
+// in the requested format and its files are still on disk
+var ErrAlreadyDownloaded = errors.New("album already downloaded")
+
+// HistoryEntry records a completed download
+type HistoryEntry struct {
+	URL          string    `json:"url"`
+	AlbumID      int64     `json:"albumId,omitempty"` // Bandcamp's id from data-tralbum
+	Artist       string    `json:"artist,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Format       string    `json:"format"`
+	Path         string    `json:"path"`     // Saved file, or the folder it was extracted to
+	Size         int64     `json:"size"`     // Bytes downloaded
+	Checksum     string    `json:"checksum"` // SHA-256 of the downloaded file
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+// present reports whether the downloaded files are still where they were saved
+func (e HistoryEntry) present() bool {
+	_, err := os.Stat(e.Path)
+	return err == nil
+}
+
+// historyLine is one line of the history file, which is only ever appended
+// to: an entry recorded or an album forgotten
+type historyLine struct {
+	Entry  *HistoryEntry `json:"entry,omitempty"`
+	Forget string        `json:"forget,omitempty"` // URL of the album whose entries are removed
+}
+
+// historyCompactMin is how many lines the history file may hold before it is
+// rewritten with only the live entries, once it is twice their number
+const historyCompactMin = 100
+
+// DownloadHistory is a local record of completed downloads, persisted as an
+// append-only JSON Lines file that is compacted as it grows
+type DownloadHistory struct {
+	path string
+
+	mu      sync.Mutex
+	entries []HistoryEntry
+	lines   int // Lines in the file
+}
+
+// NewDownloadHistory creates a history backed by path. An empty path keeps
+// the history in memory only
+func NewDownloadHistory(path string) *DownloadHistory {
+	return &DownloadHistory{path: path}
+}
+
+// NewDownloadHistoryFromEnv loads the history kept in the data dir
+func NewDownloadHistoryFromEnv() *DownloadHistory {
+	path := ""
+	if dir, err := DataDir(); err == nil {
+		path = filepath.Join(dir, "history.jsonl")
+	} else {
+		log.Printf("Download history will not be persisted: %v", err)
+	}
+
+	history := NewDownloadHistory(path)
+	if err := history.Load(); err != nil {
+		log.Printf("Failed to load download history: %v", err)
+	}
+	return history
+}
+
+// Load reads the history from disk. A missing file is not an error, and lines
+// that can't be read, such as one cut short by a crash, are dropped
+func (h *DownloadHistory) Load() error {
+	if h.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries, h.lines = nil, 0
+	broken := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		h.lines++
+		var line historyLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			broken++
+			continue
+		}
+		h.apply(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", h.path, err)
+	}
+	if broken > 0 {
+		// Appending after a cut-off line would garble the next one too
+		log.Printf("Dropping %d unreadable lines from %s", broken, h.path)
+		return h.compactLocked()
+	}
+	return nil
+}
+
+// apply updates the entries with a line of the history file
+func (h *DownloadHistory) apply(line historyLine) {
+	if line.Forget != "" {
+		kept := h.entries[:0]
+		for _, e := range h.entries {
+			if !sameAlbum(e, line.Forget, 0) {
+				kept = append(kept, e)
+			}
+		}
+		h.entries = kept
+	}
+	if entry := line.Entry; entry != nil {
+		for i, e := range h.entries {
+			if sameAlbum(e, entry.URL, entry.AlbumID) && strings.EqualFold(e.Format, entry.Format) {
+				h.entries[i] = *entry
+				return
+			}
+		}
+		h.entries = append(h.entries, *entry)
+	}
+}
+
+// Record adds a completed download, replacing an earlier entry for the same
+// album and format
+func (h *DownloadHistory) Record(entry HistoryEntry) error {
+	if entry.DownloadedAt.IsZero() {
+		entry.DownloadedAt = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.appendLocked(historyLine{Entry: &entry})
+}
+
+// Lookup returns the entry for an album downloaded in format whose files are
+// still on disk. albumID may be 0 when unknown
+func (h *DownloadHistory) Lookup(albumURL string, albumID int64, format string) (HistoryEntry, bool) {
+	for _, e := range h.find(albumURL, albumID) {
+		if strings.EqualFold(e.Format, format) && e.present() {
+			return e, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// Downloaded reports whether an album has been downloaded in any format and
+// is still on disk
+func (h *DownloadHistory) Downloaded(albumURL string, albumID int64) bool {
+	for _, e := range h.find(albumURL, albumID) {
+		if e.present() {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the entries for an album. They are copied so the disk can be
+// checked for their files without holding the lock
+func (h *DownloadHistory) find(albumURL string, albumID int64) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var found []HistoryEntry
+	for _, e := range h.entries {
+		if sameAlbum(e, albumURL, albumID) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Forget removes every entry for an album so it can be downloaded again
+func (h *DownloadHistory) Forget(albumURL string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.appendLocked(historyLine{Forget: albumURL})
+}
+
+// Entries returns every recorded download, oldest first
+func (h *DownloadHistory) Entries() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]HistoryEntry(nil), h.entries...)
+}
+
+// MarkDownloaded sets the Downloaded flag of each album found in the history
+func (h *DownloadHistory) MarkDownloaded(albums []models.Album) {
+	for i := range albums {
+		albums[i].Downloaded = h.Downloaded(albums[i].URL, albums[i].ID)
+	}
+}
+
+// appendLocked applies line and appends it to the history file, compacting
+// the file once it holds mostly replaced or forgotten entries
+func (h *DownloadHistory) appendLocked(line historyLine) error {
+	h.apply(line)
+	if h.path == "" {
+		return nil
+	}
+	if h.lines >= historyCompactMin && h.lines >= 2*len(h.entries) {
+		return h.compactLocked()
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	h.lines++
+	return f.Close()
+}
+
+// compactLocked rewrites the history file with one line per entry
+func (h *DownloadHistory) compactLocked() error {
+	var buf bytes.Buffer
+	for i := range h.entries {
+		data, err := json.Marshal(historyLine{Entry: &h.entries[i]})
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(h.path, buf.Bytes()); err != nil {
+		return err
+	}
+	h.lines = len(h.entries)
+	return nil
+}
+
+// sameAlbum matches on the album id when both sides have one, otherwise on
+// the normalized URL
+func sameAlbum(e HistoryEntry, albumURL string, albumID int64) bool {
+	if e.AlbumID != 0 && albumID != 0 {
+		return e.AlbumID == albumID
+	}
+	return normalizeAlbumURL(e.URL) == normalizeAlbumURL(albumURL)
+}
+
+// normalizeAlbumURL ignores the scheme, host case, query, fragment and a
+// trailing slash, which vary between links to the same album
+func normalizeAlbumURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(strings.TrimSpace(raw), "/")
+	}
+	return strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+}
+
+// fileChecksum returns the SHA-256 and size of the file at path
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
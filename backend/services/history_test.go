@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadHistoryPersists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.jsonl")
+	saved := filepath.Join(dir, "album.zip")
+	if err := os.WriteFile(saved, []byte("zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDownloadHistory(path)
+	for _, entry := range []HistoryEntry{
+		{URL: "https://a.bandcamp.com/album/one", AlbumID: 1, Format: "flac", Path: saved},
+		{URL: "https://a.bandcamp.com/album/one", AlbumID: 1, Format: "flac", Path: saved, Size: 3}, // Replaces the first
+		{URL: "https://a.bandcamp.com/album/one", AlbumID: 1, Format: "mp3-320", Path: filepath.Join(dir, "gone.zip")},
+		{URL: "https://a.bandcamp.com/album/two", AlbumID: 2, Format: "flac", Path: saved},
+	} {
+		if err := h.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Forget("https://a.bandcamp.com/album/two/"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewDownloadHistory(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	entries := reloaded.Entries()
+	if len(entries) != 2 || entries[0].Size != 3 {
+		t.Fatalf("reloaded entries = %+v, want album one in two formats, the first replaced", entries)
+	}
+	if _, ok := reloaded.Lookup("http://A.bandcamp.com/album/one?from=x", 0, "FLAC"); !ok {
+		t.Error("Lookup missed album one")
+	}
+	if _, ok := reloaded.Lookup("", 1, "mp3-320"); ok {
+		t.Error("Lookup found an entry whose files are gone")
+	}
+	if reloaded.Downloaded("https://a.bandcamp.com/album/two", 2) {
+		t.Error("forgotten album still downloaded")
+	}
+}
+
+func TestDownloadHistoryCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewDownloadHistory(path)
+	for i := 0; i < 3*historyCompactMin; i++ {
+		if err := h.Record(HistoryEntry{URL: "https://a.bandcamp.com/album/one", Format: "flac", Size: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines > historyCompactMin {
+		t.Errorf("file has %d lines for one entry, want it compacted", lines)
+	}
+}
+
+func TestDownloadHistoryDropsBrokenLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	line, _ := json.Marshal(historyLine{Entry: &HistoryEntry{URL: "https://a.bandcamp.com/album/one", Format: "flac"}})
+	// A crash cut the last line short
+	if err := os.WriteFile(path, append(append(line, '\n'), `{"entry": {"url": "https://a.bandc`...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDownloadHistory(path)
+	if err := h.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Record(HistoryEntry{URL: "https://a.bandcamp.com/album/two", Format: "flac"}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewDownloadHistory(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reloaded.Entries()); n != 2 {
+		t.Errorf("reloaded %d entries, want the 2 that were written whole", n)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"bcdl-app/backend/models"
+)
+
+// RosterScanner is implemented by scanners that can list the artists on a
+// label's roster
+type RosterScanner interface {
+	// ScanRoster reads a label's /artists page, returning the label's name
+	// and its artists without their releases
+	ScanRoster(ctx context.Context, labelURL string) (string, []models.LabelArtist, error)
+}
+
+// ScanLabel scans a label's own releases and groups them under the artists on
+// its roster. With includeArtists, each roster artist's own page is scanned
+// as well, so releases they put out elsewhere are included. A site without a
+// roster is scanned like an artist page
+func ScanLabel(ctx context.Context, scanner Scanner, labelURL string, includeArtists bool, onAlbumFound func(models.Album)) (models.Label, error) {
+	root, err := siteRoot(labelURL)
+	if err != nil {
+		return models.Label{}, err
+	}
+	label := models.Label{URL: root.String(), Name: root.Host}
+
+	var roster []models.LabelArtist
+	if rs, ok := scanner.(RosterScanner); ok {
+		name, artists, err := rs.ScanRoster(ctx, resolvePath(root, "/artists"))
+		if err != nil {
+			if ctx.Err() != nil {
+				return label, ctx.Err()
+			}
+			log.Printf("Scanner: No roster for %s: %v", label.URL, err)
+		}
+		if name != "" {
+			label.Name = name
+		}
+		roster = artists
+	}
+	log.Printf("Scanner: %s has %d artists on its roster", label.URL, len(roster))
+
+	seen := make(map[string]bool)
+	var albums []models.Album
+	add := func(found []models.Album) {
+		for _, album := range found {
+			key := normalizeAlbumURL(album.URL)
+			if !seen[key] {
+				seen[key] = true
+				albums = append(albums, album)
+			}
+		}
+	}
+
+	found, err := scanner.ScanArtist(ctx, resolvePath(root, "/music"), onAlbumFound)
+	add(found)
+	if err != nil {
+		label.Artists = groupByArtist(roster, albums)
+		return label, err
+	}
+
+	if includeArtists {
+		for i, artist := range roster {
+			artistRoot, err := siteRoot(artist.URL)
+			if err != nil || artistRoot.Host == root.Host {
+				continue
+			}
+
+			// Releases already found on the label's page are reported once
+			found, err := scanner.ScanArtist(ctx, resolvePath(artistRoot, "/music"), func(album models.Album) {
+				if onAlbumFound != nil && !seen[normalizeAlbumURL(album.URL)] {
+					onAlbumFound(album)
+				}
+			})
+			add(found)
+			if ctx.Err() != nil {
+				label.Artists = groupByArtist(roster, albums)
+				return label, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("Scanner: Failed to scan %s: %v", artist.URL, err)
+				roster[i].Error = err.Error()
+			}
+		}
+	}
+
+	label.Artists = groupByArtist(roster, albums)
+	return label, nil
+}
+
+// groupByArtist files each album under the roster artist whose site it's on,
+// or whose name it's credited to. Albums by other artists get an entry of
+// their own, in the order they were found
+func groupByArtist(roster []models.LabelArtist, albums []models.Album) []models.LabelArtist {
+	artists := append([]models.LabelArtist(nil), roster...)
+	byHost := make(map[string]int)
+	byName := make(map[string]int)
+	for i, artist := range artists {
+		artists[i].Albums = []models.Album{}
+		if u, err := siteRoot(artist.URL); err == nil {
+			byHost[u.Host] = i
+		}
+		byName[strings.ToLower(artist.Name)] = i
+	}
+
+	for _, album := range albums {
+		i, ok := -1, false
+		if u, err := siteRoot(album.URL); err == nil {
+			i, ok = byHost[u.Host]
+		}
+		if !ok {
+			i, ok = byName[strings.ToLower(album.Artist)]
+		}
+		if !ok {
+			i = len(artists)
+			byName[strings.ToLower(album.Artist)] = i
+			artists = append(artists, models.LabelArtist{Name: album.Artist, Albums: []models.Album{}})
+		}
+		artists[i].Albums = append(artists[i].Albums, album)
+	}
+	return artists
+}
+
+// siteRoot returns the scheme and host of a Bandcamp site, which may be a
+// subdomain of bandcamp.com or a custom domain
+func siteRoot(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// "label.bandcamp.com/music" parses as a path
+		if u, err = url.Parse("https://" + strings.TrimSpace(raw)); err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %v", raw, err)
+		}
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return &url.URL{Scheme: u.Scheme, Host: strings.ToLower(u.Host)}, nil
+}
+
+// resolvePath returns the URL of path on the site at root
+func resolvePath(root *url.URL, path string) string {
+	return root.ResolveReference(&url.URL{Path: path}).String()
+}
+
+// rosterArtist builds a roster entry from a link on the /artists page, which
+// points at the artist's site with tracking parameters
+func rosterArtist(base *url.URL, name string, href string) (models.LabelArtist, bool) {
+	link := resolveURL(base, href)
+	root, err := siteRoot(link)
+	if err != nil || link == "" {
+		return models.LabelArtist{}, false
+	}
+	u, _ := url.Parse(link)
+	artistURL := root.String()
+	if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+		artistURL += path
+	}
+	return models.LabelArtist{Name: strings.TrimSpace(name), URL: artistURL}, true
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ldJSONData is the subset of the album page's schema.org JSON-LD we rely on
+type ldJSONData struct {
+	ByArtist struct {
+		Name string `json:"name"`
+	} `json:"byArtist"`
+	Publisher struct {
+		Name string `json:"name"`
+	} `json:"publisher"`
+	RecordLabel struct {
+		Name string `json:"name"`
+	} `json:"recordLabel"`
+	DatePublished string      `json:"datePublished"`
+	Description   string      `json:"description"`
+	CreditText    string      `json:"creditText"`
+	Keywords      interface{} `json:"keywords"` // A list of tags, or one comma-separated string
+	Offers        *ldOffer    `json:"offers"`   // Track pages
+	AlbumRelease  []struct {
+		CatalogNumber string   `json:"catalogNumber"`
+		Offers        *ldOffer `json:"offers"`
+	} `json:"albumRelease"`
+}
+
+type ldOffer struct {
+	Price         float64 `json:"price"`
+	PriceCurrency string  `json:"priceCurrency"`
+}
+
+func parseLDJSON(raw string) (*ldJSONData, error) {
+	var data ldJSONData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse ld+json data: %v", err)
+	}
+	return &data, nil
+}
+
+// Label returns the record label. Releases sold from a label's account list
+// the label as publisher rather than as recordLabel
+func (d *ldJSONData) Label() string {
+	if d.RecordLabel.Name != "" {
+		return d.RecordLabel.Name
+	}
+	if d.Publisher.Name != d.ByArtist.Name {
+		return d.Publisher.Name
+	}
+	return ""
+}
+
+// CatalogNumber returns the first catalog number listed for the release
+func (d *ldJSONData) CatalogNumber() string {
+	for _, release := range d.AlbumRelease {
+		if release.CatalogNumber != "" {
+			return release.CatalogNumber
+		}
+	}
+	return ""
+}
+
+// Tags returns the release's tags
+func (d *ldJSONData) Tags() []string {
+	var raw []string
+	switch keywords := d.Keywords.(type) {
+	case string:
+		raw = strings.Split(keywords, ",")
+	case []interface{}:
+		for _, k := range keywords {
+			if tag, ok := k.(string); ok {
+				raw = append(raw, tag)
+			}
+		}
+	}
+
+	var tags []string
+	for _, tag := range raw {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Currency returns the currency the release is priced in
+func (d *ldJSONData) Currency() string {
+	if d.Offers != nil && d.Offers.PriceCurrency != "" {
+		return d.Offers.PriceCurrency
+	}
+	for _, release := range d.AlbumRelease {
+		if release.Offers != nil && release.Offers.PriceCurrency != "" {
+			return release.Offers.PriceCurrency
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+)
+
+// MailboxProvider is a source of addresses that can receive the Bandcamp
+// download email, whose calls all give up as soon as ctx is done
+type MailboxProvider interface {
+	// Name identifies the provider in logs and in BCDL_MAIL_PROVIDERS
+	Name() string
+	// GenerateAddress returns a fresh address to give to Bandcamp
+	GenerateAddress(ctx context.Context) (string, error)
+	// CheckInbox lists the messages received by address
+	CheckInbox(ctx context.Context, address string) ([]EmailMessage, error)
+	// ReadMessage retrieves the full content of a message
+	ReadMessage(ctx context.Context, address string, messageID string) (*EmailBody, error)
+}
+
+// AddressReleaser is implemented by providers that hold on to an address until
+// its download email has been read or the job gave up on it
+type AddressReleaser interface {
+	ReleaseAddress(address string)
+}
+
+// MessageDisposer is implemented by providers that tidy up a message once its
+// download link has been extracted
+type MessageDisposer interface {
+	DisposeMessage(ctx context.Context, address string, messageID string) error
+}
+
+type EmailAddress struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+}
+
+type EmailMessage struct {
+	ID      string       `json:"id"` // Mail.tm uses string IDs
+	From    EmailAddress `json:"from"`
+	Subject string       `json:"subject"`
+	Intro   string       `json:"intro"`
+}
+
+type EmailBody struct {
+	ID      string       `json:"id"`
+	From    EmailAddress `json:"from"`
+	Subject string       `json:"subject"`
+	Html    []string     `json:"html"`
+	Text    string       `json:"text"`
+}
+
+// DefaultMailProviders is the fallback order used when BCDL_MAIL_PROVIDERS is unset
+const DefaultMailProviders = "catchall,mailtm,mailgw,1secmail,imap"
+
+// MailboxProvidersFromEnv builds the ordered provider list from
+// BCDL_MAIL_PROVIDERS. The IMAP provider is only included when
+// BCDL_IMAP_ADDR is configured, the catch-all provider only when
+// BCDL_CATCHALL_DOMAIN is set as well
+func MailboxProvidersFromEnv() []MailboxProvider {
+	names := os.Getenv("BCDL_MAIL_PROVIDERS")
+	if names == "" {
+		names = DefaultMailProviders
+	}
+
+	var providers []MailboxProvider
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "mailtm":
+			providers = append(providers, NewMailTMProvider("mailtm", MailTMBaseURL))
+		case "mailgw":
+			providers = append(providers, NewMailTMProvider("mailgw", MailGWBaseURL))
+		case "1secmail":
+			providers = append(providers, NewOneSecMailProvider(OneSecMailBaseURL))
+		case "catchall":
+			if domain := os.Getenv("BCDL_CATCHALL_DOMAIN"); domain != "" && os.Getenv("BCDL_IMAP_ADDR") != "" {
+				providers = append(providers, NewCatchAllProvider(domain, IMAPConfigFromEnv()))
+			}
+		case "imap":
+			if os.Getenv("BCDL_IMAP_ADDR") != "" {
+				providers = append(providers, NewIMAPProvider(IMAPConfigFromEnv()))
+			}
+		case "":
+		default:
+			log.Printf("TempEmail: Unknown mail provider %q, skipping", name)
+		}
+	}
+	return providers
+}
+
+// IMAPConfigFromEnv reads the IMAP mailbox settings from BCDL_IMAP_* variables
+func IMAPConfigFromEnv() IMAPConfig {
+	return IMAPConfig{
+		Addr:            os.Getenv("BCDL_IMAP_ADDR"),
+		Username:        os.Getenv("BCDL_IMAP_USER"),
+		Password:        os.Getenv("BCDL_IMAP_PASSWORD"),
+		Address:         os.Getenv("BCDL_IMAP_ADDRESS"),
+		Mailbox:         os.Getenv("BCDL_IMAP_MAILBOX"),
+		Insecure:        os.Getenv("BCDL_IMAP_TLS") == "false",
+		MoveTo:          os.Getenv("BCDL_IMAP_MOVE_TO"),
+		DeleteProcessed: os.Getenv("BCDL_IMAP_DELETE") == "true",
+	}
+}
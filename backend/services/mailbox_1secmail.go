@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const OneSecMailBaseURL = "https://www.1secmail.com/api/v1/"
+
+// OneSecMailProvider uses the 1secmail API, where any address on its domains
+// receives mail without creating an account first
+type OneSecMailProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// 1secmail API structures
+type oneSecMailMessage struct {
+	ID      int64  `json:"id"`
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+}
+
+type oneSecMailBody struct {
+	oneSecMailMessage
+	TextBody string `json:"textBody"`
+	HTMLBody string `json:"htmlBody"`
+}
+
+func NewOneSecMailProvider(baseURL string) *OneSecMailProvider {
+	return &OneSecMailProvider{
+		baseURL: baseURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (p *OneSecMailProvider) Name() string {
+	return "1secmail"
+}
+
+// GenerateAddress asks the API for a random mailbox
+func (p *OneSecMailProvider) GenerateAddress(ctx context.Context) (string, error) {
+	var addresses []string
+	if err := p.get(ctx, url.Values{"action": {"genRandomMailbox"}, "count": {"1"}}, &addresses); err != nil {
+		return "", fmt.Errorf("failed to generate mailbox: %v", err)
+	}
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("no mailbox returned")
+	}
+
+	log.Printf("TempEmail: Generated 1secmail email: %s", addresses[0])
+	return addresses[0], nil
+}
+
+// CheckInbox polls the inbox for new messages
+func (p *OneSecMailProvider) CheckInbox(ctx context.Context, address string) ([]EmailMessage, error) {
+	query, err := mailboxQuery("getMessages", address)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []oneSecMailMessage
+	if err := p.get(ctx, query, &result); err != nil {
+		return nil, fmt.Errorf("failed to check inbox: %v", err)
+	}
+
+	messages := make([]EmailMessage, len(result))
+	for i, msg := range result {
+		messages[i] = EmailMessage{
+			ID:      strconv.FormatInt(msg.ID, 10),
+			From:    EmailAddress{Address: msg.From},
+			Subject: msg.Subject,
+		}
+	}
+	return messages, nil
+}
+
+// ReadMessage retrieves the full content of a message
+func (p *OneSecMailProvider) ReadMessage(ctx context.Context, address string, messageID string) (*EmailBody, error) {
+	query, err := mailboxQuery("readMessage", address)
+	if err != nil {
+		return nil, err
+	}
+	query.Set("id", messageID)
+
+	var result oneSecMailBody
+	if err := p.get(ctx, query, &result); err != nil {
+		return nil, fmt.Errorf("failed to read message: %v", err)
+	}
+
+	body := &EmailBody{
+		ID:      messageID,
+		From:    EmailAddress{Address: result.From},
+		Subject: result.Subject,
+		Text:    result.TextBody,
+	}
+	if result.HTMLBody != "" {
+		body.Html = []string{result.HTMLBody}
+	}
+	return body, nil
+}
+
+func (p *OneSecMailProvider) get(ctx context.Context, query url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func mailboxQuery(action string, address string) (url.Values, error) {
+	login, domain, ok := strings.Cut(address, "@")
+	if !ok {
+		return nil, fmt.Errorf("invalid address: %s", address)
+	}
+	return url.Values{"action": {action}, "login": {login}, "domain": {domain}}, nil
+}
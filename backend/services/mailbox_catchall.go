@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// CatchAllProvider hands out random aliases on a domain the user owns and
+// reads the download email from the IMAP mailbox the domain delivers to
+type CatchAllProvider struct {
+	*IMAPProvider
+	domain string
+}
+
+// NewCatchAllProvider creates aliases on domain. Processed messages are
+// deleted unless config.MoveTo names a mailbox to move them to
+func NewCatchAllProvider(domain string, config IMAPConfig) *CatchAllProvider {
+	if config.MoveTo == "" {
+		config.DeleteProcessed = true
+	}
+	return &CatchAllProvider{
+		IMAPProvider: NewIMAPProvider(config),
+		domain:       domain,
+	}
+}
+
+func (p *CatchAllProvider) Name() string {
+	return "catchall"
+}
+
+// GenerateAddress returns a fresh alias@domain address
+func (p *CatchAllProvider) GenerateAddress(ctx context.Context) (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	alias := fmt.Sprintf("bcdl-%s@%s", hex.EncodeToString(b), p.domain)
+
+	if err := p.watch(ctx, alias); err != nil {
+		return "", err
+	}
+
+	log.Printf("TempEmail: Generated catch-all alias: %s", alias)
+	return alias, nil
+}
+
+// ReleaseAddress stops watching alias. Aliases are never reused, so jobs don't
+// wait on each other
+func (p *CatchAllProvider) ReleaseAddress(alias string) {
+	p.forget(alias)
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+)
+
+// IMAPConfig describes a regular mailbox reachable over IMAP
+type IMAPConfig struct {
+	Addr     string // host:port of the IMAP server
+	Username string
+	Password string
+	Address  string // Address that receives the mail, defaults to Username
+	Mailbox  string // Defaults to INBOX
+	Insecure bool   // Connect without TLS, e.g. to a local test server
+
+	// What happens to a message once its download link has been read:
+	// moved to MoveTo if set, otherwise deleted if DeleteProcessed is set
+	MoveTo          string
+	DeleteProcessed bool
+}
+
+// IMAPProvider reads the download email from a mailbox the user owns. Every
+// job gets the same address, so only one job at a time can wait for its email
+type IMAPProvider struct {
+	config IMAPConfig
+	inUse  chan struct{} // Held from GenerateAddress until ReleaseAddress
+
+	mu     sync.Mutex
+	minUID map[string]uint32 // Address -> first UID that may hold its email
+}
+
+func NewIMAPProvider(config IMAPConfig) *IMAPProvider {
+	if config.Mailbox == "" {
+		config.Mailbox = "INBOX"
+	}
+	if config.Address == "" {
+		config.Address = config.Username
+	}
+	return &IMAPProvider{
+		config: config,
+		inUse:  make(chan struct{}, 1),
+		minUID: make(map[string]uint32),
+	}
+}
+
+func (p *IMAPProvider) Name() string {
+	return "imap"
+}
+
+// GenerateAddress returns the configured address once no other job is using
+// it. Only mail arriving after this call is considered, so older download
+// emails are never picked up
+func (p *IMAPProvider) GenerateAddress(ctx context.Context) (string, error) {
+	select {
+	case p.inUse <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if err := p.watch(ctx, p.config.Address); err != nil {
+		<-p.inUse
+		return "", err
+	}
+
+	log.Printf("TempEmail: Using IMAP mailbox %s", p.config.Address)
+	return p.config.Address, nil
+}
+
+// ReleaseAddress lets the next job have the address
+func (p *IMAPProvider) ReleaseAddress(address string) {
+	p.forget(address)
+	select {
+	case <-p.inUse:
+	default:
+	}
+}
+
+// watch starts tracking address from the mailbox's next UID onwards
+func (p *IMAPProvider) watch(ctx context.Context, address string) error {
+	c, mbox, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	next := mbox.UidNext
+	if next == 0 && mbox.Messages > 0 {
+		// UIDNEXT is optional, the newest message gives the same cutoff
+		if next, err = lastUID(c, mbox.Messages); err != nil {
+			return err
+		}
+		next++
+	}
+
+	p.mu.Lock()
+	p.minUID[address] = next
+	p.mu.Unlock()
+	return nil
+}
+
+// forget stops tracking address
+func (p *IMAPProvider) forget(address string) {
+	p.mu.Lock()
+	delete(p.minUID, address)
+	p.mu.Unlock()
+}
+
+// lastUID returns the UID of the newest of count messages
+func lastUID(c *imapConn, count uint32) (uint32, error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(count)
+	fetched := make(chan *imap.Message, 1)
+	if err := c.Fetch(seqset, []imap.FetchItem{imap.FetchUid}, fetched); err != nil {
+		return 0, fmt.Errorf("failed to fetch newest message: %v", err)
+	}
+	msg := <-fetched
+	if msg == nil {
+		return 0, fmt.Errorf("newest message not found")
+	}
+	return msg.Uid, nil
+}
+
+// CheckInbox lists messages addressed to address that arrived after it was
+// generated. Catch-all deliveries are matched on Delivered-To as well as To
+func (p *IMAPProvider) CheckInbox(ctx context.Context, address string) ([]EmailMessage, error) {
+	c, _, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	p.mu.Lock()
+	minUID := p.minUID[address]
+	p.mu.Unlock()
+
+	to := imap.NewSearchCriteria()
+	to.Header.Add("To", address)
+	deliveredTo := imap.NewSearchCriteria()
+	deliveredTo.Header.Add("Delivered-To", address)
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Or = [][2]*imap.SearchCriteria{{to, deliveredTo}}
+	if minUID > 0 {
+		uids := new(imap.SeqSet)
+		uids.AddRange(minUID, 0)
+		criteria.Uid = uids
+	}
+
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search mailbox: %v", err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+
+	fetched := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope}, fetched); err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %v", err)
+	}
+
+	var messages []EmailMessage
+	for msg := range fetched {
+		// UID ranges ending in * always include the newest message
+		if msg.Uid < minUID || msg.Envelope == nil {
+			continue
+		}
+		email := EmailMessage{
+			ID:      strconv.FormatUint(uint64(msg.Uid), 10),
+			Subject: msg.Envelope.Subject,
+		}
+		if len(msg.Envelope.From) > 0 {
+			email.From = EmailAddress{
+				Address: msg.Envelope.From[0].Address(),
+				Name:    msg.Envelope.From[0].PersonalName,
+			}
+		}
+		messages = append(messages, email)
+	}
+	return messages, nil
+}
+
+// ReadMessage fetches and decodes the message with the given UID
+func (p *IMAPProvider) ReadMessage(ctx context.Context, address string, messageID string) (*EmailBody, error) {
+	uid, err := strconv.ParseUint(messageID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message id: %s", messageID)
+	}
+
+	c, _, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uint32(uid))
+	section := &imap.BodySectionName{Peek: true}
+
+	fetched := make(chan *imap.Message, 1)
+	if err := c.UidFetch(seqset, []imap.FetchItem{section.FetchItem()}, fetched); err != nil {
+		return nil, fmt.Errorf("failed to read message: %v", err)
+	}
+
+	msg := <-fetched
+	if msg == nil {
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+	raw := msg.GetBody(section)
+	if raw == nil {
+		return nil, fmt.Errorf("message %s has no body", messageID)
+	}
+
+	body, err := parseMIMEMessage(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %v", err)
+	}
+	body.ID = messageID
+	return body, nil
+}
+
+// DisposeMessage moves or deletes a processed message as configured
+func (p *IMAPProvider) DisposeMessage(ctx context.Context, address string, messageID string) error {
+	if p.config.MoveTo == "" && !p.config.DeleteProcessed {
+		return nil
+	}
+
+	uid, err := strconv.ParseUint(messageID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid message id: %s", messageID)
+	}
+
+	c, _, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uint32(uid))
+
+	if p.config.MoveTo != "" {
+		if err := c.UidMove(seqset, p.config.MoveTo); err != nil {
+			return fmt.Errorf("failed to move message to %s: %v", p.config.MoveTo, err)
+		}
+		log.Printf("TempEmail: Moved message %s to %s", messageID, p.config.MoveTo)
+		return nil
+	}
+
+	flags := []interface{}{imap.DeletedFlag}
+	if err := c.UidStore(seqset, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+		return fmt.Errorf("failed to flag message as deleted: %v", err)
+	}
+	if err := c.Expunge(nil); err != nil {
+		return fmt.Errorf("failed to expunge mailbox: %v", err)
+	}
+	log.Printf("TempEmail: Deleted message %s", messageID)
+	return nil
+}
+
+// imapConn is a logged-in connection that is cut off when its context ends,
+// since the IMAP client itself doesn't take one
+type imapConn struct {
+	*client.Client
+	stop func() bool
+}
+
+// Close logs out, unless the connection was already cut off
+func (c *imapConn) Close() {
+	if c.stop() {
+		c.Logout()
+	}
+}
+
+func (p *IMAPProvider) connect(ctx context.Context) (*imapConn, *imap.MailboxStatus, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to IMAP server: %v", err)
+	}
+	ic, err := client.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to connect to IMAP server: %v", err)
+	}
+	c := &imapConn{Client: ic}
+	c.stop = context.AfterFunc(ctx, func() { ic.Terminate() })
+
+	if err := c.Login(p.config.Username, p.config.Password); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("IMAP login failed: %v", err)
+	}
+
+	mbox, err := c.Select(p.config.Mailbox, false)
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("failed to select %s: %v", p.config.Mailbox, err)
+	}
+	return c, mbox, nil
+}
+
+// dial opens the connection to the server, over TLS unless Insecure is set
+func (p *IMAPProvider) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", p.config.Addr)
+	if err != nil || p.config.Insecure {
+		return conn, err
+	}
+
+	host, _, _ := strings.Cut(p.config.Addr, ":")
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// parseMIMEMessage collects the HTML and plain text parts of a raw message
+func parseMIMEMessage(raw io.Reader) (*EmailBody, error) {
+	mr, err := mail.CreateReader(raw)
+	if err != nil {
+		return nil, err
+	}
+	defer mr.Close()
+
+	body := &EmailBody{}
+	body.Subject, _ = mr.Header.Subject()
+	if from, err := mr.Header.AddressList("From"); err == nil && len(from) > 0 {
+		body.From = EmailAddress{Address: from[0].Address, Name: from[0].Name}
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		header, ok := part.Header.(*mail.InlineHeader)
+		if !ok {
+			continue
+		}
+		contentType, _, _ := header.ContentType()
+		content, err := io.ReadAll(part.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		switch contentType {
+		case "text/html":
+			body.Html = append(body.Html, string(content))
+		case "text/plain":
+			body.Text += string(content)
+		}
+	}
+	return body, nil
+}
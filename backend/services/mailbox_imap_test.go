@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+)
+
+// testIMAP is an in-process IMAP server holding the memory backend's user,
+// whose INBOX starts out with one old message
+type testIMAP struct {
+	t    *testing.T
+	addr string
+}
+
+// newTestIMAP starts the server. Without UIDNEXT, selecting a mailbox doesn't
+// tell the next UID, like some servers
+func newTestIMAP(t *testing.T, withoutUIDNext bool) *testIMAP {
+	t.Helper()
+	be := &testIMAPBackend{Backend: memory.New(), withoutUIDNext: withoutUIDNext}
+	srv := server.New(be)
+	srv.AllowInsecureAuth = true
+	srv.ErrorLog = discardLog{}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	m := &testIMAP{t: t, addr: l.Addr().String()}
+	m.do(func(c *client.Client) error { return c.Create("Processed") })
+	return m
+}
+
+func (m *testIMAP) config() IMAPConfig {
+	return IMAPConfig{Addr: m.addr, Username: "username", Password: "password", Insecure: true}
+}
+
+func (m *testIMAP) do(f func(c *client.Client) error) {
+	m.t.Helper()
+	c, err := client.Dial(m.addr)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	defer c.Logout()
+	if err := c.Login("username", "password"); err != nil {
+		m.t.Fatal(err)
+	}
+	if err := f(c); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+// deliver appends a Bandcamp download email with the given headers to INBOX
+func (m *testIMAP) deliver(headers string, link string) {
+	m.t.Helper()
+	raw := "From: Bandcamp <noreply@bandcamp.com>\r\n" + headers +
+		"Subject: Your download\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Download your album here: " + link + "\r\n"
+	m.do(func(c *client.Client) error {
+		return c.Append("INBOX", nil, time.Now(), bytes.NewBufferString(raw))
+	})
+}
+
+// count returns how many messages mailbox holds
+func (m *testIMAP) count(mailbox string) uint32 {
+	m.t.Helper()
+	var n uint32
+	m.do(func(c *client.Client) error {
+		mbox, err := c.Select(mailbox, true)
+		if err == nil {
+			n = mbox.Messages
+		}
+		return err
+	})
+	return n
+}
+
+// testIMAPBackend adds MOVE to the memory backend, and can leave UIDNEXT out
+type testIMAPBackend struct {
+	*memory.Backend
+	withoutUIDNext bool
+}
+
+func (be *testIMAPBackend) Login(info *imap.ConnInfo, username, password string) (backend.User, error) {
+	user, err := be.Backend.Login(info, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return &testIMAPUser{User: user, be: be}, nil
+}
+
+type testIMAPUser struct {
+	backend.User
+	be *testIMAPBackend
+}
+
+func (u *testIMAPUser) GetMailbox(name string) (backend.Mailbox, error) {
+	mbox, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+	return &testIMAPMailbox{Mailbox: mbox, be: u.be}, nil
+}
+
+type testIMAPMailbox struct {
+	backend.Mailbox
+	be *testIMAPBackend
+}
+
+func (mbox *testIMAPMailbox) Status(items []imap.StatusItem) (*imap.MailboxStatus, error) {
+	status, err := mbox.Mailbox.Status(items)
+	if err == nil && mbox.be.withoutUIDNext {
+		status.UidNext = 0
+	}
+	return status, err
+}
+
+func (mbox *testIMAPMailbox) MoveMessages(uid bool, seqset *imap.SeqSet, dest string) error {
+	if err := mbox.CopyMessages(uid, seqset, dest); err != nil {
+		return err
+	}
+	if err := mbox.UpdateMessagesFlags(uid, seqset, imap.AddFlags, []string{imap.DeletedFlag}); err != nil {
+		return err
+	}
+	return mbox.Expunge()
+}
+
+type discardLog struct{}
+
+func (discardLog) Printf(string, ...interface{}) {}
+func (discardLog) Println(...interface{})        {}
+
+func TestIMAPProviderOnlyReadsNewMail(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		withoutUIDNext bool
+	}{
+		{"UIDNEXT", false},
+		{"newest UID", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestIMAP(t, tc.withoutUIDNext)
+			config := m.config()
+			config.Address = "me@example.org"
+			provider := NewIMAPProvider(config)
+			ctx := context.Background()
+
+			m.deliver("To: me@example.org\r\n", "https://bandcamp.com/download?id=old")
+			address, err := provider.GenerateAddress(ctx)
+			if err != nil {
+				t.Fatalf("GenerateAddress: %v", err)
+			}
+			defer provider.ReleaseAddress(address)
+			if address != "me@example.org" {
+				t.Errorf("address = %q, want the configured one", address)
+			}
+			m.deliver("To: me@example.org\r\n", "https://bandcamp.com/download?id=new")
+
+			messages, err := provider.CheckInbox(ctx, address)
+			if err != nil {
+				t.Fatalf("CheckInbox: %v", err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("got %d messages, want only the one that arrived after GenerateAddress", len(messages))
+			}
+			body, err := provider.ReadMessage(ctx, address, messages[0].ID)
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			if !strings.Contains(body.Text, "id=new") {
+				t.Errorf("read the wrong message: %q", body.Text)
+			}
+		})
+	}
+}
+
+func TestIMAPProviderServesOneJobAtATime(t *testing.T) {
+	m := newTestIMAP(t, false)
+	provider := NewIMAPProvider(m.config())
+	ctx := context.Background()
+
+	address, err := provider.GenerateAddress(ctx)
+	if err != nil {
+		t.Fatalf("GenerateAddress: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := provider.GenerateAddress(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second GenerateAddress err = %v, want it to wait for the first job", err)
+	}
+
+	provider.ReleaseAddress(address)
+	if _, err := provider.GenerateAddress(ctx); err != nil {
+		t.Fatalf("GenerateAddress after release: %v", err)
+	}
+}
+
+func TestCatchAllProvider(t *testing.T) {
+	m := newTestIMAP(t, false)
+	provider := NewCatchAllProvider("example.org", m.config())
+	ctx := context.Background()
+
+	first, err := provider.GenerateAddress(ctx)
+	if err != nil {
+		t.Fatalf("GenerateAddress: %v", err)
+	}
+	second, err := provider.GenerateAddress(ctx) // Aliases don't wait on each other
+	if err != nil {
+		t.Fatalf("GenerateAddress: %v", err)
+	}
+	alias := regexp.MustCompile(`^bcdl-[0-9a-f]{12}@example\.org$`)
+	if !alias.MatchString(first) || first == second {
+		t.Fatalf("aliases = %q, %q, want two different bcdl-<hex>@example.org", first, second)
+	}
+
+	m.deliver("To: "+first+"\r\n", "https://bandcamp.com/download?id=1")
+	m.deliver("To: list@example.org\r\nDelivered-To: "+second+"\r\n", "https://bandcamp.com/download?id=2")
+
+	for address, want := range map[string]string{first: "id=1", second: "id=2"} {
+		messages, err := provider.CheckInbox(ctx, address)
+		if err != nil {
+			t.Fatalf("CheckInbox(%s): %v", address, err)
+		}
+		if len(messages) != 1 {
+			t.Fatalf("CheckInbox(%s) found %d messages, want 1", address, len(messages))
+		}
+		body, err := provider.ReadMessage(ctx, address, messages[0].ID)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if !strings.Contains(body.Text, want) {
+			t.Errorf("%s got the message for another alias: %q", address, body.Text)
+		}
+	}
+}
+
+func TestIMAPDisposeMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		moveTo    string
+		inbox     uint32
+		processed uint32
+	}{
+		{"delete", "", 1, 0}, // The old message stays
+		{"move", "Processed", 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestIMAP(t, false)
+			config := m.config()
+			config.MoveTo = tc.moveTo
+			provider := NewCatchAllProvider("example.org", config) // Deletes unless told to move
+			ctx := context.Background()
+
+			address, err := provider.GenerateAddress(ctx)
+			if err != nil {
+				t.Fatalf("GenerateAddress: %v", err)
+			}
+			m.deliver("To: "+address+"\r\n", "https://bandcamp.com/download?id=1")
+			messages, err := provider.CheckInbox(ctx, address)
+			if err != nil || len(messages) != 1 {
+				t.Fatalf("CheckInbox = %d messages, %v", len(messages), err)
+			}
+
+			if err := provider.DisposeMessage(ctx, address, messages[0].ID); err != nil {
+				t.Fatalf("DisposeMessage: %v", err)
+			}
+			if n := m.count("INBOX"); n != tc.inbox {
+				t.Errorf("INBOX holds %d messages, want %d", n, tc.inbox)
+			}
+			if n := m.count("Processed"); n != tc.processed {
+				t.Errorf("Processed holds %d messages, want %d", n, tc.processed)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	MailTMBaseURL = "https://api.mail.tm"
+	// Mail.gw runs the same API as Mail.tm on separate infrastructure and domains
+	MailGWBaseURL = "https://api.mail.gw"
+)
+
+// MailTMProvider creates disposable accounts through the Mail.tm API
+type MailTMProvider struct {
+	name    string
+	baseURL string
+	client  *http.Client
+
+	mu       sync.Mutex
+	accounts map[string]mailTMAccount // By address
+}
+
+// mailTMAccount is an account created by GenerateAddress
+type mailTMAccount struct {
+	id    string
+	token string
+}
+
+// Mail.tm API structures
+type DomainResponse struct {
+	HydraMember []struct {
+		Domain string `json:"domain"`
+	} `json:"hydra:member"`
+}
+
+type AccountRequest struct {
+	Address  string `json:"address"`
+	Password string `json:"password"`
+}
+
+type AccountResponse struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+}
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+// NewMailTMProvider creates a provider for a Mail.tm compatible API at baseURL
+func NewMailTMProvider(name string, baseURL string) *MailTMProvider {
+	return &MailTMProvider{
+		name:    name,
+		baseURL: baseURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		accounts: make(map[string]mailTMAccount),
+	}
+}
+
+func (p *MailTMProvider) Name() string {
+	return p.name
+}
+
+// GenerateAddress creates a new account and logs into it
+func (p *MailTMProvider) GenerateAddress(ctx context.Context) (string, error) {
+	// 1. Get Domains
+	domain, err := p.getDomain(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get domain: %v", err)
+	}
+
+	// 2. Generate Credentials
+	username := fmt.Sprintf("user%d", time.Now().UnixNano())
+	password := fmt.Sprintf("Pwd%d!", time.Now().UnixNano())
+	address := fmt.Sprintf("%s@%s", username, domain)
+
+	// 3. Create Account
+	id, err := p.createAccount(ctx, address, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to create account: %v", err)
+	}
+
+	// 4. Get Token
+	token, err := p.getToken(ctx, address, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token: %v", err)
+	}
+
+	p.mu.Lock()
+	p.accounts[address] = mailTMAccount{id: id, token: token}
+	p.mu.Unlock()
+
+	log.Printf("TempEmail: Generated %s email: %s", p.name, address)
+	return address, nil
+}
+
+func (p *MailTMProvider) getDomain(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/domains", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result DomainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if len(result.HydraMember) == 0 {
+		return "", fmt.Errorf("no domains available")
+	}
+
+	return result.HydraMember[0].Domain, nil
+}
+
+// createAccount returns the new account's ID
+func (p *MailTMProvider) createAccount(ctx context.Context, address, password string) (string, error) {
+	reqBody, _ := json.Marshal(AccountRequest{
+		Address:  address,
+		Password: password,
+	})
+
+	resp, err := p.post(ctx, "/accounts", reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result AccountResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+func (p *MailTMProvider) getToken(ctx context.Context, address, password string) (string, error) {
+	reqBody, _ := json.Marshal(AccountRequest{
+		Address:  address,
+		Password: password,
+	})
+
+	resp, err := p.post(ctx, "/token", reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+// ReleaseAddress forgets the address's token and deletes its account, which
+// would otherwise stay around until the service expires it
+func (p *MailTMProvider) ReleaseAddress(address string) {
+	p.mu.Lock()
+	account, ok := p.accounts[address]
+	delete(p.accounts, address)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.deleteAccount(ctx, account); err != nil {
+		log.Printf("TempEmail: Failed to delete %s account %s: %v", p.name, address, err)
+	}
+}
+
+func (p *MailTMProvider) deleteAccount(ctx context.Context, account mailTMAccount) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", p.baseURL+"/accounts/"+account.id, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+account.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("API error %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CheckInbox polls the inbox for new messages
+func (p *MailTMProvider) CheckInbox(ctx context.Context, address string) ([]EmailMessage, error) {
+	req, err := p.authorizedRequest(ctx, address, "/messages")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check inbox: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %d", resp.StatusCode)
+	}
+
+	var result struct {
+		HydraMember []EmailMessage `json:"hydra:member"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse messages: %v", err)
+	}
+
+	return result.HydraMember, nil
+}
+
+// ReadMessage retrieves the full content of a message
+func (p *MailTMProvider) ReadMessage(ctx context.Context, address string, messageID string) (*EmailBody, error) {
+	req, err := p.authorizedRequest(ctx, address, "/messages/"+messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %d", resp.StatusCode)
+	}
+
+	var emailBody EmailBody
+	if err := json.NewDecoder(resp.Body).Decode(&emailBody); err != nil {
+		return nil, fmt.Errorf("failed to parse message: %v", err)
+	}
+
+	return &emailBody, nil
+}
+
+func (p *MailTMProvider) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.client.Do(req)
+}
+
+func (p *MailTMProvider) authorizedRequest(ctx context.Context, address string, path string) (*http.Request, error) {
+	p.mu.Lock()
+	account, ok := p.accounts[address]
+	p.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no %s account for %s", p.name, address)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+account.token)
+	return req, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"bcdl-app/backend/models"
+)
+
+// fillAlbumMetadata copies what the album page's data-tralbum and ld+json
+// blocks say about the release into album. Either may be nil. Values already
+// read from the artist page are kept, and Status must be set beforehand so
+// Price can be described
+func fillAlbumMetadata(album *models.Album, tralbum *tralbumData, ld *ldJSONData) {
+	if tralbum != nil {
+		album.ID = tralbum.ID
+		album.Type = tralbum.ItemType
+		if album.Title == "" {
+			album.Title = tralbum.Current.Title
+		}
+		if album.Artist == "" {
+			album.Artist = tralbum.Artist
+		}
+		if date := tralbum.ReleaseDate(); !date.IsZero() {
+			album.ReleaseDate = date.Format("2006-01-02")
+		}
+		album.About = strings.TrimSpace(tralbum.Current.About)
+		album.Credits = strings.TrimSpace(tralbum.Current.Credits)
+		album.MinimumPrice = tralbum.Current.MinimumPrice
+
+		album.Tracks = nil
+		for _, track := range tralbum.Trackinfo {
+			album.Tracks = append(album.Tracks, models.Track{
+				ID:       track.ID,
+				Number:   track.TrackNum,
+				Title:    track.Title,
+				Duration: track.Duration,
+			})
+		}
+	}
+
+	if ld != nil {
+		album.Label = ld.Label()
+		album.Catalog = ld.CatalogNumber()
+		album.Tags = ld.Tags()
+		album.Currency = ld.Currency()
+		if album.ReleaseDate == "" {
+			if date, err := time.Parse(tralbumDateLayout, ld.DatePublished); err == nil {
+				album.ReleaseDate = date.Format("2006-01-02")
+			}
+		}
+		if album.About == "" {
+			album.About = strings.TrimSpace(ld.Description)
+		}
+		if album.Credits == "" {
+			album.Credits = strings.TrimSpace(ld.CreditText)
+		}
+	}
+
+	album.Price = describePrice(album.Status, album.MinimumPrice, album.Currency)
+}
+
+// describePrice returns the price as shown on the buy button
+func describePrice(status string, minimum float64, currency string) string {
+	switch status {
+	case "free":
+		return "Free"
+	case "nyp":
+		return "Name your price"
+	}
+	if minimum <= 0 {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%.2f %s", minimum, currency))
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Path length limits, in bytes. Segments stay under the 255 byte limit of
+// most filesystems, and whole paths leave room for the download dir within
+// Windows' 260 character MAX_PATH
+const (
+	maxSegmentBytes = 200
+	maxPathBytes    = 200
+	minSegmentBytes = 16 // Never shorten a segment below this to fit a path
+)
+
+// NamingFields are the values naming templates can refer to as {artist},
+// {album}, {title}, {label}, {format}, {catalog}, {year} and {track}
+type NamingFields struct {
+	Artist  string
+	Album   string
+	Title   string // Track title, empty for album-level names
+	Label   string
+	Format  string
+	Catalog string
+	Year    int
+	Track   int // Rendered zero-padded to two digits
+}
+
+var templateField = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ValidateTemplate reports unknown or malformed fields in a naming template
+func ValidateTemplate(tmpl string) error {
+	if strings.Count(tmpl, "{") != strings.Count(tmpl, "}") {
+		return fmt.Errorf("template %q has unbalanced braces", tmpl)
+	}
+	for _, m := range templateField.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := (NamingFields{}).field(m[1]); !ok {
+			return fmt.Errorf("template %q has unknown field {%s}", tmpl, m[1])
+		}
+	}
+	return nil
+}
+
+func (f NamingFields) field(name string) (string, bool) {
+	switch name {
+	case "artist":
+		return f.Artist, true
+	case "album":
+		return f.Album, true
+	case "title":
+		return f.Title, true
+	case "label":
+		return f.Label, true
+	case "format":
+		return f.Format, true
+	case "catalog":
+		return f.Catalog, true
+	case "year":
+		if f.Year > 0 {
+			return strconv.Itoa(f.Year), true
+		}
+		return "", true
+	case "track":
+		if f.Track > 0 {
+			return fmt.Sprintf("%02d", f.Track), true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// RenderPath fills in tmpl and returns a relative path. Each "/"-separated
+// segment is rendered and sanitized on its own, so values containing slashes
+// can't add folders, and the result is shortened to fit the length limits
+func RenderPath(tmpl string, f NamingFields) string {
+	return renderSegments(tmpl, f, "")
+}
+
+// RenderFileName is RenderPath for a file: ext (e.g. ".flac") is appended to
+// the last segment and kept when shortening
+func RenderFileName(tmpl string, f NamingFields, ext string) string {
+	return renderSegments(tmpl, f, ext)
+}
+
+func renderSegments(tmpl string, f NamingFields, ext string) string {
+	var segments []string
+	for _, segment := range strings.Split(filepath.ToSlash(tmpl), "/") {
+		if segment == "" {
+			continue
+		}
+		segments = append(segments, renderSegment(segment, f))
+	}
+	if len(segments) == 0 {
+		segments = []string{""}
+	}
+
+	last := len(segments) - 1
+	if segments[last] == "" {
+		segments[last] = "_"
+	}
+	for i := range segments {
+		if i == last {
+			segments[i] = sanitizeFileName(segments[i] + ext)
+		} else {
+			segments[i] = sanitizePathSegment(segments[i])
+		}
+	}
+	fitPath(segments, ext)
+	return filepath.Join(segments...)
+}
+
+// separators are the characters templates put between fields
+const separators = " -_.,"
+
+// renderSegment fills in one segment of a template, dropping the separators
+// and brackets around empty fields, so "{year} - {album}" without a year
+// becomes "Album" rather than " - Album" and "{album} ({year})" becomes
+// "Album". Field values are kept as they are
+func renderSegment(tmpl string, f NamingFields) string {
+	// Template text and field values alternate, starting and ending with text
+	var parts []string
+	last := 0
+	for _, m := range templateField.FindAllStringSubmatchIndex(tmpl, -1) {
+		value, _ := f.field(tmpl[m[2]:m[3]])
+		parts = append(parts, tmpl[last:m[0]], value)
+		last = m[1]
+	}
+	parts = append(parts, tmpl[last:])
+
+	for i := 1; i < len(parts); i += 2 {
+		if parts[i] != "" {
+			continue
+		}
+		before := strings.TrimRight(parts[i-1], " ")
+		after := strings.TrimLeft(parts[i+1], " ")
+		if closing := bracketPair(before); closing != "" && strings.HasPrefix(after, closing) {
+			parts[i-1] = strings.TrimRight(before[:len(before)-1], " ")
+			parts[i+1] = after[len(closing):]
+			continue
+		}
+		// Keep the separator before the field only if a value follows it
+		if lastField(parts, i) {
+			parts[i-1] = strings.TrimRight(parts[i-1], separators)
+		} else {
+			parts[i+1] = strings.TrimLeft(parts[i+1], separators)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, ""))
+}
+
+// bracketPair returns the bracket closing the one s ends with, if any
+func bracketPair(s string) string {
+	switch {
+	case strings.HasSuffix(s, "("):
+		return ")"
+	case strings.HasSuffix(s, "["):
+		return "]"
+	}
+	return ""
+}
+
+// lastField reports whether the fields after parts[i] are all empty
+func lastField(parts []string, i int) bool {
+	for j := i + 2; j < len(parts); j += 2 {
+		if parts[j] != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// windowsReserved are device names Windows refuses as file names, with or
+// without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizePathSegment makes s safe as a single folder name on Windows,
+// macOS, Linux and FAT filesystems, and short enough for them
+func sanitizePathSegment(s string) string {
+	return cleanName(truncateBytes(cleanName(s), maxSegmentBytes))
+}
+
+// sanitizeFileName is sanitizePathSegment for a file name, keeping the
+// extension when the name has to be shortened
+func sanitizeFileName(name string) string {
+	name = cleanName(name)
+	if len(name) <= maxSegmentBytes {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	stem := truncateBytes(strings.TrimSuffix(name, ext), maxSegmentBytes-len(ext))
+	return cleanName(stem) + ext
+}
+
+// cleanName normalizes Unicode to NFC, replaces characters that are reserved
+// on common filesystems, trims leading and trailing dots and spaces, and
+// avoids Windows device names
+func cleanName(s string) string {
+	s = norm.NFC.String(s)
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.Trim(s, " .")
+	if s == "" {
+		return "_"
+	}
+
+	stem := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		stem = s[:i]
+	}
+	if windowsReserved[strings.ToUpper(stem)] {
+		s = "_" + s
+	}
+	return s
+}
+
+// fitPath shortens the longest segments until the joined path fits in
+// maxPathBytes, keeping the last segment's extension
+func fitPath(segments []string, ext string) {
+	for {
+		total := len(segments) - 1 // Separators
+		for _, s := range segments {
+			total += len(s)
+		}
+		excess := total - maxPathBytes
+		if excess <= 0 {
+			return
+		}
+
+		longest := -1
+		for i, s := range segments {
+			if len(s) > minSegmentBytes && (longest < 0 || len(s) > len(segments[longest])) {
+				longest = i
+			}
+		}
+		if longest < 0 {
+			return
+		}
+
+		s := segments[longest]
+		keep := len(s) - excess
+		if keep < minSegmentBytes {
+			keep = minSegmentBytes
+		}
+		if longest == len(segments)-1 && ext != "" && strings.HasSuffix(s, ext) {
+			stem := strings.TrimSuffix(s, ext)
+			segments[longest] = cleanName(truncateBytes(stem, keep-len(ext))) + ext
+		} else {
+			segments[longest] = cleanName(truncateBytes(s, keep))
+		}
+		if len(segments[longest]) == len(s) {
+			return
+		}
+	}
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateBytes(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
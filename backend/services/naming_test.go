@@ -0,0 +1,111 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderPath(t *testing.T) {
+	album := NamingFields{Artist: "Artist", Album: "Album", Year: 2023, Catalog: "FIX001", Format: "flac"}
+	noYear := album
+	noYear.Year = 0
+	noCatalog := album
+	noCatalog.Catalog = ""
+
+	for _, tc := range []struct {
+		name string
+		tmpl string
+		f    NamingFields
+		want string
+	}{
+		{"layout", "{artist}/{album}", album, "Artist/Album"},
+		{"all fields", "{artist}/{year} - {album} [{catalog}]", album, "Artist/2023 - Album [FIX001]"},
+		{"empty leading field", "{year} - {album}", noYear, "Album"},
+		{"empty middle field", "{artist} - {year} - {album}", noYear, "Artist - Album"},
+		{"empty trailing field", "{album} - {year}", noYear, "Album"},
+		{"empty bracketed field", "{album} ({year})", noYear, "Album"},
+		{"empty bracketed field in the middle", "{album} [{catalog}] {format}", noCatalog, "Album flac"},
+		{"brackets in a value", "{year} - {album}", NamingFields{Album: "Songs () []", Year: 2023}, "2023 - Songs () []"},
+		{"dashes in a value", "{album}", NamingFields{Album: "-_- Faces"}, "-_- Faces"},
+		{"slashes in a value", "{artist}/{album}", NamingFields{Artist: "AC/DC", Album: `Back\Black`}, "AC_DC/Back_Black"},
+		{"reserved name", "{artist}/{album}", NamingFields{Artist: "CON", Album: "nul"}, "_CON/_nul"},
+		{"empty segment", "{label}/{album}", NamingFields{Album: "Album"}, "_/Album"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := RenderPath(tc.tmpl, tc.f); got != filepath.FromSlash(tc.want) {
+				t.Errorf("RenderPath(%q) = %q, want %q", tc.tmpl, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderFileName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tmpl string
+		f    NamingFields
+		want string
+	}{
+		{"track", "{track} {title}", NamingFields{Track: 3, Title: "Song"}, "03 Song.flac"},
+		{"no track number", "{track}. {title}", NamingFields{Title: "Song"}, "Song.flac"},
+		{"reserved name with extension", "{title}", NamingFields{Title: "NUL"}, "_NUL.flac"},
+		{"reserved name with an extension of its own", "{title}", NamingFields{Title: "nul.txt"}, "_nul.txt.flac"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := RenderFileName(tc.tmpl, tc.f, ".flac"); got != tc.want {
+				t.Errorf("RenderFileName(%q) = %q, want %q", tc.tmpl, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	for name, want := range map[string]string{
+		"CON":           "_CON",
+		"NUL.txt":       "_NUL.txt",
+		"Console.txt":   "Console.txt",
+		"a/b\\c:d?.mp3": "a_b_c_d_.mp3",
+		" .hidden. ":    "hidden",
+		"..":            "_",
+	} {
+		if got := sanitizeFileName(name); got != want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLongNamesAreShortened(t *testing.T) {
+	long := strings.Repeat("日本語", 50) // 450 bytes, 3 per character
+
+	name := sanitizeFileName(long + ".flac")
+	if len(name) > maxSegmentBytes || !utf8.ValidString(name) || !strings.HasSuffix(name, ".flac") {
+		t.Errorf("sanitizeFileName = %q (%d bytes), want valid UTF-8 under %d bytes ending in .flac", name, len(name), maxSegmentBytes)
+	}
+
+	for _, tc := range []struct {
+		name string
+		tmpl string
+		f    NamingFields
+	}{
+		{"long file name", "{artist}/{album}/{title}", NamingFields{Artist: "Artist", Album: "Album", Title: long}},
+		{"long folders", "{artist}/{album}/{title}", NamingFields{Artist: long, Album: long, Title: "Song"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := RenderFileName(tc.tmpl, tc.f, ".flac")
+			if len(path) > maxPathBytes {
+				t.Errorf("%d bytes, want at most %d", len(path), maxPathBytes)
+			}
+			if !utf8.ValidString(path) {
+				t.Errorf("%q split a character", path)
+			}
+			if !strings.HasSuffix(path, ".flac") {
+				t.Errorf("%q lost its extension", path)
+			}
+			if got := len(strings.Split(path, string(filepath.Separator))); got != 3 {
+				t.Errorf("%q has %d segments, want 3", path, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultLayout is the folder, relative to the download dir, that organized
+// downloads are placed in
+const DefaultLayout = "{artist}/{album}"
+
+// audioExtensions are the files in an album archive that are named with
+// FileTemplate; anything else (cover art, booklets) keeps its own name
+var audioExtensions = map[string]bool{
+	".flac": true, ".mp3": true, ".ogg": true, ".m4a": true, ".aac": true,
+	".alac": true, ".wav": true, ".aif": true, ".aiff": true, ".opus": true,
+}
+
+// ReleaseInfo is the album metadata used to lay out downloaded files
+type ReleaseInfo struct {
+	ID      int64 // Bandcamp's album id, 0 when unknown
+	Artist  string
+	Album   string
+	Label   string
+	Catalog string
+	Year    int // 0 when unknown
+	Tracks  []TrackInfo
+}
+
+// TrackInfo is a track listed on the album page
+type TrackInfo struct {
+	Number int
+	Title  string
+}
+
+// fields returns the naming fields for the release, or for one of its tracks
+// when track is non-zero
+func (r ReleaseInfo) fields(format string, track int, title string) NamingFields {
+	for _, t := range r.Tracks {
+		if track > 0 && t.Number == track && t.Title != "" {
+			title = t.Title
+		}
+	}
+	return NamingFields{
+		Artist:  r.Artist,
+		Album:   r.Album,
+		Title:   title,
+		Label:   r.Label,
+		Format:  format,
+		Catalog: r.Catalog,
+		Year:    r.Year,
+		Track:   track,
+	}
+}
+
+// PostProcessOptions controls what happens to a file once it is saved. The
+// downloader holds the global defaults; a job may carry its own
+type PostProcessOptions struct {
+	// Organize extracts ZIP archives, or moves single files, into Layout
+	// under the download dir
+	Organize bool `json:"organize"`
+	// Layout is the folder template, e.g. "{artist}/{year} - {album}"
+	Layout string `json:"layout,omitempty"`
+	// FileTemplate renames audio files, e.g. "{track} - {title}". Files keep
+	// their original (sanitized) names when empty
+	FileTemplate string `json:"fileTemplate,omitempty"`
+	// DeleteArchive removes the ZIP after a successful extraction
+	DeleteArchive bool `json:"deleteArchive"`
+}
+
+// PostProcessOptionsFromEnv reads BCDL_ORGANIZE, BCDL_LAYOUT,
+// BCDL_FILE_TEMPLATE and BCDL_DELETE_ARCHIVE. Organizing is off unless
+// BCDL_ORGANIZE is set
+func PostProcessOptionsFromEnv() PostProcessOptions {
+	opts := PostProcessOptions{Layout: DefaultLayout}
+	if v, err := strconv.ParseBool(os.Getenv("BCDL_ORGANIZE")); err == nil {
+		opts.Organize = v
+	}
+	if v := os.Getenv("BCDL_LAYOUT"); v != "" {
+		opts.Layout = v
+	}
+	opts.FileTemplate = os.Getenv("BCDL_FILE_TEMPLATE")
+	if v, err := strconv.ParseBool(os.Getenv("BCDL_DELETE_ARCHIVE")); err == nil {
+		opts.DeleteArchive = v
+	}
+	return opts
+}
+
+// Validate checks the templates
+func (o PostProcessOptions) Validate() error {
+	if err := ValidateTemplate(o.Layout); err != nil {
+		return err
+	}
+	return ValidateTemplate(o.FileTemplate)
+}
+
+// withDefaults fills the templates left empty from defaults
+func (o PostProcessOptions) withDefaults(defaults PostProcessOptions) PostProcessOptions {
+	if o.Layout == "" {
+		o.Layout = defaults.Layout
+	}
+	if o.FileTemplate == "" {
+		o.FileTemplate = defaults.FileTemplate
+	}
+	return o
+}
+
+// postProcess organizes the file saved at path according to opts and returns
+// the folder or file the download ended up in. Cancelling ctx stops the
+// extraction and removes what was extracted so far
+func postProcess(ctx context.Context, path, downloadDir, format string, info ReleaseInfo, opts PostProcessOptions, progress *progressReporter) (string, error) {
+	if !opts.Organize {
+		return path, nil
+	}
+
+	layout := opts.Layout
+	if layout == "" {
+		layout = DefaultLayout
+	}
+	if info.Artist == "" {
+		info.Artist = "Unknown Artist"
+	}
+	if info.Album == "" {
+		info.Album = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	targetDir := filepath.Join(downloadDir, RenderPath(layout, info.fields(format, 0, "")))
+
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		// Single tracks aren't zipped, move them into the same layout
+		if err := os.MkdirAll(targetDir, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %v", targetDir, err)
+		}
+		name := filepath.Base(path)
+		if opts.FileTemplate != "" {
+			track, title := parseTrackName(name, info)
+			if track == 0 && len(info.Tracks) == 1 {
+				track, title = info.Tracks[0].Number, info.Tracks[0].Title
+			}
+			name = RenderFileName(opts.FileTemplate, info.fields(format, track, title), filepath.Ext(name))
+		}
+		dest := uniquePath(filepath.Join(targetDir, name))
+		progress.Message(fmt.Sprintf("Moving to: %s", dest))
+		if err := os.Rename(path, dest); err != nil {
+			return "", fmt.Errorf("failed to move %s: %v", path, err)
+		}
+		return dest, nil
+	}
+
+	progress.Phase(PhaseExtracting, "Verifying archive...")
+	if err := verifyZip(path, targetDir); err != nil {
+		return "", fmt.Errorf("failed to verify archive %s: %v", path, err)
+	}
+	if err := extractZip(ctx, path, targetDir, func(name string) string {
+		return archiveEntryName(name, format, info, opts)
+	}, progress); err != nil {
+		return "", err
+	}
+
+	if opts.DeleteArchive {
+		if err := os.Remove(path); err != nil {
+			log.Printf("Downloader: Failed to delete archive %s: %v", path, err)
+		}
+	}
+	progress.Message(fmt.Sprintf("Extracted to: %s", targetDir))
+	return targetDir, nil
+}
+
+// archiveEntryName is the path, relative to the target folder, an archive
+// entry is extracted to. The file template only renames audio files, they
+// stay in their folder, e.g. "Disc 2"
+func archiveEntryName(name, format string, info ReleaseInfo, opts PostProcessOptions) string {
+	var segments []string
+	for _, segment := range strings.Split(path.Clean(name), "/") {
+		segments = append(segments, sanitizeFileName(segment))
+	}
+
+	ext := filepath.Ext(name)
+	if opts.FileTemplate != "" && audioExtensions[strings.ToLower(ext)] {
+		track, title := parseTrackName(path.Base(name), info)
+		segments[len(segments)-1] = RenderFileName(opts.FileTemplate, info.fields(format, track, title), ext)
+	}
+	return filepath.Join(segments...)
+}
+
+// trackName matches the "NN Title" part of Bandcamp's track file names
+var trackName = regexp.MustCompile(`^(\d{1,3})[ .\-]+(.*)$`)
+
+// parseTrackName gets the track number and title from a file name such as
+// "Artist - Album - 03 Title.flac". The number is 0 if there isn't one
+func parseTrackName(name string, info ReleaseInfo) (int, string) {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	for _, prefix := range []string{info.Artist + " - " + info.Album + " - ", info.Artist + " - "} {
+		stem = strings.TrimPrefix(stem, prefix)
+	}
+	if m := trackName.FindStringSubmatch(stem); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n, m[2]
+	}
+	return 0, stem
+}
+
+// verifyZip reads every entry, which checks the CRC of each file, and rejects
+// the archive if any entry would be extracted outside targetDir
+func verifyZip(path, targetDir string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if _, err := safeJoin(targetDir, f.Name); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractZip extracts the archive at archivePath into targetDir, naming each
+// file with rename. Files already extracted, and the folders created for
+// them, are removed if it fails or ctx is done before the last one
+func extractZip(ctx context.Context, archivePath, targetDir string, rename func(name string) string, progress *progressReporter) (err error) {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var extracted, created []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, path := range extracted {
+			os.Remove(path)
+		}
+		// Deepest first; folders that something else has put files in stay
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+	}()
+
+	mkdir := func(dir string) error {
+		dirs, err := mkdirAll(dir)
+		created = append(created, dirs...)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+		return nil
+	}
+	if err := mkdir(targetDir); err != nil {
+		return err
+	}
+
+	for i, f := range r.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			log.Printf("Downloader: Skipping non-regular archive entry %s", f.Name)
+			continue
+		}
+
+		dest, err := safeJoin(targetDir, rename(f.Name))
+		if err != nil {
+			return err
+		}
+		progress.Message(fmt.Sprintf("Extracting %d/%d: %s", i+1, len(r.File), f.Name))
+		if err := mkdir(filepath.Dir(dest)); err != nil {
+			return err
+		}
+		dest = uniquePath(dest)
+		if err := extractFile(f, dest); err != nil {
+			return fmt.Errorf("failed to extract %s: %v", f.Name, err)
+		}
+		extracted = append(extracted, dest)
+	}
+	return nil
+}
+
+// mkdirAll is os.MkdirAll that also returns the folders it created, parents
+// first
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		missing = append([]string{d}, missing...)
+	}
+	// Some of them may exist even if it fails
+	return missing, os.MkdirAll(dir, 0o755)
+}
+
+func extractFile(f *zip.File, dest string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+// safeJoin joins an archive entry name onto root, rejecting names that would
+// land outside it (zip-slip)
+func safeJoin(root, name string) (string, error) {
+	// Archives made on Windows may have backslashes and drive letters, which
+	// are checked for on every platform alike
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	cleaned := filepath.FromSlash(slashed)
+	if path.IsAbs(slashed) || hasDriveLetter(slashed) || filepath.IsAbs(cleaned) || filepath.VolumeName(cleaned) != "" {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+
+	dest := filepath.Join(root, cleaned)
+	rel, err := filepath.Rel(root, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes the target folder", name)
+	}
+	return dest, nil
+}
+
+// hasDriveLetter reports whether name starts like C: on Windows
+func hasDriveLetter(name string) bool {
+	return len(name) >= 2 && name[1] == ':' &&
+		(name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z')
+}
+
+// uniquePath returns path, or "name (2).ext", "name (3).ext", ... if it is
+// already taken
+func uniquePath(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeZip writes an archive holding files, in order, and returns its path
+func writeZip(t *testing.T, files ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "album.zip")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+	for _, name := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(name))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func keepName(name string) string { return name }
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "album")
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{"01 Track.flac", true},
+		{"disc 1/01 Track.flac", true},
+		{"a/../01 Track.flac", true},
+		{"../evil.flac", false},
+		{"a/../../evil.flac", false},
+		{`..\evil.flac`, false},
+		{"/etc/passwd", false},
+		{`\evil.flac`, false},
+		{`C:\Windows\evil.dll`, false},
+		{"C:evil.flac", false},
+		{"..", false},
+	} {
+		dest, err := safeJoin(root, tc.name)
+		if (err == nil) != tc.ok {
+			t.Errorf("safeJoin(%q) = %q, %v, want ok %v", tc.name, dest, err, tc.ok)
+		}
+	}
+}
+
+func TestExtractZipNumbersClashes(t *testing.T) {
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "01 Track.flac"), []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := writeZip(t, "01 Track.flac")
+
+	if err := extractZip(context.Background(), archive, target, keepName, newProgressReporter(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "01 Track.flac")); string(data) != "mine" {
+		t.Errorf("existing file overwritten with %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(target, "01 Track (2).flac")); err != nil {
+		t.Errorf("clashing entry not numbered: %v", err)
+	}
+}
+
+func TestExtractZipCleansUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel bool // Cancel while the second file is extracted
+		want   error
+	}{
+		{"failure", false, nil},
+		{"cancel", true, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			target := filepath.Join(root, "Artist", "Album")
+			// The last entry escapes the target folder, failing the extraction
+			archive := writeZip(t, "disc 1/01 One.flac", "disc 2/01 Two.flac", "../evil.flac")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			seen := 0
+			rename := func(name string) string {
+				if seen++; seen == 2 && tt.cancel {
+					cancel()
+				}
+				return name
+			}
+
+			err := extractZip(ctx, archive, target, rename, newProgressReporter(nil))
+			if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if entries, _ := os.ReadDir(root); len(entries) != 0 {
+				t.Errorf("left %d entries behind, want the folders created for the archive removed", len(entries))
+			}
+		})
+	}
+}
+
+func TestPostProcessKeepsDiscFolders(t *testing.T) {
+	archive := writeZip(t,
+		"Disc 1/Artist - Album - 01 Intro.flac",
+		"Disc 2/Artist - Album - 01 Outro.flac",
+		"cover.jpg",
+	)
+	downloadDir := t.TempDir()
+	info := ReleaseInfo{Artist: "Artist", Album: "Album"}
+	opts := PostProcessOptions{Organize: true, FileTemplate: "{track} {title}"}
+
+	target, err := postProcess(context.Background(), archive, downloadDir, "flac", info, opts, newProgressReporter(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		filepath.Join("Disc 1", "01 Intro.flac"),
+		filepath.Join("Disc 2", "01 Outro.flac"),
+		"cover.jpg",
+	} {
+		if _, err := os.Stat(filepath.Join(target, name)); err != nil {
+			t.Errorf("%s not extracted: %v", name, err)
+		}
+	}
+}
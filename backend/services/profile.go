@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// SiteProfileVersion is the newest site profile format this build understands
+const SiteProfileVersion = 1
+
+// defaultSiteProfile is the profile built into the binary. A user's profile
+// file only needs the fields it changes
+//
+//go:embed siteprofile.json
+var defaultSiteProfile []byte
+
+// SiteProfile holds the selectors, timeouts and text matchers the browser
+// flows use to find their way around Bandcamp's pages, so a markup change can
+// be fixed with a profile file instead of a new binary
+type SiteProfile struct {
+	Version  int             `json:"version"`  // Format version, see SiteProfileVersion
+	Revision string          `json:"revision"` // Identifies the profile's content, e.g. a date
+	Scan     ScanProfile     `json:"scan"`
+	Download DownloadProfile `json:"download"`
+}
+
+// ScanProfile is what the browser scanner looks for. The buy header and
+// button are read inside the page, so they must be plain CSS selectors
+type ScanProfile struct {
+	MusicGrid ElementProfile `json:"musicGrid"`
+	BuyHeader ElementProfile `json:"buyHeader"`
+	BuyButton ElementProfile `json:"buyButton"` // Within BuyHeader
+	NYPText   []string       `json:"nypText"`   // Buy header or button text of name-your-price releases
+	FreeText  []string       `json:"freeText"`  // Buy header or button text of free releases
+}
+
+// DownloadProfile is what the download flow looks for, step by step
+type DownloadProfile struct {
+	RegionBlocked     ElementProfile `json:"regionBlocked"`
+	CookieAccept      ElementProfile `json:"cookieAccept"`
+	CookieSettle      Duration       `json:"cookieSettle"` // Wait after accepting cookies
+	Title             ElementProfile `json:"title"`
+	BuyButton         ElementProfile `json:"buyButton"`
+	PriceInput        ElementProfile `json:"priceInput"`
+	FreeDownloadLink  ElementProfile `json:"freeDownloadLink"`
+	EmailInput        ElementProfile `json:"emailInput"`
+	PostcodeInput     ElementProfile `json:"postcodeInput"`
+	Postcode          string         `json:"postcode"` // Filled into PostcodeInput
+	EmailSubmit       ElementProfile `json:"emailSubmit"`
+	EmailPolls        int            `json:"emailPolls"` // Inbox checks before giving up on the email
+	EmailPollInterval Duration       `json:"emailPollInterval"`
+	FormatSelect      ElementProfile `json:"formatSelect"`
+	FormatOption      ElementProfile `json:"formatOption"`   // Entries of a FormatSelect that isn't a <select>, picked by their text
+	FallbackFormat    string         `json:"fallbackFormat"` // Picked when the requested format isn't offered, "" for none
+	DownloadButton    ElementProfile `json:"downloadButton"`
+}
+
+// ElementProfile finds one element on a page. Its Playwright selectors are
+// fallbacks for each other, listed in order of preference
+type ElementProfile struct {
+	Selectors []string `json:"selectors"`
+	Timeout   Duration `json:"timeout,omitempty"`  // How long to wait for it to show up
+	Optional  bool     `json:"optional,omitempty"` // Only shows up on some pages, so validate-profile doesn't require it
+}
+
+// Duration is a time.Duration written as a string like "5s" in profiles
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// NamedElement is an element of a profile with its name in the profile file
+type NamedElement struct {
+	Name    string
+	Element ElementProfile
+	Waited  bool // The flows wait up to its timeout for it, rather than only look
+}
+
+// Elements lists the profile's elements, named as in the profile file
+func (p *SiteProfile) Elements() []NamedElement {
+	d := p.Download
+	return []NamedElement{
+		{"scan.musicGrid", p.Scan.MusicGrid, true},
+		{"scan.buyHeader", p.Scan.BuyHeader, false},
+		{"scan.buyButton", p.Scan.BuyButton, false},
+		{"download.regionBlocked", d.RegionBlocked, false},
+		{"download.cookieAccept", d.CookieAccept, true},
+		{"download.title", d.Title, true},
+		{"download.buyButton", d.BuyButton, true},
+		{"download.priceInput", d.PriceInput, true},
+		{"download.freeDownloadLink", d.FreeDownloadLink, true},
+		{"download.emailInput", d.EmailInput, true},
+		{"download.postcodeInput", d.PostcodeInput, true},
+		{"download.emailSubmit", d.EmailSubmit, true},
+		{"download.formatSelect", d.FormatSelect, true},
+		{"download.formatOption", d.FormatOption, true},
+		{"download.downloadButton", d.DownloadButton, true},
+	}
+}
+
+// Validate reports a profile this build can't use
+func (p *SiteProfile) Validate() error {
+	if p.Version < 1 || p.Version > SiteProfileVersion {
+		return fmt.Errorf("site profile version %d is not supported, this build reads up to version %d", p.Version, SiteProfileVersion)
+	}
+
+	var errs []error
+	for _, el := range p.Elements() {
+		if len(el.Element.Selectors) == 0 {
+			errs = append(errs, fmt.Errorf("%s has no selectors", el.Name))
+		}
+		for _, sel := range el.Element.Selectors {
+			if strings.TrimSpace(sel) == "" {
+				errs = append(errs, fmt.Errorf("%s has an empty selector", el.Name))
+			}
+		}
+		if el.Element.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s has a negative timeout", el.Name))
+		} else if el.Waited && el.Element.Timeout == 0 {
+			// Playwright takes a zero timeout as no timeout at all
+			errs = append(errs, fmt.Errorf("%s needs a timeout", el.Name))
+		}
+	}
+	for _, sel := range append(append([]string{}, p.Scan.BuyHeader.Selectors...), p.Scan.BuyButton.Selectors...) {
+		if isPlaywrightOnly(sel) {
+			errs = append(errs, fmt.Errorf("scan selector %q must be plain CSS", sel))
+		}
+	}
+	if len(p.Scan.NYPText) == 0 || len(p.Scan.FreeText) == 0 {
+		errs = append(errs, fmt.Errorf("scan.nypText and scan.freeText need at least one text"))
+	}
+	if p.Download.EmailPolls < 1 || p.Download.EmailPollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("download.emailPolls and download.emailPollInterval must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// isPlaywrightOnly reports whether sel uses Playwright's own selector engines,
+// which document.querySelector doesn't understand
+func isPlaywrightOnly(sel string) bool {
+	sel = strings.TrimSpace(sel)
+	for _, prefix := range []string{"text=", "xpath=", "internal:", "//"} {
+		if strings.HasPrefix(sel, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(sel, ":has-text(") || strings.Contains(sel, ":text(")
+}
+
+// DefaultSiteProfile returns a copy of the built-in profile
+func DefaultSiteProfile() *SiteProfile {
+	var p SiteProfile
+	if err := json.Unmarshal(defaultSiteProfile, &p); err != nil {
+		panic(fmt.Sprintf("built-in site profile is invalid: %v", err))
+	}
+	return &p
+}
+
+// LoadSiteProfile applies a profile file on top of the built-in profile.
+// Fields the file leaves out keep their built-in values; lists are replaced
+// as a whole
+func LoadSiteProfile(data []byte) (*SiteProfile, error) {
+	p := DefaultSiteProfile()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // A misspelled step would silently keep the built-in value
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to parse site profile: %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SiteProfileStore serves the site profile, reloading the user's profile
+// file whenever it changes. While the file is missing or broken the
+// built-in profile, or the last good version of the file, is used
+type SiteProfileStore struct {
+	path string // "" to only use the built-in profile
+
+	mu      sync.Mutex
+	profile *SiteProfile
+	modTime time.Time
+	size    int64
+}
+
+// NewSiteProfileStore creates a store overriding the built-in profile with
+// the file at path, if there is one
+func NewSiteProfileStore(path string) *SiteProfileStore {
+	return &SiteProfileStore{path: path, profile: DefaultSiteProfile()}
+}
+
+// NewSiteProfileStoreFromEnv reads the profile file from BCDL_SITE_PROFILE, or
+// site-profile.json in the data dir
+func NewSiteProfileStoreFromEnv() *SiteProfileStore {
+	path := os.Getenv("BCDL_SITE_PROFILE")
+	if path == "" {
+		if dir, err := DataDir(); err == nil {
+			path = filepath.Join(dir, "site-profile.json")
+		}
+	}
+	return NewSiteProfileStore(path)
+}
+
+// Path is the user's profile file, "" when there is none
+func (s *SiteProfileStore) Path() string {
+	return s.path
+}
+
+// Current returns the profile to use now. Callers keep it for the whole scan
+// or download so a reload never mixes two profiles in one flow
+func (s *SiteProfileStore) Current() *SiteProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		return s.profile
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if !s.modTime.IsZero() {
+			log.Printf("SiteProfile: %s is gone, using the built-in profile", s.path)
+			s.profile = DefaultSiteProfile()
+			s.modTime, s.size = time.Time{}, 0
+		}
+		return s.profile
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.profile
+	}
+
+	s.modTime, s.size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(s.path)
+	if err == nil {
+		var profile *SiteProfile
+		if profile, err = LoadSiteProfile(data); err == nil {
+			log.Printf("SiteProfile: Loaded %s (revision %s)", s.path, profile.Revision)
+			s.profile = profile
+			return s.profile
+		}
+	}
+	log.Printf("SiteProfile: Keeping the previous profile, %s is invalid: %v", s.path, err)
+	return s.profile
+}
+
+// locator matches any of the element's selectors, keeping the matches that
+// contain text unless it is ""
+func (e ElementProfile) locator(page pw.Page, text string) pw.Locator {
+	loc := matching(page, e.Selectors[0], text)
+	for _, sel := range e.Selectors[1:] {
+		loc = loc.Or(matching(page, sel, text))
+	}
+	return loc
+}
+
+func matching(page pw.Page, sel string, text string) pw.Locator {
+	loc := page.Locator(sel)
+	if text != "" {
+		loc = loc.Filter(pw.LocatorFilterOptions{HasText: text})
+	}
+	return loc
+}
+
+// timeoutMS is the element's timeout in milliseconds for Playwright
+func (e ElementProfile) timeoutMS() *float64 {
+	return pw.Float(float64(time.Duration(e.Timeout).Milliseconds()))
+}
+
+// count returns how many elements any of the selectors match, visible or not
+func (e ElementProfile) count(page pw.Page) int {
+	n, _ := e.locator(page, "").Count()
+	return n
+}
+
+// locate waits up to the element's timeout for one of its selectors to match
+// a visible element, and returns the first match of the most preferred
+// selector that has one
+func (e ElementProfile) locate(page pw.Page) (pw.Locator, error) {
+	return e.locateText(page, "")
+}
+
+// locateText is locate for the matches that contain text
+func (e ElementProfile) locateText(page pw.Page, text string) (pw.Locator, error) {
+	if loc, ok := e.firstVisible(page, text); ok {
+		return loc, nil
+	}
+	if err := e.locator(page, text).First().WaitFor(pw.LocatorWaitForOptions{Timeout: e.timeoutMS()}); err != nil {
+		return nil, err
+	}
+	if loc, ok := e.firstVisible(page, text); ok {
+		return loc, nil
+	}
+	return e.locator(page, text).First(), nil
+}
+
+func (e ElementProfile) firstVisible(page pw.Page, text string) (pw.Locator, bool) {
+	for _, sel := range e.Selectors {
+		loc := matching(page, sel, text).First()
+		if visible, _ := loc.IsVisible(); visible {
+			return loc, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bcdl-app/backend/fixtures"
+	"bcdl-app/backend/playwright"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// ProfileCheck is what validate-profile found for one element of a profile
+type ProfileCheck struct {
+	Element  string   `json:"element"`
+	Optional bool     `json:"optional,omitempty"`
+	Matched  []string `json:"matched"` // Fixture pages the element was found on
+}
+
+// Missing reports a required element no fixture page has
+func (c ProfileCheck) Missing() bool {
+	return !c.Optional && len(c.Matched) == 0
+}
+
+// CheckSiteProfile loads the HTML pages and DOM snapshots of each fixture
+// directory in the browser and reports where every element of profile is
+// found. Elements that only show up after interacting with a page count as
+// found when they are in the page, visible or not
+func CheckSiteProfile(ctx context.Context, pwService *playwright.Service, profile *SiteProfile, dirs []string) ([]ProfileCheck, error) {
+	elements := profile.Elements()
+	checks := make([]ProfileCheck, 0, len(elements)+2)
+	for _, el := range elements {
+		checks = append(checks, ProfileCheck{Element: el.Name, Optional: el.Element.Optional})
+	}
+	checks = append(checks,
+		ProfileCheck{Element: "scan.nypText"},
+		ProfileCheck{Element: "scan.freeText"},
+	)
+	texts := [][]string{profile.Scan.NYPText, profile.Scan.FreeText}
+
+	check := func(page pw.Page, name string) {
+		for i, el := range elements {
+			if el.Element.count(page) > 0 {
+				checks[i].Matched = append(checks[i].Matched, name)
+			}
+		}
+		content, err := page.TextContent("body")
+		if err != nil {
+			return
+		}
+		content = strings.ToLower(content)
+		for i, needles := range texts {
+			for _, needle := range needles {
+				if strings.Contains(content, strings.ToLower(needle)) {
+					checks[len(elements)+i].Matched = append(checks[len(elements)+i].Matched, name)
+					break
+				}
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		if err := checkFixtureDir(ctx, pwService, dir, check); err != nil {
+			return nil, err
+		}
+	}
+	return checks, nil
+}
+
+// checkFixtureDir serves dir and calls check with each of its HTML pages and
+// snapshots loaded
+func checkFixtureDir(ctx context.Context, pwService *playwright.Service, dir string, check func(page pw.Page, name string)) error {
+	srv, err := fixtures.NewServer(dir)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+	manifest, err := fixtures.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	lease, err := pwService.Lease(ctx, playwright.LeaseOptions{})
+	if err != nil {
+		return err
+	}
+	defer lease.Return()
+	page := lease.Page
+
+	for _, route := range manifest.Routes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if (route.Method != "" && !strings.EqualFold(route.Method, "GET")) || !strings.Contains(route.ContentType, "html") {
+			continue
+		}
+		pageURL := srv.URL() + route.Path
+		if route.Query != "" {
+			pageURL += "?" + route.Query
+		}
+		if _, err := page.Goto(pageURL, pw.PageGotoOptions{WaitUntil: pw.WaitUntilStateLoad}); err != nil {
+			log.Printf("SiteProfile: Failed to load %s: %v", pageURL, err)
+			continue
+		}
+		check(page, filepath.Join(dir, route.File))
+	}
+
+	for _, snap := range manifest.Snapshots {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(snap.File)))
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot: %v", err)
+		}
+		html := strings.ReplaceAll(string(data), fixtures.BasePlaceholder, srv.URL())
+		if err := page.SetContent(html); err != nil {
+			log.Printf("SiteProfile: Failed to load snapshot %s: %v", snap.File, err)
+			continue
+		}
+		check(page, filepath.Join(dir, snap.File))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultSiteProfileIsValid(t *testing.T) {
+	profile := DefaultSiteProfile()
+	if err := profile.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Version != SiteProfileVersion {
+		t.Errorf("built-in profile is version %d, want %d", profile.Version, SiteProfileVersion)
+	}
+}
+
+func TestLoadSiteProfile(t *testing.T) {
+	profile, err := LoadSiteProfile([]byte(`{
+		"revision": "test",
+		"download": {"title": {"selectors": ["h1.title"], "timeout": "2s"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Revision != "test" {
+		t.Errorf("revision = %q, want test", profile.Revision)
+	}
+	title := profile.Download.Title
+	if len(title.Selectors) != 1 || title.Selectors[0] != "h1.title" || time.Duration(title.Timeout) != 2*time.Second {
+		t.Errorf("title = %+v, want the override", title)
+	}
+	// Everything the file leaves out keeps its built-in value
+	builtin := DefaultSiteProfile()
+	if profile.Version != builtin.Version || profile.Scan.MusicGrid.Selectors[0] != builtin.Scan.MusicGrid.Selectors[0] {
+		t.Errorf("override lost the built-in values: %+v", profile)
+	}
+	if profile.Download.EmailPolls != builtin.Download.EmailPolls {
+		t.Errorf("emailPolls = %d, want %d", profile.Download.EmailPolls, builtin.Download.EmailPolls)
+	}
+}
+
+func TestLoadSiteProfileRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unknown field", `{"download": {"titel": {"selectors": ["h1"]}}}`, "unknown field"},
+		{"newer version", `{"version": 2}`, "not supported"},
+		{"no selectors", `{"scan": {"musicGrid": {"selectors": []}}}`, "scan.musicGrid has no selectors"},
+		{"playwright selector in scan", `{"scan": {"buyHeader": {"selectors": ["text=Buy"]}}}`, "plain CSS"},
+		{"bad duration", `{"download": {"cookieSettle": "soon"}}`, "duration"},
+		{"zero timeout", `{"download": {"title": {"selectors": ["h1"], "timeout": "0s"}}}`, "download.title needs a timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSiteProfile([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSiteProfileStoreReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "site-profile.json")
+	store := NewSiteProfileStore(path)
+	builtin := DefaultSiteProfile().Revision
+
+	if got := store.Current().Revision; got != builtin {
+		t.Fatalf("without a file revision = %q, want the built-in %q", got, builtin)
+	}
+
+	// Each write gets a new modification time, the store notices the change by it
+	stamp := time.Now()
+	write := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		stamp = stamp.Add(time.Second)
+		if err := os.Chtimes(path, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"revision": "one"}`)
+	if got := store.Current().Revision; got != "one" {
+		t.Errorf("revision = %q, want one", got)
+	}
+
+	write(`{"revision": "two"}`)
+	if got := store.Current().Revision; got != "two" {
+		t.Errorf("after an edit revision = %q, want two", got)
+	}
+
+	write(`{"revision": "broken",`)
+	if got := store.Current().Revision; got != "two" {
+		t.Errorf("after a broken edit revision = %q, want the previous two", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Current().Revision; got != builtin {
+		t.Errorf("after removing the file revision = %q, want the built-in %q", got, builtin)
+	}
+}
+
+func TestCheckSiteProfile(t *testing.T) {
+	pwService := newTestBrowser(t)
+
+	checks, err := CheckSiteProfile(context.Background(), pwService, DefaultSiteProfile(), []string{siteFixtures})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range checks {
+		if check.Missing() {
+			t.Errorf("%s matched no fixture page", check.Element)
+		}
+	}
+}
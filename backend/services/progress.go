@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ProgressPhase is the step of a download that is currently running
+type ProgressPhase string
+
+const (
+	PhaseNavigating   ProgressPhase = "navigating"
+	PhaseEmailWait    ProgressPhase = "email-wait"
+	PhasePreparing    ProgressPhase = "preparing"
+	PhaseTransferring ProgressPhase = "transferring"
+	PhaseExtracting   ProgressPhase = "extracting"
+)
+
+// Progress describes where a download is. The byte counts are only set while
+// transferring; BytesTotal is 0 when the server does not send a length
+type Progress struct {
+	Phase      ProgressPhase `json:"phase"`
+	Message    string        `json:"message"`
+	BytesDone  int64         `json:"bytesDone,omitempty"`
+	BytesTotal int64         `json:"bytesTotal,omitempty"`
+	Speed      float64       `json:"speed,omitempty"` // Bytes per second
+	ETA        float64       `json:"eta,omitempty"`   // Seconds remaining, 0 when unknown
+}
+
+// ProgressCallback is a function that receives progress updates
+type ProgressCallback func(p Progress)
+
+// progressReporter keeps track of the current phase so the download flow can
+// report plain status messages
+type progressReporter struct {
+	fn    ProgressCallback
+	phase ProgressPhase
+}
+
+func newProgressReporter(fn ProgressCallback) *progressReporter {
+	if fn == nil {
+		fn = func(Progress) {}
+	}
+	return &progressReporter{fn: fn, phase: PhaseNavigating}
+}
+
+// Phase switches to a new phase and reports msg
+func (r *progressReporter) Phase(phase ProgressPhase, msg string) {
+	r.phase = phase
+	r.Message(msg)
+}
+
+// Message reports msg in the current phase
+func (r *progressReporter) Message(msg string) {
+	r.fn(Progress{Phase: r.phase, Message: msg})
+}
+
+// progressInterval limits how often transfer progress is reported
+const progressInterval = 250 * time.Millisecond
+
+// transferMeter is an io.Writer that counts bytes and reports throughput and
+// ETA. Speed is smoothed so the ETA doesn't jump around on bursty connections
+type transferMeter struct {
+	report func(Progress)
+	total  int64
+	now    func() time.Time // time.Now, replaced in tests
+
+	done       int64
+	speed      float64
+	lastReport time.Time
+	lastDone   int64
+}
+
+func newTransferMeter(total int64, report func(Progress)) *transferMeter {
+	if total < 0 {
+		total = 0
+	}
+	return &transferMeter{
+		report:     report,
+		total:      total,
+		now:        time.Now,
+		lastReport: time.Now(),
+	}
+}
+
+func (m *transferMeter) Write(p []byte) (int, error) {
+	m.done += int64(len(p))
+	if elapsed := m.now().Sub(m.lastReport); elapsed >= progressInterval {
+		m.sample(elapsed)
+		m.emit()
+	}
+	return len(p), nil
+}
+
+func (m *transferMeter) sample(elapsed time.Duration) {
+	current := float64(m.done-m.lastDone) / elapsed.Seconds()
+	if m.speed == 0 {
+		m.speed = current
+	} else {
+		m.speed = 0.3*current + 0.7*m.speed
+	}
+	m.lastReport = m.now()
+	m.lastDone = m.done
+}
+
+// Finish reports the final byte count
+func (m *transferMeter) Finish() {
+	if m.total <= 0 {
+		m.total = m.done
+	}
+	m.emit()
+}
+
+func (m *transferMeter) emit() {
+	p := Progress{
+		Phase:      PhaseTransferring,
+		BytesDone:  m.done,
+		BytesTotal: m.total,
+		Speed:      m.speed,
+	}
+	if m.total > 0 && m.speed > 0 && m.done < m.total {
+		p.ETA = float64(m.total-m.done) / m.speed
+	}
+	p.Message = formatTransfer(p)
+	m.report(p)
+}
+
+// formatTransfer renders a progress line such as "12.3 MB / 98.0 MB (2.1 MB/s, ETA 41s)"
+func formatTransfer(p Progress) string {
+	msg := formatBytes(p.BytesDone)
+	if p.BytesTotal > 0 {
+		msg += " / " + formatBytes(p.BytesTotal)
+	}
+	if p.Speed > 0 {
+		msg += fmt.Sprintf(" (%s/s", formatBytes(int64(p.Speed)))
+		if p.ETA > 0 {
+			msg += fmt.Sprintf(", ETA %s", (time.Duration(math.Ceil(p.ETA)) * time.Second).String())
+		}
+		msg += ")"
+	}
+	return msg
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// meterWrite is a write of bytes, at after the meter started
+type meterWrite struct {
+	at    time.Duration
+	bytes int
+}
+
+func TestTransferMeter(t *testing.T) {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		total  int64
+		writes []meterWrite
+		want   []string // Reported messages, the last one from Finish
+	}{
+		{
+			name:  "known size",
+			total: 10000,
+			writes: []meterWrite{
+				{100 * time.Millisecond, 1000}, // Too soon to report
+				{time.Second, 1000},
+				{2 * time.Second, 3000}, // Speed smoothed to 0.3*3000 + 0.7*2000
+			},
+			want: []string{
+				"2.0 kB / 10.0 kB (2.0 kB/s, ETA 4s)",
+				"5.0 kB / 10.0 kB (2.3 kB/s, ETA 3s)",
+				"5.0 kB / 10.0 kB (2.3 kB/s, ETA 3s)",
+			},
+		},
+		{
+			name:  "unknown size",
+			total: -1,
+			writes: []meterWrite{
+				{time.Second, 1500},
+			},
+			want: []string{
+				"1.5 kB (1.5 kB/s)",
+				"1.5 kB / 1.5 kB (1.5 kB/s)",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			m := newTransferMeter(tc.total, func(p Progress) {
+				if p.Phase != PhaseTransferring {
+					t.Errorf("phase = %s", p.Phase)
+				}
+				got = append(got, p.Message)
+			})
+			now := start
+			m.now = func() time.Time { return now }
+			m.lastReport = start
+
+			for _, w := range tc.writes {
+				now = start.Add(w.at)
+				m.Write(make([]byte, w.bytes))
+			}
+			m.Finish()
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("reported %q, want %q", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("report %d = %q, want %q", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatTransfer(t *testing.T) {
+	for _, tc := range []struct {
+		p    Progress
+		want string
+	}{
+		{Progress{}, "0 B"},
+		{Progress{BytesDone: 999}, "999 B"},
+		{Progress{BytesDone: 1500, BytesTotal: 2_000_000_000}, "1.5 kB / 2.0 GB"},
+		{Progress{BytesDone: 1_500_000, BytesTotal: 3_000_000, Speed: 500_000, ETA: 3}, "1.5 MB / 3.0 MB (500.0 kB/s, ETA 3s)"},
+		{Progress{BytesDone: 1_000_000, Speed: 1_000_000, ETA: 90.2}, "1.0 MB (1.0 MB/s, ETA 1m31s)"},
+		{Progress{BytesDone: 1_000_000, Speed: 10}, "1.0 MB (10 B/s)"},
+	} {
+		if got := formatTransfer(tc.p); got != tc.want {
+			t.Errorf("formatTransfer(%+v) = %q, want %q", tc.p, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"bcdl-app/backend/models"
+)
+
+// purchasesTTL is how long a fan's purchases are cached, so downloading a
+// whole collection lists it once
+const purchasesTTL = 10 * time.Minute
+
+// purchasesRetryDelay is how long a failed listing is remembered, so a batch
+// of downloads doesn't retry it for every album
+const purchasesRetryDelay = time.Minute
+
+// PurchaseFinder finds the download pages of releases the logged-in fan owns
+type PurchaseFinder interface {
+	// PurchaseDownloadPage returns the download page of the release at
+	// albumURL or with albumID, either of which may be empty, or "" when
+	// the fan doesn't own it
+	PurchaseDownloadPage(ctx context.Context, albumURL string, albumID int64) (string, error)
+}
+
+// purchaseIndex is a fan's collection keyed by album URL and id
+type purchaseIndex struct {
+	fanID int64 // 0 when the session isn't logged in
+	byURL map[string]string
+	byID  map[int64]string
+}
+
+// purchaseLoad is one listing of the fan's purchases. Callers arriving while
+// it runs wait for done rather than listing again
+type purchaseLoad struct {
+	done     chan struct{} // Closed once index or err is set
+	index    *purchaseIndex
+	err      error
+	loadedAt time.Time
+}
+
+// staleLocked reports whether the listing finished long enough ago to be
+// done again
+func (l *purchaseLoad) staleLocked() bool {
+	select {
+	case <-l.done:
+	default:
+		return false
+	}
+	ttl := purchasesTTL
+	if l.err != nil {
+		ttl = purchasesRetryDelay
+	}
+	return time.Since(l.loadedAt) >= ttl
+}
+
+// PurchaseDownloadPage looks the release up in the collection of the fan the
+// scanner's cookies are logged in as
+func (s *FanScanner) PurchaseDownloadPage(ctx context.Context, albumURL string, albumID int64) (string, error) {
+	index, err := s.purchaseIndex(ctx)
+	if err != nil {
+		return "", err
+	}
+	if albumURL != "" {
+		if page, ok := index.byURL[normalizeAlbumURL(albumURL)]; ok {
+			return page, nil
+		}
+	}
+	if albumID != 0 {
+		if page, ok := index.byID[albumID]; ok {
+			return page, nil
+		}
+	}
+	return "", nil
+}
+
+// purchaseIndex returns the cached purchases, listing the collection again
+// once they're older than purchasesTTL. The listing runs without holding the
+// lock, and outlives a caller that gives up waiting on it
+func (s *FanScanner) purchaseIndex(ctx context.Context) (*purchaseIndex, error) {
+	s.mu.Lock()
+	load := s.purchases
+	if load == nil || load.staleLocked() {
+		load = &purchaseLoad{done: make(chan struct{})}
+		s.purchases = load
+		go s.loadPurchases(context.WithoutCancel(ctx), load)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.index, load.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// loadPurchases lists the fan's collection into load
+func (s *FanScanner) loadPurchases(ctx context.Context, load *purchaseLoad) {
+	defer close(load.done)
+	load.index, load.err = s.listPurchases(ctx)
+	load.loadedAt = time.Now()
+	if load.err != nil {
+		log.Printf("FanScanner: Failed to list purchases: %v", load.err)
+	}
+}
+
+func (s *FanScanner) listPurchases(ctx context.Context) (*purchaseIndex, error) {
+	index := &purchaseIndex{
+		byURL: make(map[string]string),
+		byID:  make(map[int64]string),
+	}
+	summary, err := s.collectionSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index.fanID = summary.FanID
+	if index.fanID == 0 {
+		log.Printf("FanScanner: Not logged in, purchases can't be downloaded")
+		return index, nil
+	}
+
+	err = s.scanItems(ctx, "collection_items", index.fanID, true, func(album models.Album) {
+		if album.DownloadURL == "" {
+			return
+		}
+		index.byURL[normalizeAlbumURL(album.URL)] = album.DownloadURL
+		if album.ID != 0 {
+			index.byID[album.ID] = album.DownloadURL
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("FanScanner: %s owns %d downloadable releases", summary.Username, len(index.byURL))
+	return index, nil
+}
+
+// isPurchaseDownloadPage reports whether rawURL is a download page on
+// bandcamp.com, such as the links in a fan's collection
+func isPurchaseDownloadPage(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return (host == "bandcamp.com" || host == "www.bandcamp.com") && strings.TrimSuffix(u.Path, "/") == "/download"
+}
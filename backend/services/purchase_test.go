@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCollection serves a logged-in fan's summary and a one-album collection.
+// Listings wait for release, and fail while failing is set
+type fakeCollection struct {
+	*httptest.Server
+	release   chan struct{}
+	failing   atomic.Bool
+	summaries atomic.Int32
+}
+
+func newFakeCollection(t *testing.T) *fakeCollection {
+	c := &fakeCollection{release: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/fan/2/collection_summary", func(w http.ResponseWriter, r *http.Request) {
+		c.summaries.Add(1)
+		<-c.release
+		if c.failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"collection_summary": {"fan_id": 7, "username": "fan"}}`))
+	})
+	mux.HandleFunc("POST /api/fancollection/1/collection_items", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"items": [{"item_type": "album", "item_url": "https://artist.bandcamp.com/album/one", "tralbum_id": 1, "sale_item_type": "p", "sale_item_id": 5}],
+			"redownload_urls": {"p5": "https://bandcamp.com/download?id=5"}
+		}`))
+	})
+	c.Server = httptest.NewServer(mux)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestPurchaseIndexListsOnce(t *testing.T) {
+	collection := newFakeCollection(t)
+	fans := NewFanScanner()
+	fans.baseURL = collection.URL
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	pages := make([]string, 3)
+	for i := range pages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pages[i], _ = fans.PurchaseDownloadPage(ctx, "https://artist.bandcamp.com/album/one", 0)
+		}()
+	}
+
+	// Waiting callers don't hold the lock the downloader and SetCookies need
+	for collection.summaries.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	locked := make(chan struct{})
+	go func() {
+		fans.mu.Lock()
+		fans.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock is held while the collection is listed")
+	}
+
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := fans.PurchaseDownloadPage(shortCtx, "", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the caller's own deadline", err)
+	}
+
+	close(collection.release)
+	wg.Wait()
+	for i, page := range pages {
+		if page != "https://bandcamp.com/download?id=5" {
+			t.Errorf("caller %d got %q", i, page)
+		}
+	}
+	if n := collection.summaries.Load(); n != 1 {
+		t.Errorf("listed the collection %d times, want once", n)
+	}
+}
+
+func TestPurchaseIndexRemembersFailures(t *testing.T) {
+	collection := newFakeCollection(t)
+	close(collection.release)
+	collection.failing.Store(true)
+	fans := NewFanScanner()
+	fans.baseURL = collection.URL
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := fans.PurchaseDownloadPage(ctx, "", 1); err == nil {
+			t.Fatal("listing succeeded against a failing server")
+		}
+	}
+	if n := collection.summaries.Load(); n != 1 {
+		t.Errorf("listed the collection %d times, want the failure reused", n)
+	}
+
+	// Once the failure is old enough the next download tries again
+	collection.failing.Store(false)
+	fans.mu.Lock()
+	fans.purchases.loadedAt = time.Now().Add(-purchasesRetryDelay)
+	fans.mu.Unlock()
+	if page, err := fans.PurchaseDownloadPage(ctx, "", 1); err != nil || page == "" {
+		t.Errorf("PurchaseDownloadPage after the retry delay = %q, %v", page, err)
+	}
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultQueueParallelism is the number of downloads the queue runs at once
+const DefaultQueueParallelism = 2
+
+// JobStatus is the lifecycle state of a queued download
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobPaused    JobStatus = "paused"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+	JobSkipped   JobStatus = "skipped" // Already in the download history
+)
+
+// Finished reports whether the job has reached a terminal state
+func (st JobStatus) Finished() bool {
+	return st == JobDone || st == JobFailed || st == JobCancelled || st == JobSkipped
+}
+
+// DownloadJob is a single album download tracked by the queue
+type DownloadJob struct {
+	ID            string              `json:"id"`
+	URL           string              `json:"url"`
+	Dir           string              `json:"dir"`
+	Format        string              `json:"format"`
+	PostProcess   *PostProcessOptions `json:"postProcess,omitempty"`   // Overrides the downloader's options
+	IgnoreHistory bool                `json:"ignoreHistory,omitempty"` // Download again even if the history has it
+	Status        JobStatus           `json:"status"`
+	Message       string              `json:"message,omitempty"` // Last progress message
+	Progress      *Progress           `json:"progress,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	ErrorKind     string              `json:"errorKind,omitempty"`   // Failure class, e.g. "paid_only", see ErrorKind
+	Diagnostics   string              `json:"diagnostics,omitempty"` // Folder with the failure's diagnostics bundle
+	CreatedAt     time.Time           `json:"createdAt"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+}
+
+// AlbumDownloader downloads a single album, see DownloaderService
+type AlbumDownloader interface {
+	DownloadAlbumWithOptions(ctx context.Context, url string, downloadDir string, format string, opts DownloadOptions, onProgress ProgressCallback) error
+}
+
+// DownloadFunc adapts a function to the AlbumDownloader interface
+type DownloadFunc func(ctx context.Context, url string, downloadDir string, format string, opts DownloadOptions, onProgress ProgressCallback) error
+
+func (f DownloadFunc) DownloadAlbumWithOptions(ctx context.Context, url string, downloadDir string, format string, opts DownloadOptions, onProgress ProgressCallback) error {
+	return f(ctx, url, downloadDir, format, opts, onProgress)
+}
+
+// DownloadQueue runs album downloads in order with bounded parallelism.
+// Unfinished jobs are persisted to statePath so they survive a restart
+type DownloadQueue struct {
+	downloader  AlbumDownloader
+	statePath   string
+	parallelism int
+	jobTimeout  time.Duration // Per-job deadline, 0 for none
+	wg          sync.WaitGroup
+
+	mu         sync.Mutex
+	ctx        context.Context
+	jobs       []*DownloadJob // Queue order
+	running    map[string]context.CancelFunc
+	onUpdate   func(DownloadJob)
+	onProgress func(job DownloadJob, p Progress)
+}
+
+// NewDownloadQueue creates a queue backed by statePath. An empty statePath
+// keeps the queue in memory only
+func NewDownloadQueue(downloader AlbumDownloader, statePath string) *DownloadQueue {
+	return &DownloadQueue{
+		downloader:  downloader,
+		statePath:   statePath,
+		parallelism: DefaultQueueParallelism,
+		running:     make(map[string]context.CancelFunc),
+	}
+}
+
+// NewDownloadQueueFromEnv creates the queue persisted in the data dir when
+// one is available. BCDL_DOWNLOAD_PARALLELISM sets how many downloads run at
+// once and BCDL_JOB_TIMEOUT (e.g. "30m") how long each may take
+func NewDownloadQueueFromEnv(downloader AlbumDownloader) *DownloadQueue {
+	statePath := ""
+	if dir, err := DataDir(); err == nil {
+		statePath = filepath.Join(dir, "queue.json")
+	} else {
+		log.Printf("Download queue will not be persisted: %v", err)
+	}
+
+	queue := NewDownloadQueue(downloader, statePath)
+	if n, err := strconv.Atoi(os.Getenv("BCDL_DOWNLOAD_PARALLELISM")); err == nil {
+		queue.SetParallelism(n)
+	}
+	if d, err := time.ParseDuration(os.Getenv("BCDL_JOB_TIMEOUT")); err == nil {
+		queue.SetJobTimeout(d)
+	}
+	return queue
+}
+
+// OnUpdate registers a callback invoked whenever a job changes state
+func (q *DownloadQueue) OnUpdate(fn func(DownloadJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onUpdate = fn
+}
+
+// OnProgress registers a callback receiving each job's progress updates
+func (q *DownloadQueue) OnProgress(fn func(job DownloadJob, p Progress)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onProgress = fn
+}
+
+// SetParallelism sets how many jobs may run at the same time
+func (q *DownloadQueue) SetParallelism(n int) {
+	if n < 1 {
+		return
+	}
+	q.mu.Lock()
+	q.parallelism = n
+	started := q.scheduleLocked()
+	q.mu.Unlock()
+	q.notify(started...)
+}
+
+// SetJobTimeout limits how long a single download may run before it fails
+// with a timeout. Zero removes the limit. It applies to jobs started afterwards
+func (q *DownloadQueue) SetJobTimeout(d time.Duration) {
+	if d < 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobTimeout = d
+}
+
+// Load restores unfinished jobs from disk. Jobs that were running when the
+// app stopped are queued again
+func (q *DownloadQueue) Load() error {
+	if q.statePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(q.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read queue state: %v", err)
+	}
+
+	var jobs []*DownloadJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("failed to parse queue state: %v", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range jobs {
+		if job.Status == JobRunning {
+			job.Status = JobQueued
+		}
+	}
+	q.jobs = jobs
+	log.Printf("Queue: Restored %d jobs from %s", len(jobs), q.statePath)
+	return nil
+}
+
+// Start begins processing jobs. Cancelling ctx stops running downloads and
+// leaves them queued for the next start
+func (q *DownloadQueue) Start(ctx context.Context) {
+	q.mu.Lock()
+	q.ctx = ctx
+	started := q.scheduleLocked()
+	q.mu.Unlock()
+	q.notify(started...)
+}
+
+// Wait blocks until the running downloads have returned, e.g. after the
+// context passed to Start was cancelled
+func (q *DownloadQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Enqueue adds a download to the end of the queue
+func (q *DownloadQueue) Enqueue(url string, dir string, format string) (DownloadJob, error) {
+	return q.EnqueueWithOptions(url, dir, format, DownloadOptions{})
+}
+
+// EnqueueWithOptions adds a download with its own options. Post-processing
+// templates left empty fall back to the downloader's defaults
+func (q *DownloadQueue) EnqueueWithOptions(url string, dir string, format string, opts DownloadOptions) (DownloadJob, error) {
+	if url == "" {
+		return DownloadJob{}, fmt.Errorf("url is required")
+	}
+	if opts.PostProcess != nil {
+		if err := opts.PostProcess.Validate(); err != nil {
+			return DownloadJob{}, err
+		}
+	}
+
+	now := time.Now()
+	job := &DownloadJob{
+		ID:            newJobID(),
+		URL:           url,
+		Dir:           dir,
+		Format:        format,
+		PostProcess:   opts.PostProcess,
+		IgnoreHistory: opts.IgnoreHistory,
+		Status:        JobQueued,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	q.mu.Lock()
+	q.jobs = append(q.jobs, job)
+	snapshot := *job
+	started := q.scheduleLocked()
+	q.saveLocked()
+	q.mu.Unlock()
+
+	log.Printf("Queue: Enqueued %s (%s)", url, job.ID)
+	q.notify(append([]DownloadJob{snapshot}, started...)...)
+	return snapshot, nil
+}
+
+// Jobs returns a snapshot of all jobs in queue order
+func (q *DownloadQueue) Jobs() []DownloadJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]DownloadJob, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// Pause holds a queued job, or stops a running one so it restarts from
+// scratch when resumed
+func (q *DownloadQueue) Pause(id string) error {
+	return q.transition(id, func(job *DownloadJob) error {
+		if job.Status != JobQueued && job.Status != JobRunning {
+			return fmt.Errorf("cannot pause %s job", job.Status)
+		}
+		if cancel, ok := q.running[id]; ok {
+			cancel()
+		}
+		job.Status = JobPaused
+		return nil
+	})
+}
+
+// Resume puts a paused job back in line
+func (q *DownloadQueue) Resume(id string) error {
+	return q.transition(id, func(job *DownloadJob) error {
+		if job.Status != JobPaused {
+			return fmt.Errorf("cannot resume %s job", job.Status)
+		}
+		job.Status = JobQueued
+		return nil
+	})
+}
+
+// Cancel stops a job for good
+func (q *DownloadQueue) Cancel(id string) error {
+	return q.transition(id, func(job *DownloadJob) error {
+		if job.Status.Finished() {
+			return fmt.Errorf("cannot cancel %s job", job.Status)
+		}
+		if cancel, ok := q.running[id]; ok {
+			cancel()
+		}
+		job.Status = JobCancelled
+		return nil
+	})
+}
+
+// Reorder moves a job to the given position in the queue
+func (q *DownloadQueue) Reorder(id string, index int) error {
+	return q.transition(id, func(job *DownloadJob) error {
+		from := q.indexLocked(id)
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(q.jobs) {
+			index = len(q.jobs) - 1
+		}
+
+		jobs := append(q.jobs[:from:from], q.jobs[from+1:]...)
+		jobs = append(jobs[:index], append([]*DownloadJob{job}, jobs[index:]...)...)
+		q.jobs = jobs
+		return nil
+	})
+}
+
+// Remove drops a finished job from the queue
+func (q *DownloadQueue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexLocked(id)
+	if i < 0 {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if job := q.jobs[i]; !job.Status.Finished() {
+		return fmt.Errorf("cannot remove %s job, cancel it first", job.Status)
+	}
+	q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+	return nil
+}
+
+// ClearFinished drops every finished job from the queue and returns how many
+// it removed
+func (q *DownloadQueue) ClearFinished() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.jobs[:0]
+	for _, job := range q.jobs {
+		if !job.Status.Finished() {
+			kept = append(kept, job)
+		}
+	}
+	removed := len(q.jobs) - len(kept)
+	clear(q.jobs[len(kept):])
+	q.jobs = kept
+	return removed
+}
+
+// transition applies change to a job under the lock, then persists the queue
+// and starts whatever can run next
+func (q *DownloadQueue) transition(id string, change func(job *DownloadJob) error) error {
+	q.mu.Lock()
+	i := q.indexLocked(id)
+	if i < 0 {
+		q.mu.Unlock()
+		return fmt.Errorf("job %s not found", id)
+	}
+
+	job := q.jobs[i]
+	if err := change(job); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	job.UpdatedAt = time.Now()
+	snapshot := *job
+	started := q.scheduleLocked()
+	q.saveLocked()
+	q.mu.Unlock()
+
+	q.notify(append([]DownloadJob{snapshot}, started...)...)
+	return nil
+}
+
+// scheduleLocked starts queued jobs in order until the parallelism limit is
+// reached, returning the jobs it started
+func (q *DownloadQueue) scheduleLocked() []DownloadJob {
+	if q.ctx == nil || q.ctx.Err() != nil {
+		return nil
+	}
+
+	var started []DownloadJob
+	for _, job := range q.jobs {
+		if len(q.running) >= q.parallelism {
+			break
+		}
+		// A paused job that was resumed quickly may still be winding down
+		if _, busy := q.running[job.ID]; busy || job.Status != JobQueued {
+			continue
+		}
+
+		ctx, cancel := q.jobContextLocked()
+		q.running[job.ID] = cancel
+		job.Status = JobRunning
+		job.Error = ""
+		job.ErrorKind = ""
+		job.Diagnostics = ""
+		job.Progress = nil
+		job.UpdatedAt = time.Now()
+		started = append(started, *job)
+
+		q.wg.Add(1)
+		go q.run(ctx, *job)
+	}
+	return started
+}
+
+// jobContextLocked derives a job's context from the queue's, bounded by the
+// job timeout if one is set
+func (q *DownloadQueue) jobContextLocked() (context.Context, context.CancelFunc) {
+	if q.jobTimeout > 0 {
+		return context.WithTimeout(q.ctx, q.jobTimeout)
+	}
+	return context.WithCancel(q.ctx)
+}
+
+func (q *DownloadQueue) run(ctx context.Context, job DownloadJob) {
+	defer q.wg.Done()
+	log.Printf("Queue: Starting %s (%s)", job.URL, job.ID)
+
+	progress := func(p Progress) {
+		q.mu.Lock()
+		snapshot := job
+		if i := q.indexLocked(job.ID); i >= 0 {
+			q.jobs[i].Message = p.Message
+			q.jobs[i].Progress = &p
+			snapshot = *q.jobs[i]
+		}
+		onProgress := q.onProgress
+		q.mu.Unlock()
+
+		if onProgress != nil {
+			onProgress(snapshot, p)
+		}
+	}
+
+	opts := DownloadOptions{PostProcess: job.PostProcess, IgnoreHistory: job.IgnoreHistory}
+	err := q.downloader.DownloadAlbumWithOptions(ctx, job.URL, job.Dir, job.Format, opts, progress)
+
+	q.mu.Lock()
+	q.running[job.ID]()
+	delete(q.running, job.ID)
+
+	var changed []DownloadJob
+	if i := q.indexLocked(job.ID); i >= 0 && q.jobs[i].Status == JobRunning {
+		current := q.jobs[i]
+		switch {
+		case err == nil:
+			current.Status = JobDone
+		case errors.Is(err, ErrAlreadyDownloaded):
+			current.Status = JobSkipped
+			current.Message = err.Error()
+		case q.ctx.Err() != nil:
+			// Shutting down, pick the job up again on the next start
+			current.Status = JobQueued
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+			current.Status = JobFailed
+			current.Error = fmt.Sprintf("timed out after %s", q.jobTimeout)
+			current.ErrorKind = ErrorKind(err)
+		default:
+			current.Status = JobFailed
+			current.Error = err.Error()
+			current.ErrorKind = ErrorKind(err)
+			current.Diagnostics = DiagnosticsDir(err)
+		}
+		current.UpdatedAt = time.Now()
+		changed = append(changed, *current)
+		log.Printf("Queue: Job %s finished: %s", job.ID, current.Status)
+	}
+	changed = append(changed, q.scheduleLocked()...)
+	q.saveLocked()
+	q.mu.Unlock()
+
+	q.notify(changed...)
+}
+
+func (q *DownloadQueue) notify(jobs ...DownloadJob) {
+	q.mu.Lock()
+	onUpdate := q.onUpdate
+	q.mu.Unlock()
+
+	if onUpdate == nil {
+		return
+	}
+	for _, job := range jobs {
+		onUpdate(job)
+	}
+}
+
+func (q *DownloadQueue) indexLocked(id string) int {
+	for i, job := range q.jobs {
+		if job.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveLocked writes unfinished jobs to disk. Errors are logged rather than
+// returned since the in-memory queue keeps working without persistence
+func (q *DownloadQueue) saveLocked() {
+	if q.statePath == "" {
+		return
+	}
+
+	pending := []*DownloadJob{}
+	for _, job := range q.jobs {
+		if !job.Status.Finished() {
+			pending = append(pending, job)
+		}
+	}
+
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		log.Printf("Queue: Failed to encode state: %v", err)
+		return
+	}
+	if err := writeFileAtomic(q.statePath, data); err != nil {
+		log.Printf("Queue: Failed to save state: %v", err)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
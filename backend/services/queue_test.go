@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stubDownloads is a downloader whose downloads run until finish is called
+// for their URL or their context ends
+type stubDownloads struct {
+	started chan string
+	done    map[string]chan error
+}
+
+func newStubDownloads(urls ...string) *stubDownloads {
+	s := &stubDownloads{started: make(chan string, len(urls)), done: make(map[string]chan error)}
+	for _, url := range urls {
+		s.done[url] = make(chan error, 1)
+	}
+	return s
+}
+
+func (s *stubDownloads) download(ctx context.Context, url string, downloadDir string, format string, opts DownloadOptions, onProgress ProgressCallback) error {
+	s.started <- url
+	select {
+	case err := <-s.done[url]:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *stubDownloads) finish(url string, err error) {
+	s.done[url] <- err
+}
+
+// waitForStart returns the next URL whose download started
+func (s *stubDownloads) waitForStart(t *testing.T) string {
+	t.Helper()
+	select {
+	case url := <-s.started:
+		return url
+	case <-time.After(5 * time.Second):
+		t.Fatal("no download started")
+		return ""
+	}
+}
+
+// waitForStatus polls the queue until job id reaches status
+func waitForStatus(t *testing.T, q *DownloadQueue, id string, status JobStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, job := range q.Jobs() {
+			if job.ID == id && job.Status == status {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s never became %s: %+v", id, status, q.Jobs())
+}
+
+func jobIDs(q *DownloadQueue) []string {
+	var ids []string
+	for _, job := range q.Jobs() {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func TestDownloadQueueTransitions(t *testing.T) {
+	stub := newStubDownloads("a", "b", "c")
+	q := NewDownloadQueue(DownloadFunc(stub.download), "")
+	q.SetParallelism(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		q.Wait()
+	}()
+	q.Start(ctx)
+
+	a, _ := q.Enqueue("a", "", "flac")
+	b, _ := q.Enqueue("b", "", "flac")
+	c, _ := q.Enqueue("c", "", "flac")
+	if url := stub.waitForStart(t); url != "a" {
+		t.Fatalf("started %s first, want a", url)
+	}
+
+	if err := q.Resume(b.ID); err == nil {
+		t.Error("resumed a job that wasn't paused")
+	}
+	if err := q.Pause(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Reorder(c.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := jobIDs(q); got[0] != c.ID || got[1] != a.ID || got[2] != b.ID {
+		t.Errorf("order after moving c to the front = %v, want c, a, b", got)
+	}
+
+	// Cancelling the running job frees its slot for c, skipping the paused b
+	if err := q.Cancel(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, q, a.ID, JobCancelled)
+	if url := stub.waitForStart(t); url != "c" {
+		t.Fatalf("started %s after cancelling a, want c", url)
+	}
+	if err := q.Cancel(a.ID); err == nil {
+		t.Error("cancelled a job twice")
+	}
+
+	if err := q.Resume(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	stub.finish("c", nil)
+	waitForStatus(t, q, c.ID, JobDone)
+	if url := stub.waitForStart(t); url != "b" {
+		t.Fatalf("started %s after c finished, want the resumed b", url)
+	}
+	stub.finish("b", ErrAlreadyDownloaded)
+	waitForStatus(t, q, b.ID, JobSkipped)
+
+	if err := q.Pause(b.ID); err == nil {
+		t.Error("paused a finished job")
+	}
+}
+
+func TestDownloadQueueRemove(t *testing.T) {
+	stub := newStubDownloads("a", "b", "c")
+	q := NewDownloadQueue(DownloadFunc(stub.download), "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		q.Wait()
+	}()
+	q.Start(ctx)
+
+	a, _ := q.Enqueue("a", "", "flac")
+	b, _ := q.Enqueue("b", "", "flac")
+	c, _ := q.Enqueue("c", "", "flac")
+	stub.finish("a", nil)
+	stub.finish("b", nil)
+	waitForStatus(t, q, a.ID, JobDone)
+	waitForStatus(t, q, b.ID, JobDone)
+
+	if err := q.Remove(c.ID); err == nil {
+		t.Error("removed a running job")
+	}
+	if err := q.Remove(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Remove(a.ID); err == nil {
+		t.Error("removed a job twice")
+	}
+	if n := q.ClearFinished(); n != 1 {
+		t.Errorf("ClearFinished removed %d jobs, want 1", n)
+	}
+	if got := jobIDs(q); len(got) != 1 || got[0] != c.ID {
+		t.Errorf("jobs = %v, want only the running one", got)
+	}
+}
+
+func TestDownloadQueuePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	stub := newStubDownloads("a", "b", "c", "d")
+	q := NewDownloadQueue(DownloadFunc(stub.download), path)
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Start(ctx)
+
+	a, _ := q.Enqueue("a", "", "flac")
+	b, _ := q.Enqueue("b", "", "flac")
+	c, _ := q.Enqueue("c", "", "flac")
+	d, _ := q.Enqueue("d", "", "flac")
+	stub.finish("a", nil)
+	waitForStatus(t, q, a.ID, JobDone)
+	waitForStatus(t, q, b.ID, JobRunning)
+	if err := q.Pause(c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Cancel(d.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shutting down leaves the running job queued
+	cancel()
+	q.Wait()
+
+	restored := NewDownloadQueue(DownloadFunc(stub.download), path)
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+	jobs := restored.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("restored %+v, want only the unfinished b and c", jobs)
+	}
+	for i, want := range []struct {
+		id     string
+		status JobStatus
+	}{
+		{b.ID, JobQueued},
+		{c.ID, JobPaused},
+	} {
+		if jobs[i].ID != want.id || jobs[i].Status != want.status {
+			t.Errorf("job %d = %s %s, want %s %s", i, jobs[i].ID, jobs[i].Status, want.id, want.status)
+		}
+	}
+}
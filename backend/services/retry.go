@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// maxRetryAfter bounds a server's Retry-After when the policy has no MaxDelay
+const maxRetryAfter = 5 * time.Minute
+
+// RetryPolicy decides how often and how patiently a failed step is retried
+type RetryPolicy struct {
+	Attempts  int           // Tries in total, including the first
+	BaseDelay time.Duration // Wait before the second try, doubled for each one after
+	MaxDelay  time.Duration // Upper bound of the wait
+	Jitter    float64       // Fraction of each wait that is randomized, from 0 to 1
+	Retryable []error       // Failure classes worth retrying, matched with errors.Is
+}
+
+// DefaultRetryPolicy retries network errors and rate limiting three times in
+// total. Paid-only or region-blocked releases never succeed on a retry
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:  3,
+	BaseDelay: 2 * time.Second,
+	MaxDelay:  30 * time.Second,
+	Jitter:    0.2,
+	Retryable: []error{ErrNetwork, ErrRateLimited},
+}
+
+// RetryPolicyFromEnv is the default policy with the attempts and base delay
+// from BCDL_RETRY_ATTEMPTS and BCDL_RETRY_DELAY (e.g. "500ms")
+func RetryPolicyFromEnv() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if n, err := strconv.Atoi(os.Getenv("BCDL_RETRY_ATTEMPTS")); err == nil && n > 0 {
+		policy.Attempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("BCDL_RETRY_DELAY")); err == nil && d >= 0 {
+		policy.BaseDelay = d
+	}
+	return policy
+}
+
+// ShouldRetry reports whether err belongs to one of the retryable classes
+func (p RetryPolicy) ShouldRetry(err error) bool {
+	for _, target := range p.Retryable {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Do runs fn until it succeeds, fails with an error that isn't retryable, runs
+// out of attempts or ctx is done, waiting between tries. It returns fn's last
+// error, or ctx.Err() if ctx ended the wait
+func (p RetryPolicy) Do(ctx context.Context, what string, fn func() error) error {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= attempts || !p.ShouldRetry(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := p.delay(attempt, err)
+		log.Printf("Retry: %s failed (attempt %d/%d), retrying in %s: %v", what, attempt, attempts, delay.Round(time.Millisecond), err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// delay returns the wait after the given failed attempt: the base delay
+// doubled per attempt, capped, with jitter. A server's Retry-After wins when
+// it asks for longer, up to MaxDelay
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		spread := float64(d) * p.Jitter
+		d += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > d {
+		limit := p.MaxDelay
+		if limit <= 0 {
+			limit = maxRetryAfter
+		}
+		d = min(httpErr.RetryAfter, limit)
+	}
+	return d
+}
+
+// sleepContext waits for d, returning ctx.Err() early if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// gotoPage navigates page to url, retrying per policy. Rate limiting and
+// server errors are returned as *HTTPError; other statuses are left to the
+// caller, as page.Goto does
+func gotoPage(ctx context.Context, policy RetryPolicy, page pw.Page, url string, opts pw.PageGotoOptions) error {
+	return policy.Do(ctx, "navigating to "+url, func() error {
+		resp, err := page.Goto(url, opts)
+		if err != nil {
+			return navigationError(ctx, err)
+		}
+		if resp != nil && (resp.Status() == http.StatusTooManyRequests || resp.Status() >= 500) {
+			return &HTTPError{
+				StatusCode: resp.Status(),
+				URL:        url,
+				RetryAfter: retryAfter(resp.Headers()["retry-after"]),
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRetryDo(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Retryable: []error{ErrNetwork}}
+	for _, tc := range []struct {
+		name     string
+		policy   RetryPolicy
+		failures []error // Returned by the first calls, nil after
+		calls    int
+		want     error
+	}{
+		{"success", policy, nil, 1, nil},
+		{"recovers", policy, []error{ErrNetwork}, 2, nil},
+		{"gives up", policy, []error{ErrNetwork, ErrNetwork, ErrNetwork, ErrNetwork}, 3, ErrNetwork},
+		{"not retryable", policy, []error{ErrPaidOnly}, 1, ErrPaidOnly},
+		{"retryable, then not", policy, []error{ErrNetwork, ErrPaidOnly}, 2, ErrPaidOnly},
+		{"no attempts set", RetryPolicy{Retryable: []error{ErrNetwork}}, []error{ErrNetwork}, 1, ErrNetwork},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := tc.policy.Do(context.Background(), "test", func() error {
+				calls++
+				if calls <= len(tc.failures) {
+					return fmt.Errorf("try %d: %w", calls, tc.failures[calls-1])
+				}
+				return nil
+			})
+			if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tc.want)
+			}
+			if calls != tc.calls {
+				t.Errorf("called %d times, want %d", calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestRetryDoCancelledWhileWaiting(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Hour, Retryable: []error{ErrNetwork}}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := policy.Do(ctx, "test", func() error {
+		calls++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return ErrNetwork
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("called %d times, want 1", calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	uncapped := RetryPolicy{BaseDelay: time.Second}
+	for _, tc := range []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		err     error
+		want    time.Duration
+	}{
+		{"first", policy, 1, ErrNetwork, time.Second},
+		{"doubled", policy, 2, ErrNetwork, 2 * time.Second},
+		{"doubled twice", policy, 3, ErrNetwork, 4 * time.Second},
+		{"capped", policy, 4, ErrNetwork, 5 * time.Second},
+		{"capped long after", policy, 40, ErrNetwork, 5 * time.Second},
+		{"uncapped", uncapped, 4, ErrNetwork, 8 * time.Second},
+		{"retry-after longer", policy, 1, &HTTPError{StatusCode: 429, RetryAfter: 3 * time.Second}, 3 * time.Second},
+		{"retry-after shorter", policy, 3, &HTTPError{StatusCode: 429, RetryAfter: time.Second}, 4 * time.Second},
+		{"retry-after over the cap", policy, 1, &HTTPError{StatusCode: 429, RetryAfter: time.Hour}, 5 * time.Second},
+		{"retry-after without a cap", uncapped, 1, &HTTPError{StatusCode: 429, RetryAfter: time.Hour}, maxRetryAfter},
+		{"wrapped retry-after", policy, 1, fmt.Errorf("page: %w", &HTTPError{StatusCode: 503, RetryAfter: 2 * time.Second}), 2 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.delay(tc.attempt, tc.err); got != tc.want {
+				t.Errorf("delay(%d) = %s, want %s", tc.attempt, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+	varied := false
+	for i := 0; i < 200; i++ {
+		d := policy.delay(1, ErrNetwork)
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay = %s, want within 50%% of 1s", d)
+		}
+		varied = varied || d != time.Second
+	}
+	if !varied {
+		t.Error("jitter never changed the delay")
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		" 3 ":                           3 * time.Second,
+		"":                              0,
+		"0":                             0,
+		"-5":                            0,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0, // Dates aren't supported
+	} {
+		if got := retryAfter(header); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tc := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"none", nil, ""},
+		{"unclassified", io.EOF, ""},
+		{"wrapped", fmt.Errorf("album: %w", ErrPaidOnly), "paid_only"},
+		{"selector", selectorError("title", nil), "selector_changed"},
+		{"already downloaded", fmt.Errorf("%w to /music", ErrAlreadyDownloaded), "already_downloaded"},
+		{"too many requests", &HTTPError{StatusCode: 429}, "rate_limited"},
+		{"server error", &HTTPError{StatusCode: 503}, "network"},
+		{"not found", &HTTPError{StatusCode: 404}, ""},
+		{"connection", networkError(context.Background(), io.ErrUnexpectedEOF), "network"},
+		{"connection while cancelled", networkError(cancelled, io.ErrUnexpectedEOF), ""},
+		{"cancelled", fmt.Errorf("download: %w", context.Canceled), "cancelled"},
+		{"timeout", context.DeadlineExceeded, "timeout"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ErrorKind(tc.err); got != tc.want {
+				t.Errorf("ErrorKind(%v) = %q, want %q", tc.err, got, tc.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
+	"net/url"
+	"os"
+	"strconv"
 
 	"bcdl-app/backend/models"
 	"bcdl-app/backend/playwright"
@@ -12,45 +14,139 @@ import (
 	pw "github.com/playwright-community/playwright-go"
 )
 
+// Scanner discovers the releases listed on a Bandcamp artist page
+type Scanner interface {
+	ScanArtist(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error)
+}
+
+// ScanFunc adapts a function to the Scanner interface
+type ScanFunc func(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error)
+
+func (f ScanFunc) ScanArtist(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+	return f(ctx, url, onAlbumFound)
+}
+
+// NewScannerFromEnv picks the scanner backend from BCDL_SCANNER and
+// BCDL_SCAN_CONCURRENCY
+func NewScannerFromEnv(pwService *playwright.Service) Scanner {
+	concurrency, _ := strconv.Atoi(os.Getenv("BCDL_SCAN_CONCURRENCY"))
+	return NewScanner(os.Getenv("BCDL_SCANNER"), concurrency, pwService)
+}
+
+// NewScanner creates a scanner of the given kind. The HTTP scanner is the
+// default; "browser" scans through Playwright. concurrency sets how many
+// album pages are checked in parallel, zero keeps the default
+func NewScanner(kind string, concurrency int, pwService *playwright.Service) Scanner {
+	if kind == "browser" {
+		log.Printf("Using browser scanner")
+		scanner := NewScannerService(pwService)
+		scanner.SetConcurrency(concurrency)
+		return scanner
+	}
+	scanner := NewHTTPScannerService()
+	scanner.SetConcurrency(concurrency)
+	return scanner
+}
+
+// historyScanner fills in each album's Downloaded flag from the history
+type historyScanner struct {
+	Scanner
+	history *DownloadHistory
+}
+
+// WithHistory wraps scanner so the albums it finds are marked as downloaded
+// when they are in history
+func WithHistory(scanner Scanner, history *DownloadHistory) Scanner {
+	if history == nil {
+		return scanner
+	}
+	return &historyScanner{Scanner: scanner, history: history}
+}
+
+func (s *historyScanner) ScanArtist(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+	albums, err := s.Scanner.ScanArtist(ctx, url, func(album models.Album) {
+		album.Downloaded = s.history.Downloaded(album.URL, album.ID)
+		if onAlbumFound != nil {
+			onAlbumFound(album)
+		}
+	})
+	s.history.MarkDownloaded(albums)
+	return albums, err
+}
+
+// ScanRoster lists the label's roster through the wrapped scanner
+func (s *historyScanner) ScanRoster(ctx context.Context, labelURL string) (string, []models.LabelArtist, error) {
+	rs, ok := s.Scanner.(RosterScanner)
+	if !ok {
+		return "", nil, fmt.Errorf("scanner can't list label rosters")
+	}
+	return rs.ScanRoster(ctx, labelURL)
+}
+
+// ScannerService scans artist pages by driving a Playwright browser
 type ScannerService struct {
-	pwService *playwright.Service
+	pwService   *playwright.Service
+	concurrency int
+	retry       RetryPolicy // For page navigation
+	profiles    *SiteProfileStore
 }
 
 func NewScannerService(pwService *playwright.Service) *ScannerService {
 	return &ScannerService{
-		pwService: pwService,
+		pwService:   pwService,
+		concurrency: DefaultScanConcurrency,
+		retry:       RetryPolicyFromEnv(),
+		profiles:    NewSiteProfileStoreFromEnv(),
+	}
+}
+
+// SetSiteProfiles sets where the selectors the scanner looks for come from
+func (s *ScannerService) SetSiteProfiles(profiles *SiteProfileStore) {
+	s.profiles = profiles
+}
+
+// SetConcurrency sets how many album pages are checked in parallel
+func (s *ScannerService) SetConcurrency(n int) {
+	if n > 0 {
+		s.concurrency = n
 	}
 }
 
 // ScanArtist scans a Bandcamp artist URL for albums
-func (s *ScannerService) ScanArtist(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+func (s *ScannerService) ScanArtist(ctx context.Context, artistURL string, onAlbumFound func(models.Album)) (albums []models.Album, err error) {
 	log.Printf("Scanner: Creating new page...")
-	page, err := s.pwService.NewPage()
+	job, err := s.pwService.NewJobPage(ctx, "scan", playwright.LeaseOptions{BlockResources: true})
 	if err != nil {
 		log.Printf("Scanner: Failed to create page: %v", err)
 		return nil, err
 	}
-	defer page.Close()
+	// The job is closed once the grid is read. A failure before that saves
+	// its diagnostics from the page, which is then closed
+	open := true
+	defer func() {
+		if open {
+			err = saveDiagnostics(ctx, job, err)
+			job.Close()
+		}
+	}()
+	page := job.Page
 
 	// Navigate to artist page
-	log.Printf("Scanner: Navigating to %s", url)
-	if _, err := page.Goto(url, pw.PageGotoOptions{
+	log.Printf("Scanner: Navigating to %s", artistURL)
+	if err := gotoPage(ctx, s.retry, page, artistURL, pw.PageGotoOptions{
 		WaitUntil: pw.WaitUntilStateNetworkidle,
 	}); err != nil {
 		log.Printf("Scanner: Navigation failed: %v", err)
-		return nil, fmt.Errorf("failed to navigate: %v", err)
+		return nil, fmt.Errorf("failed to navigate: %w", err)
 	}
 	log.Printf("Scanner: Navigation successful")
 
 	// Wait for grid
 	log.Printf("Scanner: Waiting for music grid...")
-	grid := page.Locator("ol#music-grid")
-	if err := grid.WaitFor(pw.LocatorWaitForOptions{
-		State:   pw.WaitForSelectorStateVisible,
-		Timeout: pw.Float(10000),
-	}); err != nil {
+	profile := s.profiles.Current()
+	if _, err := profile.Scan.MusicGrid.locate(page); err != nil {
 		log.Printf("Scanner: Music grid not found: %v", err)
-		return nil, fmt.Errorf("music grid not found: %v", err)
+		return nil, selectorError("music grid not found", err)
 	}
 	log.Printf("Scanner: Music grid found")
 
@@ -94,16 +190,14 @@ func (s *ScannerService) ScanArtist(ctx context.Context, url string, onAlbumFoun
 
 	log.Printf("Scanner: Extracted %d albums from grid", len(itemsData))
 
-	var albums []models.Album
-	for i, itemData := range itemsData {
-		// Check for cancellation
-		select {
-		case <-ctx.Done():
-			log.Printf("Scanner: Scan cancelled by user")
-			return albums, ctx.Err()
-		default:
-		}
+	// Resolve links against where the page ended up, which may be a custom domain
+	pageURL, err := url.Parse(page.URL())
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL %q: %v", page.URL(), err)
+	}
 
+	var items []models.Album
+	for _, itemData := range itemsData {
 		data, ok := itemData.(map[string]interface{})
 		if !ok {
 			continue
@@ -116,79 +210,201 @@ func (s *ScannerService) ScanArtist(ctx context.Context, url string, onAlbumFoun
 		// priceText is used as a fallback or initial guess
 		// priceText, _ := data["price"].(string)
 
-		// Handle relative URLs
-		fullURL := href
-		if !strings.HasPrefix(href, "http") {
-			baseURL := strings.Split(url, "/music")[0]
-			fullURL = baseURL + href
-		}
-
-		log.Printf("Scanner: Checking status for album %d/%d: %s", i+1, len(itemsData), title)
-
-		// Visit album page to check true status (NYP/Free/Paid)
-		// We reuse the same page for performance
-		status := "paid"
-		isFree := false
-		isNYP := false
-
-		if _, err := page.Goto(fullURL, pw.PageGotoOptions{
-			WaitUntil: pw.WaitUntilStateDomcontentloaded, // Faster than networkidle
-		}); err == nil {
-			// Check for "name your price" or "Free Download"
-			// Using Evaluate for speed
-			checkResult, err := page.Evaluate(`() => {
-				const buyHeader = document.querySelector('h4.ft.compound-button');
-				if (!buyHeader) return 'unavailable';
-				
-				const text = buyHeader.innerText.toLowerCase();
-				if (text.includes('name your price')) return 'nyp';
-				if (text.includes('free download')) return 'free';
-				
-				const buyBtn = buyHeader.querySelector('button.download-link');
-				if (buyBtn) {
-					const btnText = buyBtn.innerText.toLowerCase();
-					if (btnText.includes('name your price')) return 'nyp';
-					if (btnText.includes('free')) return 'free';
-				}
-				
-				return 'paid';
-			}`)
-
-			if err == nil {
-				statusStr, _ := checkResult.(string)
-				if statusStr == "nyp" {
-					isNYP = true
-					status = "nyp"
-				} else if statusStr == "free" {
-					isFree = true
-					status = "free"
-				} else if statusStr == "paid" {
-					status = "paid"
-				}
-			}
-		} else {
-			log.Printf("Scanner: Failed to visit album page: %v", err)
+		fullURL := resolveURL(pageURL, href)
+		if fullURL == "" {
+			continue
 		}
 
-		album := models.Album{
+		items = append(items, models.Album{
 			Title:    title,
 			Artist:   artist,
 			CoverURL: coverURL,
 			URL:      fullURL,
-			IsFree:   isFree,
-			IsNYP:    isNYP,
-			Price:    "", // Price text is less relevant now that we have status
-			Status:   status,
-		}
+			Price:    "", // Filled in from the album page along with the status
+			Status:   "paid",
+		})
+	}
 
-		albums = append(albums, album)
+	// The grid is read, its context is better used by a worker
+	job.Close()
+	open = false
 
-		// Emit event for dynamic UI updates
-		if onAlbumFound != nil {
-			onAlbumFound(album)
-		}
+	// Visit album pages to check true status (NYP/Free/Paid), one page per worker
+	log.Printf("Scanner: Checking album status with %d workers", s.concurrency)
+	albums, err = probeAlbums(ctx, items, s.concurrency, s.pageProberFactory(profile), onAlbumFound)
+	if err != nil {
+		return albums, err
 	}
 
 	log.Printf("Scanner: Finished processing all items, returning %d albums", len(albums))
 	return albums, nil
 }
+
+// ScanRoster lists the artists on a label's /artists page
+func (s *ScannerService) ScanRoster(ctx context.Context, labelURL string) (string, []models.LabelArtist, error) {
+	lease, err := s.pwService.Lease(ctx, playwright.LeaseOptions{BlockResources: true})
+	if err != nil {
+		return "", nil, err
+	}
+	defer lease.Return()
+	page := lease.Page
+	stop := context.AfterFunc(ctx, func() { page.Close() })
+	defer stop()
+
+	if err := gotoPage(ctx, s.retry, page, labelURL, pw.PageGotoOptions{
+		WaitUntil: pw.WaitUntilStateDomcontentloaded,
+	}); err != nil {
+		return "", nil, fmt.Errorf("failed to fetch roster: %w", err)
+	}
+
+	result, err := page.Evaluate(`() => {
+		const siteName = document.querySelector('meta[property="og:site_name"]');
+		const items = document.querySelectorAll('li.artists-grid-item');
+		return {
+			name: siteName ? siteName.getAttribute('content') : '',
+			artists: Array.from(items).map(item => {
+				const linkEl = item.querySelector('a');
+				const nameEl = item.querySelector('.artists-grid-name');
+				return {
+					name: nameEl ? nameEl.innerText.trim() : '',
+					url: linkEl ? linkEl.getAttribute('href') : ''
+				};
+			})
+		};
+	}`)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read roster: %v", err)
+	}
+
+	pageURL, err := url.Parse(page.URL())
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid page URL %q: %v", page.URL(), err)
+	}
+
+	data, _ := result.(map[string]interface{})
+	name, _ := data["name"].(string)
+	items, _ := data["artists"].([]interface{})
+	var artists []models.LabelArtist
+	for _, item := range items {
+		entry, _ := item.(map[string]interface{})
+		artistName, _ := entry["name"].(string)
+		href, _ := entry["url"].(string)
+		if artistName == "" {
+			continue
+		}
+		if artist, ok := rosterArtist(pageURL, artistName, href); ok {
+			artists = append(artists, artist)
+		}
+	}
+	return name, artists, nil
+}
+
+// pageProberFactory leases a dedicated page for each worker. The first worker
+// waits for a free browser context, the others are only started while there
+// are free contexts. The page is closed as soon as ctx is cancelled so
+// in-flight navigations abort immediately
+func (s *ScannerService) pageProberFactory(profile *SiteProfile) newProberFunc {
+	started := 0 // Workers are started one after the other
+	return func(ctx context.Context) (albumProber, func(), error) {
+		job, err := s.pwService.NewJobPage(ctx, "scan", playwright.LeaseOptions{
+			BlockResources: true,
+			NoWait:         started > 0,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		started++
+		page := job.Page
+		stop := context.AfterFunc(ctx, func() { page.Close() })
+
+		probe := func(ctx context.Context, album *models.Album) error {
+			err := probeAlbumPage(ctx, s.retry, profile, page, album)
+			return saveDiagnostics(ctx, job, err)
+		}
+		cleanup := func() {
+			stop()
+			job.Close()
+		}
+		return probe, cleanup, nil
+	}
+}
+
+// probeAlbumPage visits the album page, reads the buy button to classify it
+// and fills in the metadata from the page's data-tralbum and ld+json blocks
+func probeAlbumPage(ctx context.Context, retry RetryPolicy, profile *SiteProfile, page pw.Page, album *models.Album) error {
+	if err := gotoPage(ctx, retry, page, album.URL, pw.PageGotoOptions{
+		WaitUntil: pw.WaitUntilStateDomcontentloaded, // Faster than networkidle
+	}); err != nil {
+		return fmt.Errorf("failed to visit album page: %w", err)
+	}
+
+	// Check for "name your price" or "Free Download"
+	// Using Evaluate for speed
+	checkResult, err := page.Evaluate(`(profile) => {
+		const first = (root, selectors) => selectors.map(sel => root.querySelector(sel)).find(el => el);
+		const mentions = (text, needles) => needles.some(needle => text.includes(needle.toLowerCase()));
+		const tralbumEl = document.querySelector('script[data-tralbum]');
+		const ldEl = document.querySelector('script[type="application/ld+json"]');
+		const result = {
+			status: 'paid',
+			tralbum: tralbumEl ? tralbumEl.getAttribute('data-tralbum') : '',
+			ldjson: ldEl ? ldEl.textContent : ''
+		};
+
+		const buyHeader = first(document, profile.buyHeader);
+		if (!buyHeader) {
+			result.status = 'unavailable';
+			return result;
+		}
+		
+		const text = buyHeader.innerText.toLowerCase();
+		if (mentions(text, profile.nypText)) {
+			result.status = 'nyp';
+		} else if (mentions(text, profile.freeText)) {
+			result.status = 'free';
+		} else {
+			const buyBtn = first(buyHeader, profile.buyButton);
+			if (buyBtn) {
+				const btnText = buyBtn.innerText.toLowerCase();
+				if (mentions(btnText, profile.nypText)) result.status = 'nyp';
+				else if (mentions(btnText, profile.freeText)) result.status = 'free';
+			}
+		}
+		return result;
+	}`, map[string]interface{}{
+		"buyHeader": profile.Scan.BuyHeader.Selectors,
+		"buyButton": profile.Scan.BuyButton.Selectors,
+		"nypText":   profile.Scan.NYPText,
+		"freeText":  profile.Scan.FreeText,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check album status: %v", err)
+	}
+
+	data, _ := checkResult.(map[string]interface{})
+	statusStr, _ := data["status"].(string)
+	if statusStr == "nyp" {
+		album.IsNYP = true
+		album.Status = "nyp"
+	} else if statusStr == "free" {
+		album.IsFree = true
+		album.Status = "free"
+	} else if statusStr == "paid" {
+		album.Status = "paid"
+	}
+
+	var tralbum *tralbumData
+	if raw, _ := data["tralbum"].(string); raw != "" {
+		if tralbum, err = parseTralbum(raw); err != nil {
+			log.Printf("Scanner: %s: %v", album.URL, err)
+		}
+	}
+	var ld *ldJSONData
+	if raw, _ := data["ldjson"].(string); raw != "" {
+		if ld, err = parseLDJSON(raw); err != nil {
+			log.Printf("Scanner: %s: %v", album.URL, err)
+		}
+	}
+	fillAlbumMetadata(album, tralbum, ld)
+	return nil
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/playwright"
+
+	"golang.org/x/net/html"
+)
+
+// HTTPScannerService scans artist pages with plain HTTP requests, reading the
+// server-rendered grid and the embedded JSON instead of driving a browser
+type HTTPScannerService struct {
+	client      *http.Client
+	concurrency int
+	retry       RetryPolicy // For page fetches
+}
+
+func NewHTTPScannerService() *HTTPScannerService {
+	return &HTTPScannerService{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		concurrency: DefaultScanConcurrency,
+		retry:       RetryPolicyFromEnv(),
+	}
+}
+
+// SetConcurrency sets how many album pages are fetched in parallel
+func (s *HTTPScannerService) SetConcurrency(n int) {
+	if n > 0 {
+		s.concurrency = n
+	}
+}
+
+// clientItem is an entry of the music grid's data-client-items JSON, which
+// holds the releases Bandcamp renders client-side
+type clientItem struct {
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	BandName string `json:"band_name"`
+	PageURL  string `json:"page_url"`
+	ArtID    int64  `json:"art_id"`
+}
+
+// ScanArtist scans a Bandcamp artist URL for albums
+func (s *HTTPScannerService) ScanArtist(ctx context.Context, artistURL string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+	log.Printf("HTTPScanner: Fetching %s", artistURL)
+	doc, pageURL, err := s.fetchDocument(ctx, artistURL)
+	if err != nil {
+		log.Printf("HTTPScanner: Fetch failed: %v", err)
+		return nil, fmt.Errorf("failed to fetch artist page: %w", err)
+	}
+
+	// Label pages may split their releases over several grids
+	grids := findAll(doc, func(n *html.Node) bool {
+		return n.Data == "ol" && (attr(n, "id") == "music-grid" || hasClass(n, "music-grid"))
+	})
+	if len(grids) == 0 {
+		log.Printf("HTTPScanner: Music grid not found")
+		return nil, selectorError("music grid not found", nil)
+	}
+
+	items := gridAlbums(grids, pageURL, metaContent(doc, "og:site_name"))
+	log.Printf("HTTPScanner: Extracted %d albums from grid", len(items))
+
+	albums, err := probeAlbums(ctx, items, s.concurrency, s.newProber, onAlbumFound)
+	if err != nil {
+		return albums, err
+	}
+
+	log.Printf("HTTPScanner: Finished processing all items, returning %d albums", len(albums))
+	return albums, nil
+}
+
+// newProber shares the HTTP client between workers, nothing to clean up
+func (s *HTTPScannerService) newProber(ctx context.Context) (albumProber, func(), error) {
+	return s.probeAlbum, func() {}, nil
+}
+
+// probeAlbum reads the album page's data-tralbum and ld+json blocks to fill in
+// its status and metadata. Albums that cannot be checked are left as "paid"
+func (s *HTTPScannerService) probeAlbum(ctx context.Context, album *models.Album) error {
+	album.Status = "paid"
+
+	doc, _, err := s.fetchDocument(ctx, album.URL)
+	if err != nil {
+		return err
+	}
+
+	script := findFirst(doc, func(n *html.Node) bool {
+		return n.Data == "script" && hasAttr(n, "data-tralbum")
+	})
+	if script == nil {
+		return selectorError("tralbum data not found on "+album.URL, nil)
+	}
+
+	tralbum, err := parseTralbum(attr(script, "data-tralbum"))
+	if err != nil {
+		return err
+	}
+
+	var ld *ldJSONData
+	if ldScript := findFirst(doc, func(n *html.Node) bool {
+		return n.Data == "script" && attr(n, "type") == "application/ld+json"
+	}); ldScript != nil && ldScript.FirstChild != nil {
+		if ld, err = parseLDJSON(ldScript.FirstChild.Data); err != nil {
+			log.Printf("HTTPScanner: %s: %v", album.URL, err)
+		}
+	}
+
+	album.Status = tralbum.Status()
+	album.IsFree = album.Status == "free"
+	album.IsNYP = album.Status == "nyp"
+	fillAlbumMetadata(album, tralbum, ld)
+	return nil
+}
+
+// fetchDocument downloads and parses an HTML page, returning the final URL
+// after redirects so relative links can be resolved against it. Network
+// errors and rate limiting are retried
+func (s *HTTPScannerService) fetchDocument(ctx context.Context, pageURL string) (*html.Node, *url.URL, error) {
+	var doc *html.Node
+	var finalURL *url.URL
+	err := s.retry.Do(ctx, "fetching "+pageURL, func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", playwright.UserAgent)
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return networkError(ctx, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return newHTTPError(resp, pageURL)
+		}
+
+		if doc, err = html.Parse(resp.Body); err != nil {
+			return networkError(ctx, fmt.Errorf("failed to parse HTML: %w", err))
+		}
+		finalURL = resp.Request.URL
+		return nil
+	})
+	return doc, finalURL, err
+}
+
+// ScanRoster lists the artists on a label's /artists page
+func (s *HTTPScannerService) ScanRoster(ctx context.Context, labelURL string) (string, []models.LabelArtist, error) {
+	doc, pageURL, err := s.fetchDocument(ctx, labelURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch roster: %w", err)
+	}
+
+	var artists []models.LabelArtist
+	for _, item := range findAll(doc, byClass("artists-grid-item")) {
+		link := findFirst(item, byTag("a"))
+		nameEl := findFirst(item, byClass("artists-grid-name"))
+		if link == nil || nameEl == nil {
+			continue
+		}
+		if artist, ok := rosterArtist(pageURL, textContent(nameEl, nil), attr(link, "href")); ok {
+			artists = append(artists, artist)
+		}
+	}
+	return metaContent(doc, "og:site_name"), artists, nil
+}
+
+// gridAlbums collects the rendered li.music-grid-item entries followed by any
+// entries only present in data-client-items, in grid order
+func gridAlbums(grids []*html.Node, pageURL *url.URL, bandName string) []models.Album {
+	var albums []models.Album
+	seen := make(map[string]bool)
+
+	add := func(album models.Album) {
+		if album.URL == "" || seen[album.URL] {
+			return
+		}
+		seen[album.URL] = true
+		if album.Artist == "" {
+			album.Artist = bandName
+		}
+		albums = append(albums, album)
+	}
+
+	for _, grid := range grids {
+		gridItems(grid, pageURL, add)
+	}
+	return albums
+}
+
+// gridItems passes each release in one music grid to add
+func gridItems(grid *html.Node, pageURL *url.URL, add func(models.Album)) {
+	for _, item := range findAll(grid, byClass("music-grid-item")) {
+		var album models.Album
+
+		if link := findFirst(item, byTag("a")); link != nil {
+			album.URL = resolveURL(pageURL, attr(link, "href"))
+		}
+		if titleEl := findFirst(item, byClass("title")); titleEl != nil {
+			album.Title = textContent(titleEl, byClass("artist-override"))
+		}
+		if artistEl := findFirst(item, func(n *html.Node) bool {
+			return hasClass(n, "artist-override") || hasClass(n, "artist")
+		}); artistEl != nil {
+			album.Artist = strings.TrimPrefix(textContent(artistEl, nil), "by ")
+		}
+		// Handle lazy loading for cover image
+		if img := findFirst(item, byTag("img")); img != nil {
+			album.CoverURL = attr(img, "data-original")
+			if album.CoverURL == "" {
+				album.CoverURL = attr(img, "src")
+			}
+		}
+		add(album)
+	}
+
+	if raw := attr(grid, "data-client-items"); raw != "" {
+		var items []clientItem
+		if err := json.Unmarshal([]byte(raw), &items); err != nil {
+			log.Printf("HTTPScanner: Failed to parse data-client-items: %v", err)
+		}
+		for _, item := range items {
+			album := models.Album{
+				Title:  item.Title,
+				Artist: item.Artist,
+				URL:    resolveURL(pageURL, item.PageURL),
+			}
+			if album.Artist == "" {
+				album.Artist = item.BandName
+			}
+			if item.ArtID != 0 {
+				album.CoverURL = fmt.Sprintf("https://f4.bcbits.com/img/a%010d_2.jpg", item.ArtID)
+			}
+			add(album)
+		}
+	}
+}
+
+func resolveURL(base *url.URL, href string) string {
+	if href == "" {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
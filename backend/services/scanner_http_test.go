@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bcdl-app/backend/models"
+)
+
+// wantFixtureAlbums is what scanning the fixture artist finds, in grid order
+var wantFixtureAlbums = []struct {
+	path   string
+	title  string
+	status string
+	id     int64
+}{
+	{"/album/free-album", "Free Album", "free", 1001},
+	{"/album/nyp-album", "Name Your Price Album", "nyp", 1002},
+	{"/album/email-album", "Email Album", "nyp", 1003}, // Name your price, behind an email
+	{"/album/paid-album", "Paid Album", "paid", 1004},
+}
+
+func TestHTTPScannerScanArtist(t *testing.T) {
+	site := newFixtureSite(t, nil)
+	scanner := NewHTTPScannerService()
+	scanner.retry = noRetry
+
+	found := 0
+	albums, err := scanner.ScanArtist(context.Background(), site.URL()+"/music", func(models.Album) { found++ })
+	if err != nil {
+		t.Fatalf("ScanArtist: %v", err)
+	}
+	if len(albums) != len(wantFixtureAlbums) || found != len(wantFixtureAlbums) {
+		t.Fatalf("got %d albums (%d reported), want %d", len(albums), found, len(wantFixtureAlbums))
+	}
+
+	for i, want := range wantFixtureAlbums {
+		album := albums[i]
+		if album.URL != site.URL()+want.path {
+			t.Errorf("album %d: URL = %q, want %q", i, album.URL, site.URL()+want.path)
+		}
+		if album.Title != want.title || album.Status != want.status || album.ID != want.id {
+			t.Errorf("album %d: got %q %s #%d, want %q %s #%d", i, album.Title, album.Status, album.ID, want.title, want.status, want.id)
+		}
+		if album.Artist != "Fixture Artist" || album.Label != "Fixture Records" || album.ReleaseDate != "2023-03-01" {
+			t.Errorf("album %d: metadata = %q / %q / %q", i, album.Artist, album.Label, album.ReleaseDate)
+		}
+		if len(album.Tracks) != 2 {
+			t.Errorf("album %d: %d tracks, want 2", i, len(album.Tracks))
+		}
+	}
+	if albums[3].Price != "7.00 EUR" {
+		t.Errorf("paid album price = %q, want %q", albums[3].Price, "7.00 EUR")
+	}
+}
+
+func TestHTTPScannerMissingGrid(t *testing.T) {
+	site := newFixtureSite(t, nil)
+	scanner := NewHTTPScannerService()
+	scanner.retry = noRetry
+
+	_, err := scanner.ScanArtist(context.Background(), site.URL()+"/download", nil)
+	if !errors.Is(err, ErrSelectorChanged) {
+		t.Fatalf("err = %v, want ErrSelectorChanged", err)
+	}
+}
+
+func TestTralbumStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"free download page", `{"freeDownloadPage": "https://x.bandcamp.com/download?id=1", "current": {"minimum_price": 0}}`, "free"},
+		{"free download flag", `{"is_purchasable": true, "current": {"minimum_price": 0, "free_download": 1}}`, "free"},
+		{"name your price", `{"is_purchasable": true, "current": {"minimum_price": 0}}`, "nyp"},
+		{"name your price behind an email", `{"is_purchasable": true, "current": {"minimum_price": 0, "require_email": 1}}`, "nyp"},
+		{"paid", `{"is_purchasable": true, "current": {"minimum_price": 7}}`, "paid"},
+		{"not for sale", `{"is_purchasable": false, "current": {"minimum_price": 0}}`, "paid"},
+		{"no sale flag", `{"current": {"minimum_price": 0}}`, "nyp"},
+	} {
+		tralbum, err := parseTralbum(tc.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := tralbum.Status(); got != tc.want {
+			t.Errorf("%s: Status() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"bcdl-app/backend/models"
+)
+
+// DefaultScanConcurrency is the number of album pages checked in parallel
+const DefaultScanConcurrency = 4
+
+// albumProber fills in the status of a single album
+type albumProber func(ctx context.Context, album *models.Album) error
+
+// newProberFunc creates the prober used by one worker, along with a cleanup
+// function that releases whatever the worker holds (e.g. a browser page)
+type newProberFunc func(ctx context.Context) (albumProber, func(), error)
+
+// probeAlbums checks the status of every album using up to concurrency
+// workers. Albums are passed to onAlbumFound as soon as they resolve, while
+// the returned slice keeps grid order. When ctx is cancelled all workers stop
+// and the albums resolved so far are returned together with ctx.Err()
+func probeAlbums(ctx context.Context, albums []models.Album, concurrency int, newProber newProberFunc, onAlbumFound func(models.Album)) ([]models.Album, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(albums) {
+		concurrency = len(albums)
+	}
+
+	results := make([]models.Album, len(albums))
+	resolved := make([]bool, len(albums))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan int)
+	for w := 0; w < concurrency; w++ {
+		probe, cleanup, err := newProber(ctx)
+		if err != nil {
+			if w == 0 {
+				return nil, err
+			}
+			log.Printf("Scanner: Running with %d workers, failed to start more: %v", w, err)
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cleanup()
+
+			for i := range jobs {
+				album := albums[i]
+				if err := probe(ctx, &album); err != nil {
+					if ctx.Err() != nil {
+						continue
+					}
+					log.Printf("Scanner: Failed to check album %s: %v", album.URL, err)
+					album.ProbeError = err.Error()
+				}
+
+				mu.Lock()
+				results[i] = album
+				resolved[i] = true
+				// Emit event for dynamic UI updates
+				if onAlbumFound != nil {
+					onAlbumFound(album)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range albums {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var ordered []models.Album
+	for i, album := range results {
+		if resolved[i] {
+			ordered = append(ordered, album)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		log.Printf("Scanner: Scan cancelled by user")
+		return ordered, err
+	}
+	return ordered, nil
+}
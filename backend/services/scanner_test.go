@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bcdl-app/backend/playwright"
+)
+
+func TestBrowserScannerScanArtist(t *testing.T) {
+	browser := newTestBrowser(t)
+	site := newFixtureSite(t, nil)
+	scanner := NewScannerService(browser)
+	scanner.retry = noRetry
+
+	albums, err := scanner.ScanArtist(context.Background(), site.URL()+"/music", nil)
+	if err != nil {
+		t.Fatalf("ScanArtist: %v", err)
+	}
+	if len(albums) != len(wantFixtureAlbums) {
+		t.Fatalf("got %d albums, want %d", len(albums), len(wantFixtureAlbums))
+	}
+
+	for i, want := range wantFixtureAlbums {
+		album := albums[i]
+		if album.URL != site.URL()+want.path || album.Title != want.title || album.Status != want.status || album.ID != want.id {
+			t.Errorf("album %d: got %s %q %s #%d, want %s %q %s #%d", i,
+				album.URL, album.Title, album.Status, album.ID, site.URL()+want.path, want.title, want.status, want.id)
+		}
+	}
+}
+
+func TestBrowserScannerSavesDiagnostics(t *testing.T) {
+	browser := newTestBrowser(t)
+	site := newFixtureSite(t, nil)
+	diagnostics := t.TempDir()
+	browser.SetDiagnostics(playwright.DiagnosticsOptions{Dir: diagnostics})
+	t.Cleanup(func() { browser.SetDiagnostics(playwright.DiagnosticsOptions{}) })
+
+	// An artist page whose music grid moved
+	profilePath := filepath.Join(t.TempDir(), "site-profile.json")
+	if err := os.WriteFile(profilePath, []byte(`{"scan": {"musicGrid": {"selectors": ["#moved"], "timeout": "1s"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScannerService(browser)
+	scanner.retry = noRetry
+	scanner.SetSiteProfiles(NewSiteProfileStore(profilePath))
+
+	_, err := scanner.ScanArtist(context.Background(), site.URL()+"/music", nil)
+	if !errors.Is(err, ErrSelectorChanged) {
+		t.Fatalf("err = %v, want ErrSelectorChanged", err)
+	}
+	if dir := DiagnosticsDir(err); filepath.Dir(dir) != diagnostics {
+		t.Errorf("diagnostics saved to %q, want a folder in %s", dir, diagnostics)
+	}
+}
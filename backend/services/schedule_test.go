@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // The zones below, whatever the machine has installed
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		ok   bool
+	}{
+		{"0 */6 * * *", true},
+		{"15,45 9-17 * * 1-5", true},
+		{"0 0 1 */3 *", true},
+		{"0 0 * * 7", true},
+		{"@hourly", true},
+		{"@daily", true},
+		{"@weekly", true},
+		{"@every 90m", true},
+		{"@every 30s", false},
+		{"@every soon", false},
+		{"0 * * *", false},
+		{"60 * * * *", false},
+		{"0 24 * * *", false},
+		{"0 0 0 * *", false},
+		{"0 0 * 13 *", false},
+		{"0 0 * * 8", false},
+		{"5-1 * * * *", false},
+		{"*/0 * * * *", false},
+		{"a * * * *", false},
+	} {
+		_, err := ParseSchedule(tc.spec)
+		if (err == nil) != tc.ok {
+			t.Errorf("ParseSchedule(%q) err = %v, want ok %v", tc.spec, err, tc.ok)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	zone := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loc
+	}
+	utc := time.UTC
+	kolkata := zone("Asia/Kolkata")        // UTC+5:30
+	adelaide := zone("Australia/Adelaide") // UTC+9:30 / +10:30
+	newYork := zone("America/New_York")
+
+	for _, tc := range []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every six hours", DefaultWatchSchedule,
+			time.Date(2026, 5, 1, 7, 13, 0, 0, utc), time.Date(2026, 5, 1, 12, 0, 0, 0, utc)},
+		{"strictly after", DefaultWatchSchedule,
+			time.Date(2026, 5, 1, 12, 0, 0, 0, utc), time.Date(2026, 5, 1, 18, 0, 0, 0, utc)},
+		{"half-hour zone", DefaultWatchSchedule,
+			time.Date(2026, 5, 1, 7, 13, 0, 0, kolkata), time.Date(2026, 5, 1, 12, 0, 0, 0, kolkata)},
+		{"half-hour zone with DST", DefaultWatchSchedule,
+			time.Date(2026, 1, 10, 19, 59, 0, 0, adelaide), time.Date(2026, 1, 11, 0, 0, 0, 0, adelaide)},
+		{"weekdays", "30 9 * * 1-5",
+			time.Date(2026, 5, 1, 10, 0, 0, 0, kolkata), time.Date(2026, 5, 4, 9, 30, 0, 0, kolkata)}, // Friday to Monday
+		{"either day field", "0 0 13 * 5",
+			time.Date(2026, 2, 1, 0, 0, 0, 0, utc), time.Date(2026, 2, 6, 0, 0, 0, 0, utc)},
+		{"every", "@every 90m",
+			time.Date(2026, 5, 1, 7, 13, 0, 0, utc), time.Date(2026, 5, 1, 8, 43, 0, 0, utc)},
+		// 02:30 doesn't exist on the day clocks spring forward
+		{"spring forward", "30 2 * * *",
+			time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 9, 2, 30, 0, 0, newYork)},
+		{"hours after spring forward", DefaultWatchSchedule,
+			time.Date(2026, 3, 8, 1, 0, 0, 0, newYork), time.Date(2026, 3, 8, 6, 0, 0, 0, newYork)},
+		{"fall back", DefaultWatchSchedule,
+			time.Date(2026, 11, 1, 0, 30, 0, 0, newYork), time.Date(2026, 11, 1, 6, 0, 0, 0, newYork)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(tc.from); !got.Equal(tc.want) {
+				t.Errorf("Next(%s) = %s, want %s", tc.from, got, tc.want)
+			}
+		})
+	}
+}
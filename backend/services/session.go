@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bcdl-app/backend/playwright"
+
+	pw "github.com/playwright-community/playwright-go"
+)
+
+// LoginTimeout bounds how long an interactive login waits for the user
+const LoginTimeout = 10 * time.Minute
+
+// SessionStatus says whether the Bandcamp login in use is still valid
+type SessionStatus struct {
+	LoggedIn  bool      `json:"loggedIn"`
+	Username  string    `json:"username,omitempty"`
+	FanID     int64     `json:"fanId,omitempty"`
+	Source    string    `json:"source,omitempty"` // File the session was loaded from
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Session is the Bandcamp login shared by the browser pages and the fan
+// scanner. It's loaded from a Playwright storage-state JSON or a cookies.txt
+// and saved as storage state in the data dir
+type Session struct {
+	browser *playwright.Service
+	fans    *FanScanner
+	path    string // Where imported and logged-in sessions are saved
+
+	mu     sync.Mutex
+	state  *pw.OptionalStorageState
+	source string
+}
+
+// NewSession creates a session applied to browser and fans, either of which
+// may be nil, and saved to path. An empty path keeps it in memory only
+func NewSession(browser *playwright.Service, fans *FanScanner, path string) *Session {
+	return &Session{browser: browser, fans: fans, path: path}
+}
+
+// NewSessionFromEnv creates a session saved in the data dir. Load reads
+// BCDL_STORAGE_STATE or BCDL_COOKIES_FILE instead when set
+func NewSessionFromEnv(browser *playwright.Service, fans *FanScanner) *Session {
+	path := ""
+	if dir, err := DataDir(); err == nil {
+		path = filepath.Join(dir, "session.json")
+	} else {
+		log.Printf("Session will not be persisted: %v", err)
+	}
+	return NewSession(browser, fans, path)
+}
+
+// Load applies the session file named by BCDL_STORAGE_STATE or
+// BCDL_COOKIES_FILE, or else the saved one. A missing saved session is not
+// an error
+func (s *Session) Load() error {
+	for _, key := range []string{"BCDL_STORAGE_STATE", "BCDL_COOKIES_FILE"} {
+		if path := os.Getenv(key); path != "" {
+			return s.loadFile(path)
+		}
+	}
+
+	if s.path == "" {
+		return nil
+	}
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil
+	}
+	return s.loadFile(s.path)
+}
+
+func (s *Session) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read session: %v", err)
+	}
+	state, err := playwright.ParseSession(data)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %v", path, err)
+	}
+	s.apply(state, path)
+	log.Printf("Session: Loaded %d cookies from %s", len(state.Cookies), path)
+	return nil
+}
+
+// Import applies a cookies.txt or storage-state file and saves it as the
+// session used from now on
+func (s *Session) Import(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read session: %v", err)
+	}
+	return s.ImportData(data)
+}
+
+// ImportData is Import for a file's contents
+func (s *Session) ImportData(data []byte) error {
+	state, err := playwright.ParseSession(data)
+	if err != nil {
+		return err
+	}
+	if err := s.save(state); err != nil {
+		return err
+	}
+	s.apply(state, s.path)
+	log.Printf("Session: Imported %d cookies", len(state.Cookies))
+	return nil
+}
+
+// Login opens a browser window for the user to log in to Bandcamp, then
+// saves the session. The browser must be initialized
+func (s *Session) Login(ctx context.Context) error {
+	if s.browser == nil {
+		return fmt.Errorf("no browser to log in with")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, LoginTimeout)
+	defer cancel()
+	state, err := s.browser.Login(ctx)
+	if err != nil {
+		return fmt.Errorf("login failed: %v", err)
+	}
+	if err := s.save(state); err != nil {
+		return err
+	}
+	s.apply(state, s.path)
+	return nil
+}
+
+// Check asks Bandcamp who the session belongs to. A session Bandcamp
+// rejects is reported as logged out rather than as an error
+func (s *Session) Check(ctx context.Context) (SessionStatus, error) {
+	s.mu.Lock()
+	state, source := s.state, s.source
+	s.mu.Unlock()
+
+	status := SessionStatus{Source: source, CheckedAt: time.Now()}
+	if playwright.SessionCookieValue(state) == "" {
+		return status, nil
+	}
+
+	// A client of its own so only this session's cookies are sent
+	client := NewFanScanner()
+	client.SetCookies(playwright.HTTPCookies(state))
+	summary, err := client.collectionSummary(ctx)
+	if err != nil {
+		return status, err
+	}
+	status.LoggedIn = summary.FanID != 0
+	status.FanID = summary.FanID
+	status.Username = summary.Username
+	return status, nil
+}
+
+// apply hands the session to the browser and the fan scanner
+func (s *Session) apply(state *pw.OptionalStorageState, source string) {
+	s.mu.Lock()
+	s.state = state
+	s.source = source
+	s.mu.Unlock()
+
+	if s.browser != nil {
+		s.browser.SetSession(state)
+	}
+	if s.fans != nil {
+		s.fans.SetCookies(playwright.HTTPCookies(state))
+	}
+}
+
+// save writes the session as storage state, readable by the owner only since
+// it logs in as them
+func (s *Session) save(state *pw.OptionalStorageState) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomicPerm(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save session: %v", err)
+	}
+	return nil
+}
+
+// collectionSummary is the subset of the logged-in fan's summary we rely on
+type collectionSummary struct {
+	FanID    int64  `json:"fan_id"`
+	Username string `json:"username"`
+}
+
+// collectionSummary returns the fan the client's cookies are logged in as,
+// with a zero FanID when they aren't
+func (s *FanScanner) collectionSummary(ctx context.Context) (collectionSummary, error) {
+	apiURL := s.baseURL + "/api/fan/2/collection_summary"
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return collectionSummary{}, err
+	}
+	req.Header.Set("User-Agent", playwright.UserAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return collectionSummary{}, fmt.Errorf("failed to check session: %w", networkError(ctx, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return collectionSummary{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return collectionSummary{}, newHTTPError(resp, apiURL)
+	}
+
+	var body struct {
+		FanID   int64             `json:"fan_id"`
+		Summary collectionSummary `json:"collection_summary"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return collectionSummary{}, fmt.Errorf("failed to parse collection summary: %v", err)
+	}
+	if body.Summary.FanID == 0 {
+		body.Summary.FanID = body.FanID
+	}
+	return body.Summary, nil
+}
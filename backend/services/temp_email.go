@@ -1,235 +1,160 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
+// TempEmailService hands out addresses from an ordered list of mailbox
+// providers and watches them for the Bandcamp download email
 type TempEmailService struct {
-	client    *http.Client
-	Token     string
-	AccountID string
-	Email     string
-}
-
-// Mail.tm API structures
-type DomainResponse struct {
-	HydraMember []struct {
-		Domain string `json:"domain"`
-	} `json:"hydra:member"`
-}
-
-type AccountRequest struct {
-	Address  string `json:"address"`
-	Password string `json:"password"`
-}
-
-type AccountResponse struct {
-	ID      string `json:"id"`
-	Address string `json:"address"`
-}
+	providers    []MailboxProvider
+	retry        RetryPolicy    // For inbox checks that fail
+	downloadLink *regexp.Regexp // Matches the link in the download email
 
-type TokenResponse struct {
-	Token string `json:"token"`
+	mu     sync.Mutex
+	owners map[string]MailboxProvider // Address -> provider that created it
 }
 
-type EmailMessage struct {
-	ID   string `json:"id"` // Mail.tm uses string IDs
-	From struct {
-		Address string `json:"address"`
-		Name    string `json:"name"`
-	} `json:"from"`
-	Subject string `json:"subject"`
-	Intro   string `json:"intro"`
-}
-
-type EmailBody struct {
-	ID   string `json:"id"`
-	From struct {
-		Address string `json:"address"`
-		Name    string `json:"name"`
-	} `json:"from"`
-	Subject string   `json:"subject"`
-	Html    []string `json:"html"`
-	Text    string   `json:"text"`
-}
-
-func NewTempEmailService() *TempEmailService {
+// NewTempEmailService creates the service with providers in fallback order.
+// Without providers the list is read from the environment
+func NewTempEmailService(providers ...MailboxProvider) *TempEmailService {
+	if len(providers) == 0 {
+		providers = MailboxProvidersFromEnv()
+	}
 	return &TempEmailService{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		providers:    providers,
+		retry:        RetryPolicyFromEnv(),
+		downloadLink: bandcampDownloadLink,
+		owners:       make(map[string]MailboxProvider),
 	}
 }
 
-// GenerateTempEmail creates a new temporary email address using Mail.tm
-func (s *TempEmailService) GenerateTempEmail() (string, error) {
-	// 1. Get Domains
-	domain, err := s.getDomain()
-	if err != nil {
-		return "", fmt.Errorf("failed to get domain: %v", err)
-	}
-
-	// 2. Generate Credentials
-	username := fmt.Sprintf("user%d", time.Now().UnixNano())
-	password := fmt.Sprintf("Pwd%d!", time.Now().UnixNano())
-	address := fmt.Sprintf("%s@%s", username, domain)
+// bandcampDownloadLink matches links like https://bandcamp.com/download?...
+// or https://[artist].bandcamp.com/download?...
+var bandcampDownloadLink = regexp.MustCompile(`https?://[^"'\s<>]*bandcamp\.com/download[^"'\s<>]*`)
 
-	// 3. Create Account
-	if err := s.createAccount(address, password); err != nil {
-		return "", fmt.Errorf("failed to create account: %v", err)
-	}
-
-	// 4. Get Token
-	token, err := s.getToken(address, password)
-	if err != nil {
-		return "", fmt.Errorf("failed to get token: %v", err)
-	}
-
-	s.Email = address
-	s.Token = token
-	log.Printf("TempEmail: Generated email: %s", s.Email)
-
-	return s.Email, nil
+// SetDownloadBaseURL makes ExtractDownloadLink look for download links on
+// baseURL, such as a local fixture server, instead of bandcamp.com
+func (s *TempEmailService) SetDownloadBaseURL(baseURL string) {
+	s.downloadLink = regexp.MustCompile(regexp.QuoteMeta(strings.TrimSuffix(baseURL, "/")) + `/download[^"'\s<>]*`)
 }
 
-func (s *TempEmailService) getDomain() (string, error) {
-	resp, err := s.client.Get("https://api.mail.tm/domains")
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	var result DomainResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
-
-	if len(result.HydraMember) == 0 {
-		return "", fmt.Errorf("no domains available")
-	}
-
-	return result.HydraMember[0].Domain, nil
+// SetRetryPolicy sets how failed inbox checks are retried. Call it before
+// waiting for emails
+func (s *TempEmailService) SetRetryPolicy(policy RetryPolicy) {
+	s.retry = policy
 }
 
-func (s *TempEmailService) createAccount(address, password string) error {
-	reqBody, _ := json.Marshal(AccountRequest{
-		Address:  address,
-		Password: password,
-	})
+// Providers returns the configured providers in fallback order
+func (s *TempEmailService) Providers() []MailboxProvider {
+	return s.providers
+}
 
-	resp, err := s.client.Post("https://api.mail.tm/accounts", "application/json", bytes.NewBuffer(reqBody))
+// NewAddress generates an address with provider and remembers it so the
+// inbox can be checked later, until ReleaseAddress or WaitForDownloadEmail
+// is done with it
+func (s *TempEmailService) NewAddress(ctx context.Context, provider MailboxProvider) (string, error) {
+	address, err := provider.GenerateAddress(ctx)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("%s: %v", provider.Name(), err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result AccountResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-	s.AccountID = result.ID
-	return nil
+	s.mu.Lock()
+	s.owners[address] = provider
+	s.mu.Unlock()
+	return address, nil
 }
 
-func (s *TempEmailService) getToken(address, password string) (string, error) {
-	reqBody, _ := json.Marshal(AccountRequest{
-		Address:  address,
-		Password: password,
-	})
-
-	resp, err := s.client.Post("https://api.mail.tm/token", "application/json", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+// GenerateTempEmail creates an address with the first provider that works
+func (s *TempEmailService) GenerateTempEmail(ctx context.Context) (string, error) {
+	var errs []string
+	for _, provider := range s.providers {
+		address, err := s.NewAddress(ctx, provider)
+		if err == nil {
+			return address, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		log.Printf("TempEmail: Provider failed, trying next: %v", err)
+		errs = append(errs, err.Error())
 	}
-
-	var result TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	if len(errs) == 0 {
+		return "", fmt.Errorf("no mail providers configured")
 	}
-	return result.Token, nil
+	return "", fmt.Errorf("all mail providers failed: %s", strings.Join(errs, "; "))
 }
 
-// CheckInbox polls the inbox for new messages
-func (s *TempEmailService) CheckInbox(email string) ([]EmailMessage, error) {
-	req, err := http.NewRequest("GET", "https://api.mail.tm/messages", nil)
+// CheckInbox polls the inbox for new messages. Provider failures are
+// network errors
+func (s *TempEmailService) CheckInbox(ctx context.Context, email string) ([]EmailMessage, error) {
+	provider, err := s.owner(email)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+s.Token)
+	messages, err := provider.CheckInbox(ctx, email)
+	return messages, networkError(ctx, err)
+}
 
-	resp, err := s.client.Do(req)
+// ReadMessage retrieves the full content of a message. Provider failures are
+// network errors
+func (s *TempEmailService) ReadMessage(ctx context.Context, email string, messageID string) (*EmailBody, error) {
+	provider, err := s.owner(email)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check inbox: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d", resp.StatusCode)
+		return nil, err
 	}
+	body, err := provider.ReadMessage(ctx, email, messageID)
+	return body, networkError(ctx, err)
+}
 
-	var result struct {
-		HydraMember []EmailMessage `json:"hydra:member"`
+// ReleaseAddress forgets email and tells its provider the job is done with it,
+// so providers handing out a fixed address can give it to the next job
+func (s *TempEmailService) ReleaseAddress(email string) {
+	s.mu.Lock()
+	provider, ok := s.owners[email]
+	delete(s.owners, email)
+	s.mu.Unlock()
+	if !ok {
+		return
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse messages: %v", err)
+	if releaser, ok := provider.(AddressReleaser); ok {
+		releaser.ReleaseAddress(email)
 	}
-
-	return result.HydraMember, nil
 }
 
-// ReadMessage retrieves the full content of a message
-func (s *TempEmailService) ReadMessage(email string, messageID string) (*EmailBody, error) {
-	url := fmt.Sprintf("https://api.mail.tm/messages/%s", messageID)
-	req, err := http.NewRequest("GET", url, nil)
+// disposeMessage lets providers that support it move or delete a processed message
+func (s *TempEmailService) disposeMessage(ctx context.Context, email string, messageID string) {
+	provider, err := s.owner(email)
 	if err != nil {
-		return nil, err
+		return
 	}
-	req.Header.Set("Authorization", "Bearer "+s.Token)
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read message: %v", err)
+	if disposer, ok := provider.(MessageDisposer); ok {
+		if err := disposer.DisposeMessage(ctx, email, messageID); err != nil {
+			log.Printf("TempEmail: Failed to clean up message: %v", err)
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d", resp.StatusCode)
-	}
+func (s *TempEmailService) owner(email string) (MailboxProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var emailBody EmailBody
-	if err := json.NewDecoder(resp.Body).Decode(&emailBody); err != nil {
-		return nil, fmt.Errorf("failed to parse message: %v", err)
+	provider, ok := s.owners[email]
+	if !ok {
+		return nil, fmt.Errorf("unknown address: %s", email)
 	}
-
-	return &emailBody, nil
+	return provider, nil
 }
 
 // ExtractDownloadLink extracts the Bandcamp download link from email body
 func (s *TempEmailService) ExtractDownloadLink(emailBody string) (string, error) {
-	// Bandcamp sends links like: https://bandcamp.com/download?...
-	// or https://[artist].bandcamp.com/download?...
-	// Regex updated to handle both cases (optional subdomain)
-	re := regexp.MustCompile(`https?://[^"'\s<>]*bandcamp\.com/download[^"'\s<>]*`)
-	matches := re.FindStringSubmatch(emailBody)
+	matches := s.downloadLink.FindStringSubmatch(emailBody)
 
 	if len(matches) == 0 {
 		// Log truncated body for debugging
@@ -249,17 +174,32 @@ func (s *TempEmailService) ExtractDownloadLink(emailBody string) (string, error)
 	return link, nil
 }
 
-// WaitForDownloadEmail polls the inbox until a Bandcamp email arrives or timeout
-func (s *TempEmailService) WaitForDownloadEmail(email string, maxAttempts int, intervalSeconds int) (string, error) {
+// WaitForDownloadEmail polls the inbox every interval until a Bandcamp email
+// arrives, maxAttempts checks have come up empty or ctx is done. The address
+// is released when it returns
+func (s *TempEmailService) WaitForDownloadEmail(ctx context.Context, email string, maxAttempts int, interval time.Duration) (string, error) {
+	defer s.ReleaseAddress(email)
 	log.Printf("TempEmail: Waiting for download email at %s...", email)
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			if err := sleepContext(ctx, interval); err != nil {
+				return "", err
+			}
+		}
 		log.Printf("TempEmail: Polling inbox... (attempt %d/%d)", attempt, maxAttempts)
 
-		messages, err := s.CheckInbox(email)
+		var messages []EmailMessage
+		err := s.retry.Do(ctx, "checking inbox", func() error {
+			var err error
+			messages, err = s.CheckInbox(ctx, email)
+			return err
+		})
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		if err != nil {
 			log.Printf("TempEmail: Failed to check inbox: %v", err)
-			time.Sleep(time.Duration(intervalSeconds) * time.Second)
 			continue
 		}
 
@@ -277,7 +217,15 @@ func (s *TempEmailService) WaitForDownloadEmail(email string, maxAttempts int, i
 				log.Printf("TempEmail: Match found! From: %s, Subject: %s", msg.From.Address, msg.Subject)
 
 				// Read full message
-				emailBody, err := s.ReadMessage(email, msg.ID)
+				var emailBody *EmailBody
+				err := s.retry.Do(ctx, "reading message", func() error {
+					var err error
+					emailBody, err = s.ReadMessage(ctx, email, msg.ID)
+					return err
+				})
+				if ctx.Err() != nil {
+					return "", ctx.Err()
+				}
 				if err != nil {
 					log.Printf("TempEmail: Failed to read message: %v", err)
 					continue
@@ -297,14 +245,11 @@ func (s *TempEmailService) WaitForDownloadEmail(email string, maxAttempts int, i
 					continue
 				}
 
+				s.disposeMessage(ctx, email, msg.ID)
 				return link, nil
 			}
 		}
-
-		if attempt < maxAttempts {
-			time.Sleep(time.Duration(intervalSeconds) * time.Second)
-		}
 	}
 
-	return "", fmt.Errorf("timeout waiting for download email after %d attempts", maxAttempts)
+	return "", fmt.Errorf("%w after %d attempts", ErrEmailTimeout, maxAttempts)
 }
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bcdl-app/backend/fixtures"
+)
+
+func TestWaitForDownloadEmail(t *testing.T) {
+	mail := newFixtureMail(t)
+	provider := NewMailTMProvider("mailtm", mail.URL())
+	svc := NewTempEmailService(provider)
+	svc.SetRetryPolicy(noRetry)
+	svc.SetDownloadBaseURL("http://fixture.test")
+
+	ctx := context.Background()
+	address, err := svc.GenerateTempEmail(ctx)
+	if err != nil {
+		t.Fatalf("GenerateTempEmail: %v", err)
+	}
+	if !strings.HasSuffix(address, "@"+fixtureDomain) {
+		t.Fatalf("address = %q, want one at %s", address, fixtureDomain)
+	}
+
+	mail.Deliver(address, fixtures.Message{FromAddress: "someone@example.com", Subject: "Hello"})
+	mail.Deliver(address, fixtures.Message{
+		FromAddress: "noreply@bandcamp.com",
+		Subject:     "Your download",
+		HTML:        `<a href="http://fixture.test/download?id=1&amp;sig=abc">Download</a>`,
+	})
+
+	link, err := svc.WaitForDownloadEmail(ctx, address, 1, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForDownloadEmail: %v", err)
+	}
+	if want := "http://fixture.test/download?id=1&amp;sig=abc"; link != want {
+		t.Errorf("link = %q, want %q", link, want)
+	}
+	if _, err := svc.owner(address); err == nil {
+		t.Error("address still known after its email was read")
+	}
+	if open := mail.Open(); len(open) != 0 {
+		t.Errorf("accounts %v left open after the email was read", open)
+	}
+	if _, err := provider.CheckInbox(ctx, address); err == nil {
+		t.Error("provider still holds a token for the released address")
+	}
+}
+
+func TestWaitForDownloadEmailTimeout(t *testing.T) {
+	mail := newFixtureMail(t)
+	svc := NewTempEmailService(NewMailTMProvider("mailtm", mail.URL()))
+	svc.SetRetryPolicy(noRetry)
+
+	address, err := svc.GenerateTempEmail(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateTempEmail: %v", err)
+	}
+	_, err = svc.WaitForDownloadEmail(context.Background(), address, 2, time.Millisecond)
+	if !errors.Is(err, ErrEmailTimeout) {
+		t.Fatalf("err = %v, want ErrEmailTimeout", err)
+	}
+	if _, err := svc.owner(address); err == nil {
+		t.Error("address still known after giving up on it")
+	}
+}
+
+func TestWaitForDownloadEmailCanceled(t *testing.T) {
+	mail := newFixtureMail(t)
+	svc := NewTempEmailService(NewMailTMProvider("mailtm", mail.URL()))
+	svc.SetRetryPolicy(noRetry)
+
+	address, err := svc.GenerateTempEmail(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateTempEmail: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := svc.WaitForDownloadEmail(ctx, address, 2, time.Millisecond); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if _, err := svc.owner(address); err == nil {
+		t.Error("address still known after cancel")
+	}
+}
+
+func TestExtractDownloadLink(t *testing.T) {
+	svc := NewTempEmailService(NewMailTMProvider("mailtm", "http://unused"))
+	for _, body := range []string{
+		`Get it at https://bandcamp.com/download?from=email&id=1 today`,
+		`<a href="https://artist.bandcamp.com/download?id=1">here</a>`,
+	} {
+		if _, err := svc.ExtractDownloadLink(body); err != nil {
+			t.Errorf("ExtractDownloadLink(%q): %v", body, err)
+		}
+	}
+	if _, err := svc.ExtractDownloadLink("https://example.com/download?id=1"); err == nil {
+		t.Error("ExtractDownloadLink accepted a link off bandcamp.com")
+	}
+}
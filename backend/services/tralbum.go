@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// tralbumData is the subset of the album page's data-tralbum JSON we rely on
+type tralbumData struct {
+	ID               int64  `json:"id"`
+	ItemType         string `json:"item_type"` // "album" or "track"
+	Artist           string `json:"artist"`
+	FreeDownloadPage string `json:"freeDownloadPage"`
+	IsPurchasable    *bool  `json:"is_purchasable"` // false when it isn't for sale at all
+	AlbumReleaseDate string `json:"album_release_date"`
+	Current          struct {
+		Title        string      `json:"title"`
+		About        string      `json:"about"`
+		Credits      string      `json:"credits"`
+		ReleaseDate  string      `json:"release_date"`
+		MinimumPrice float64     `json:"minimum_price"`
+		FreeDownload interface{} `json:"free_download"`
+	} `json:"current"`
+	Trackinfo []struct {
+		ID       int64   `json:"id"`
+		Title    string  `json:"title"`
+		TrackNum int     `json:"track_num"`
+		Duration float64 `json:"duration"`
+	} `json:"trackinfo"`
+}
+
+// tralbumDateLayout is how dates appear in data-tralbum, e.g. "24 Feb 2017 00:00:00 GMT"
+const tralbumDateLayout = "02 Jan 2006 15:04:05 MST"
+
+func parseTralbum(raw string) (*tralbumData, error) {
+	var data tralbumData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse tralbum data: %v", err)
+	}
+	return &data, nil
+}
+
+// Status classifies the release the same way the album page's buy button does:
+// "free", "nyp" or "paid". Releases that aren't for sale can't be downloaded
+// and count as "paid", like a page without a buy button
+func (t *tralbumData) Status() string {
+	if t.FreeDownloadPage != "" || isTruthy(t.Current.FreeDownload) {
+		return "free"
+	}
+	if t.IsPurchasable != nil && !*t.IsPurchasable {
+		return "paid"
+	}
+	if t.Current.MinimumPrice == 0 {
+		return "nyp"
+	}
+	return "paid"
+}
+
+// ReleaseDate returns the date the release came out, or the zero time if unknown
+func (t *tralbumData) ReleaseDate() time.Time {
+	for _, raw := range []string{t.AlbumReleaseDate, t.Current.ReleaseDate} {
+		if date, err := time.Parse(tralbumDateLayout, raw); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// ReleaseYear returns the year the release came out, or 0 if unknown
+func (t *tralbumData) ReleaseYear() int {
+	if date := t.ReleaseDate(); !date.IsZero() {
+		return date.Year()
+	}
+	return 0
+}
+
+// Tracks returns the track listing
+func (t *tralbumData) Tracks() []TrackInfo {
+	tracks := make([]TrackInfo, 0, len(t.Trackinfo))
+	for _, track := range t.Trackinfo {
+		tracks = append(tracks, TrackInfo{Number: track.TrackNum, Title: track.Title})
+	}
+	return tracks
+}
+
+// isTruthy interprets the loosely typed flags Bandcamp uses (1, true, "1", null)
+func isTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		return val != "" && val != "0" && val != "false"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bcdl-app/backend/models"
+)
+
+// DefaultWatchSchedule re-scans watched artists every six hours
+const DefaultWatchSchedule = "0 */6 * * *"
+
+// WatchedArtist is an artist or label page that is re-scanned on a schedule
+type WatchedArtist struct {
+	ID           string              `json:"id"`
+	URL          string              `json:"url"`
+	Dir          string              `json:"dir"`
+	Format       string              `json:"format"`
+	PostProcess  *PostProcessOptions `json:"postProcess,omitempty"`
+	Schedule     string              `json:"schedule"`     // Cron expression, see ParseSchedule
+	AutoDownload bool                `json:"autoDownload"` // Queue new free and NYP releases
+	AddedAt      time.Time           `json:"addedAt"`
+	LastScan     time.Time           `json:"lastScan"`
+	NextScan     time.Time           `json:"nextScan"`
+	LastError    string              `json:"lastError,omitempty"`
+	Known        int                 `json:"known"` // Releases seen by the last scan
+}
+
+// WatchReport describes one re-scan of a watched artist
+type WatchReport struct {
+	ArtistID    string         `json:"artistId"`
+	ArtistURL   string         `json:"artistUrl"`
+	ScannedAt   time.Time      `json:"scannedAt"`
+	Total       int            `json:"total"`
+	Baseline    bool           `json:"baseline"` // First scan, which only records what exists
+	NewReleases []models.Album `json:"newReleases"`
+	Queued      []string       `json:"queued"` // IDs of the download jobs created
+	Error       string         `json:"error,omitempty"`
+}
+
+// watchEntry is a watched artist as persisted, with the album URLs of its
+// last successful scan
+type watchEntry struct {
+	WatchedArtist
+	Snapshot   []string     `json:"snapshot"`
+	LastReport *WatchReport `json:"lastReport,omitempty"`
+}
+
+// WatchList keeps the watched artists, re-scans them when their schedule is
+// due, and queues the free and name-your-price releases that appeared since
+// the previous scan. State is persisted to path
+type WatchList struct {
+	scanner Scanner
+	queue   *DownloadQueue
+	path    string
+
+	mu       sync.Mutex
+	entries  []*watchEntry
+	checking map[string]bool
+	onReport func(WatchReport)
+}
+
+// NewWatchList creates a watch list backed by path. An empty path keeps the
+// list in memory only, and a nil queue disables automatic downloads
+func NewWatchList(scanner Scanner, queue *DownloadQueue, path string) *WatchList {
+	return &WatchList{
+		scanner:  scanner,
+		queue:    queue,
+		path:     path,
+		checking: make(map[string]bool),
+	}
+}
+
+// NewWatchListFromEnv loads the watch list kept in the data dir
+func NewWatchListFromEnv(scanner Scanner, queue *DownloadQueue) *WatchList {
+	path := ""
+	if dir, err := DataDir(); err == nil {
+		path = filepath.Join(dir, "watchlist.json")
+	} else {
+		log.Printf("Watch list will not be persisted: %v", err)
+	}
+
+	watchList := NewWatchList(scanner, queue, path)
+	if err := watchList.Load(); err != nil {
+		log.Printf("Failed to load watch list: %v", err)
+	}
+	return watchList
+}
+
+// OnReport registers a callback receiving the report of every re-scan
+func (w *WatchList) OnReport(fn func(WatchReport)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onReport = fn
+}
+
+// Load reads the watch list from disk. A missing file is not an error
+func (w *WatchList) Load() error {
+	if w.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []*watchEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", w.path, err)
+	}
+
+	w.mu.Lock()
+	w.entries = entries
+	w.mu.Unlock()
+	return nil
+}
+
+// Add starts watching an artist. An empty schedule uses DefaultWatchSchedule.
+// The first scan only records the existing releases
+func (w *WatchList) Add(artist WatchedArtist) (WatchedArtist, error) {
+	if artist.URL == "" {
+		return WatchedArtist{}, fmt.Errorf("url is required")
+	}
+	if artist.Schedule == "" {
+		artist.Schedule = DefaultWatchSchedule
+	}
+	if _, err := ParseSchedule(artist.Schedule); err != nil {
+		return WatchedArtist{}, err
+	}
+	if artist.PostProcess != nil {
+		if err := artist.PostProcess.Validate(); err != nil {
+			return WatchedArtist{}, err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, e := range w.entries {
+		if normalizeAlbumURL(e.URL) == normalizeAlbumURL(artist.URL) {
+			return WatchedArtist{}, fmt.Errorf("%s is already watched", artist.URL)
+		}
+	}
+
+	artist.ID = newJobID()
+	artist.AddedAt = time.Now()
+	artist.LastScan = time.Time{}
+	artist.LastError = ""
+	artist.Known = 0
+	entry := &watchEntry{WatchedArtist: artist}
+	w.entries = append(w.entries, entry)
+	if err := w.saveLocked(); err != nil {
+		return WatchedArtist{}, err
+	}
+
+	log.Printf("Watch: Added %s (%s)", artist.URL, artist.ID)
+	return entry.view(), nil
+}
+
+// Remove stops watching an artist
+func (w *WatchList) Remove(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.indexLocked(id)
+	if i < 0 {
+		return fmt.Errorf("watched artist %s not found", id)
+	}
+	w.entries = append(w.entries[:i], w.entries[i+1:]...)
+	return w.saveLocked()
+}
+
+// Artists returns the watched artists in the order they were added
+func (w *WatchList) Artists() []WatchedArtist {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	artists := make([]WatchedArtist, 0, len(w.entries))
+	for _, e := range w.entries {
+		artists = append(artists, e.view())
+	}
+	return artists
+}
+
+// Reports returns the latest report of each watched artist that has been
+// scanned
+func (w *WatchList) Reports() []WatchReport {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	reports := []WatchReport{}
+	for _, e := range w.entries {
+		if e.LastReport != nil {
+			reports = append(reports, *e.LastReport)
+		}
+	}
+	return reports
+}
+
+// Run re-scans artists as their schedules come due until ctx is cancelled.
+// Schedules are checked once a minute
+func (w *WatchList) Run(ctx context.Context) {
+	for {
+		w.CheckDue(ctx)
+
+		now := time.Now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// CheckDue re-scans, one after another, every artist whose schedule is due
+func (w *WatchList) CheckDue(ctx context.Context) []WatchReport {
+	now := time.Now()
+	var due []string
+	w.mu.Lock()
+	for _, e := range w.entries {
+		if next := e.nextScan(); !next.IsZero() && !next.After(now) {
+			due = append(due, e.ID)
+		}
+	}
+	w.mu.Unlock()
+
+	var reports []WatchReport
+	for _, id := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		report, err := w.Check(ctx, id)
+		if err != nil && report.ArtistID == "" {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// Check re-scans one watched artist now, compares the result with the last
+// snapshot and queues new free and NYP releases. A failed scan still returns
+// a report carrying the error, and leaves the snapshot untouched
+func (w *WatchList) Check(ctx context.Context, id string) (WatchReport, error) {
+	w.mu.Lock()
+	i := w.indexLocked(id)
+	if i < 0 {
+		w.mu.Unlock()
+		return WatchReport{}, fmt.Errorf("watched artist %s not found", id)
+	}
+	if w.checking[id] {
+		w.mu.Unlock()
+		return WatchReport{}, fmt.Errorf("watched artist %s is already being scanned", id)
+	}
+	w.checking[id] = true
+	artist := w.entries[i].WatchedArtist
+	known := make(map[string]bool, len(w.entries[i].Snapshot))
+	for _, u := range w.entries[i].Snapshot {
+		known[u] = true
+	}
+	baseline := w.entries[i].Snapshot == nil
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		delete(w.checking, id)
+		w.mu.Unlock()
+	}()
+
+	log.Printf("Watch: Scanning %s", artist.URL)
+	albums, err := w.scanner.ScanArtist(ctx, artist.URL, nil)
+	if ctx.Err() != nil {
+		// Interrupted scans are retried on the next run rather than reported
+		return WatchReport{}, ctx.Err()
+	}
+
+	report := WatchReport{
+		ArtistID:    artist.ID,
+		ArtistURL:   artist.URL,
+		ScannedAt:   time.Now(),
+		Baseline:    baseline,
+		NewReleases: []models.Album{},
+		Queued:      []string{},
+	}
+
+	var snapshot []string
+	if err != nil {
+		report.Error = err.Error()
+		log.Printf("Watch: Scan of %s failed: %v", artist.URL, err)
+	} else {
+		report.Total = len(albums)
+		snapshot = make([]string, 0, len(albums))
+		for _, album := range albums {
+			key := normalizeAlbumURL(album.URL)
+			if album.ProbeError != "" && !baseline && !known[key] {
+				// Its status is a guess, the next check looks at it again
+				log.Printf("Watch: Leaving %s for the next scan, its page could not be checked", album.URL)
+				continue
+			}
+			snapshot = append(snapshot, key)
+			if baseline || known[key] {
+				continue
+			}
+			report.NewReleases = append(report.NewReleases, album)
+			if jobID := w.autoQueue(artist, album); jobID != "" {
+				report.Queued = append(report.Queued, jobID)
+			}
+		}
+		log.Printf("Watch: %s has %d releases, %d new, %d queued", artist.URL, report.Total, len(report.NewReleases), len(report.Queued))
+	}
+
+	w.mu.Lock()
+	if i := w.indexLocked(id); i >= 0 {
+		e := w.entries[i]
+		e.LastScan = report.ScannedAt
+		e.LastError = report.Error
+		if err == nil {
+			e.Snapshot = snapshot
+			e.Known = len(snapshot)
+		}
+		e.LastReport = &report
+		if saveErr := w.saveLocked(); saveErr != nil {
+			log.Printf("Watch: Failed to save watch list: %v", saveErr)
+		}
+	}
+	onReport := w.onReport
+	w.mu.Unlock()
+
+	if onReport != nil {
+		onReport(report)
+	}
+	return report, err
+}
+
+// autoQueue queues album when it's free or NYP and not already downloaded,
+// returning the job ID or "" when nothing was queued
+func (w *WatchList) autoQueue(artist WatchedArtist, album models.Album) string {
+	if !artist.AutoDownload || w.queue == nil || album.Downloaded {
+		return ""
+	}
+	if album.Status != "free" && album.Status != "nyp" {
+		return ""
+	}
+
+	var opts *PostProcessOptions
+	if artist.PostProcess != nil {
+		o := *artist.PostProcess
+		opts = &o
+	}
+	job, err := w.queue.EnqueueWithOptions(album.URL, artist.Dir, artist.Format, DownloadOptions{PostProcess: opts})
+	if err != nil {
+		log.Printf("Watch: Failed to queue %s: %v", album.URL, err)
+		return ""
+	}
+	return job.ID
+}
+
+func (w *WatchList) indexLocked(id string) int {
+	for i, e := range w.entries {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *WatchList) saveLocked() error {
+	if w.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(w.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(w.path, data)
+}
+
+// nextScan returns when the artist is due, which is right away if it has
+// never been scanned, or the zero time if its schedule never fires
+func (e *watchEntry) nextScan() time.Time {
+	schedule, err := ParseSchedule(e.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	if e.LastScan.IsZero() {
+		return e.AddedAt
+	}
+	return schedule.Next(e.LastScan)
+}
+
+func (e *watchEntry) view() WatchedArtist {
+	artist := e.WatchedArtist
+	artist.NextScan = e.nextScan()
+	return artist
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"bcdl-app/backend/models"
+)
+
+func TestWatchListRetriesFailedProbes(t *testing.T) {
+	old := models.Album{URL: "https://artist.bandcamp.com/album/old", Status: "paid"}
+	failed := models.Album{URL: "https://artist.bandcamp.com/album/new", Status: "paid", ProbeError: "timeout"}
+	probed := models.Album{URL: "https://artist.bandcamp.com/album/new", Status: "free"}
+	scans := [][]models.Album{
+		{old},
+		{old, failed},
+		{old, probed},
+	}
+	calls := 0
+	scanner := ScanFunc(func(ctx context.Context, url string, onAlbumFound func(models.Album)) ([]models.Album, error) {
+		albums := scans[calls]
+		calls++
+		return albums, nil
+	})
+
+	w := NewWatchList(scanner, nil, "")
+	artist, err := w.Add(WatchedArtist{URL: "https://artist.bandcamp.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for i, want := range []struct {
+		known int
+		new   []string
+	}{
+		{1, nil},
+		{1, nil}, // The failed probe is left for the next scan
+		{2, []string{probed.URL}},
+	} {
+		report, err := w.Check(ctx, artist.ID)
+		if err != nil {
+			t.Fatalf("scan %d: %v", i+1, err)
+		}
+		var got []string
+		for _, album := range report.NewReleases {
+			got = append(got, album.URL)
+		}
+		if len(got) != len(want.new) || (len(got) > 0 && got[0] != want.new[0]) {
+			t.Errorf("scan %d reported %v as new, want %v", i+1, got, want.new)
+		}
+		if known := w.Artists()[0].Known; known != want.known {
+			t.Errorf("scan %d: known = %d, want %d", i+1, known, want.known)
+		}
+	}
+	if got := w.Reports()[0].NewReleases[0].Status; got != "free" {
+		t.Errorf("reported status = %q, want the probed one", got)
+	}
+}
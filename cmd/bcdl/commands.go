@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/playwright"
+	"bcdl-app/backend/services"
+)
+
+// scanFlags are shared by the commands that scan an artist
+type scanFlags struct {
+	scanner     string
+	concurrency int
+}
+
+func (f *scanFlags) register(fs *flag.FlagSet) {
+	concurrency, _ := strconv.Atoi(os.Getenv("BCDL_SCAN_CONCURRENCY"))
+	fs.StringVar(&f.scanner, "scanner", envOr("BCDL_SCANNER", "http"), "scanner backend: http or browser")
+	fs.IntVar(&f.concurrency, "concurrency", concurrency, "album pages checked in parallel (0 = default)")
+}
+
+// labelFlags switch scan and sync to a label's releases grouped by artist
+type labelFlags struct {
+	label   bool
+	artists bool
+}
+
+func (f *labelFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.label, "label", false, "treat the URL as a label and group its releases by roster artist")
+	fs.BoolVar(&f.artists, "artists", false, "with -label, also scan each roster artist's own page")
+}
+
+// downloadFlags are shared by the commands that download albums
+type downloadFlags struct {
+	dir         string
+	format      string
+	parallel    int
+	timeout     time.Duration
+	force       bool
+	postProcess services.PostProcessOptions
+}
+
+func (f *downloadFlags) register(fs *flag.FlagSet) {
+	env := services.PostProcessOptionsFromEnv()
+	fs.StringVar(&f.dir, "dir", ".", "download directory")
+	fs.StringVar(&f.format, "format", "flac", "audio format, e.g. flac, mp3-320")
+	fs.IntVar(&f.parallel, "parallel", services.DefaultQueueParallelism, "downloads run in parallel")
+	timeout, _ := time.ParseDuration(os.Getenv("BCDL_JOB_TIMEOUT"))
+	fs.DurationVar(&f.timeout, "timeout", timeout, "give up on an album after this long, e.g. 30m (0 = no limit)")
+	fs.BoolVar(&f.force, "force", false, "download albums again even if the history has them")
+	fs.BoolVar(&f.postProcess.Organize, "organize", env.Organize, "extract archives into the -layout folder")
+	fs.StringVar(&f.postProcess.Layout, "layout", env.Layout, "folder template for -organize, e.g. {artist}/{year} - {album}")
+	fs.StringVar(&f.postProcess.FileTemplate, "file-template", env.FileTemplate, "track file name template for -organize, e.g. {track} - {title}")
+	fs.BoolVar(&f.postProcess.DeleteArchive, "delete-archive", env.DeleteArchive, "delete the ZIP after extracting it")
+}
+
+// validate reports bad flag values, printing them to stderr
+func (f *downloadFlags) validate() bool {
+	if err := f.postProcess.Validate(); err != nil {
+		logf("bcdl: %v", err)
+		return false
+	}
+	return true
+}
+
+// downloadResult is the JSON summary of one album download
+type downloadResult struct {
+	URL         string             `json:"url"`
+	Status      services.JobStatus `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	Diagnostics string             `json:"diagnostics,omitempty"`
+}
+
+func runScan(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl scan [flags] <artist-url>")
+		fs.PrintDefaults()
+	}
+	var sf scanFlags
+	var lf labelFlags
+	sf.register(fs)
+	lf.register(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(sf.scanner == "browser")
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	if lf.label {
+		label, err := scanLabel(ctx, pwService, sf, services.NewDownloadHistoryFromEnv(), fs.Arg(0), lf.artists)
+		if err != nil {
+			logf("bcdl: scan failed: %v", err)
+			if len(label.Artists) > 0 {
+				writeJSON(label)
+			}
+			return exitFailure
+		}
+		if err := writeJSON(label); err != nil {
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	albums, err := scan(ctx, pwService, sf, services.NewDownloadHistoryFromEnv(), fs.Arg(0))
+	if err != nil {
+		logf("bcdl: scan failed: %v", err)
+		if len(albums) > 0 {
+			writeJSON(albums)
+		}
+		return exitFailure
+	}
+
+	if err := writeJSON(albums); err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runFan(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("fan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl fan [flags] <username-or-url>")
+		fs.PrintDefaults()
+	}
+	var opts services.FanScanOptions
+	var df downloadFlags
+	fs.BoolVar(&opts.Collection, "collection", true, "list the fan's collection")
+	fs.BoolVar(&opts.Wishlist, "wishlist", false, "list the fan's wishlist")
+	downloadOwned := fs.Bool("download", false, "download every album in the collection (needs the fan's session)")
+	df.register(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+	if !df.validate() {
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(*downloadOwned)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	logf("Scanning fan: %s", fs.Arg(0))
+	fans := services.NewFanScannerFromEnv()
+	loadSession(nil, fans)
+	history := services.NewDownloadHistoryFromEnv()
+	scanner := services.WithHistory(fans.Scanner(opts), history)
+	albums, err := scanner.ScanArtist(ctx, fs.Arg(0), func(album models.Album) {
+		logf("Found album: %s - %s", album.Artist, album.Title)
+	})
+	if err != nil {
+		logf("bcdl: scan failed: %v", err)
+		if len(albums) > 0 {
+			writeJSON(albums)
+		}
+		return exitFailure
+	}
+	logf("Found %d albums", len(albums))
+
+	if !*downloadOwned {
+		if err := writeJSON(albums); err != nil {
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	var urls []string
+	downloadable := 0
+	for _, album := range albums {
+		if album.Owned {
+			urls = append(urls, album.URL)
+		}
+		if album.DownloadURL != "" {
+			downloadable++
+		}
+	}
+	if downloadable == 0 && len(urls) > 0 {
+		logf("Warning: not logged in as %s, only free releases can be downloaded (see bcdl session)", fs.Arg(0))
+	}
+	logf("Downloading %d owned releases", len(urls))
+
+	results := download(ctx, pwService, df, history, urls)
+	writeJSON(struct {
+		Albums    []models.Album   `json:"albums"`
+		Downloads []downloadResult `json:"downloads"`
+	}{albums, results})
+	return resultsExitCode(results)
+}
+
+func runDownload(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl download [flags] <album-url>...")
+		fs.PrintDefaults()
+	}
+	var df downloadFlags
+	df.register(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+	if !df.validate() {
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	results := download(ctx, pwService, df, services.NewDownloadHistoryFromEnv(), fs.Args())
+	writeJSON(results)
+	return resultsExitCode(results)
+}
+
+func runSync(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl sync [flags] <artist-url>")
+		fs.PrintDefaults()
+	}
+	var sf scanFlags
+	var lf labelFlags
+	var df downloadFlags
+	sf.register(fs)
+	lf.register(fs)
+	df.register(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+	if !df.validate() {
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	history := services.NewDownloadHistoryFromEnv()
+	var albums []models.Album
+	if lf.label {
+		var label models.Label
+		label, err = scanLabel(ctx, pwService, sf, history, fs.Arg(0), lf.artists)
+		for _, artist := range label.Artists {
+			albums = append(albums, artist.Albums...)
+		}
+	} else {
+		albums, err = scan(ctx, pwService, sf, history, fs.Arg(0))
+	}
+	if err != nil {
+		logf("bcdl: scan failed: %v", err)
+		return exitFailure
+	}
+
+	var urls []string
+	for _, album := range albums {
+		if album.Status == "free" || album.Status == "nyp" {
+			urls = append(urls, album.URL)
+		}
+	}
+	logf("Downloading %d of %d releases", len(urls), len(albums))
+
+	results := download(ctx, pwService, df, history, urls)
+	writeJSON(struct {
+		Albums    []models.Album   `json:"albums"`
+		Downloads []downloadResult `json:"downloads"`
+	}{albums, results})
+	return resultsExitCode(results)
+}
+
+// startBrowser creates the Playwright service logged in with the saved
+// session, launching the browser only when the command needs it
+func startBrowser(needed bool) (*playwright.Service, error) {
+	pwService := playwright.NewService()
+	pwService.SetOutput(os.Stderr)
+	pwService.SetDiagnostics(services.DiagnosticsOptionsFromEnv())
+	loadSession(pwService, nil)
+	if !needed {
+		return pwService, nil
+	}
+
+	if err := pwService.Init(); err != nil {
+		return nil, fmt.Errorf("failed to init Playwright: %v", err)
+	}
+	return pwService, nil
+}
+
+func scan(ctx context.Context, pwService *playwright.Service, sf scanFlags, history *services.DownloadHistory, url string) ([]models.Album, error) {
+	logf("Scanning artist: %s", url)
+	scanner := services.WithHistory(services.NewScanner(sf.scanner, sf.concurrency, pwService), history)
+	albums, err := scanner.ScanArtist(ctx, url, func(album models.Album) {
+		if album.Downloaded {
+			logf("Found album: %s (%s, downloaded)", album.Title, album.Status)
+		} else {
+			logf("Found album: %s (%s)", album.Title, album.Status)
+		}
+	})
+	if err == nil {
+		logf("Found %d albums", len(albums))
+	}
+	return albums, err
+}
+
+func scanLabel(ctx context.Context, pwService *playwright.Service, sf scanFlags, history *services.DownloadHistory, url string, includeArtists bool) (models.Label, error) {
+	logf("Scanning label: %s", url)
+	scanner := services.WithHistory(services.NewScanner(sf.scanner, sf.concurrency, pwService), history)
+	label, err := services.ScanLabel(ctx, scanner, url, includeArtists, func(album models.Album) {
+		logf("Found album: %s - %s (%s)", album.Artist, album.Title, album.Status)
+	})
+	if err == nil {
+		logf("Found releases by %d artists", len(label.Artists))
+	}
+	return label, err
+}
+
+// download runs the albums through an in-memory queue and waits until every
+// job has finished or ctx is cancelled
+func download(ctx context.Context, pwService *playwright.Service, df downloadFlags, history *services.DownloadHistory, urls []string) []downloadResult {
+	downloader := newDownloader(pwService, history)
+	downloader.SetPostProcess(df.postProcess)
+	queue := services.NewDownloadQueue(downloader, "")
+	queue.SetParallelism(df.parallel)
+	queue.SetJobTimeout(df.timeout)
+
+	finished := make(chan services.DownloadJob, len(urls))
+	logJobs(queue, finished)
+	queue.Start(ctx)
+
+	pending := 0
+	opts := services.DownloadOptions{IgnoreHistory: df.force}
+	for _, url := range urls {
+		if _, err := queue.EnqueueWithOptions(url, df.dir, df.format, opts); err != nil {
+			logf("bcdl: %v", err)
+			continue
+		}
+		pending++
+	}
+
+	waitForJobs(ctx, finished, pending)
+	// Let cancelled downloads remove their partial files
+	queue.Wait()
+	return jobResults(queue)
+}
+
+// newDownloader creates a downloader recording to history, which fetches the
+// logged-in fan's purchases from their collection
+func newDownloader(pwService *playwright.Service, history *services.DownloadHistory) *services.DownloaderService {
+	downloader := services.NewDownloaderService(pwService)
+	downloader.SetHistory(history)
+	fans := services.NewFanScannerFromEnv()
+	loadSession(nil, fans)
+	downloader.SetPurchases(fans)
+	return downloader
+}
+
+// logJobs logs the queue's progress to stderr and sends each finished job to
+// finished, which must have room for every job
+func logJobs(queue *services.DownloadQueue, finished chan<- services.DownloadJob) {
+	queue.OnUpdate(func(job services.DownloadJob) {
+		switch job.Status {
+		case services.JobRunning:
+			logf("Starting download: %s", job.URL)
+		case services.JobDone:
+			logf("Download complete: %s", job.URL)
+		case services.JobFailed:
+			logf("Download failed: %s: %s", job.URL, job.Error)
+		case services.JobSkipped:
+			logf("Skipped %s: %s", job.URL, job.Message)
+		case services.JobCancelled:
+			logf("Download cancelled: %s", job.URL)
+		}
+		if job.Status.Finished() && finished != nil {
+			finished <- job
+		}
+	})
+	queue.OnProgress(func(job services.DownloadJob, p services.Progress) {
+		logf("[%s] %s: %s", job.URL, p.Phase, p.Message)
+	})
+}
+
+// waitForJobs waits until n jobs have finished or ctx is cancelled
+func waitForJobs(ctx context.Context, finished <-chan services.DownloadJob, n int) {
+	for ; n > 0; n-- {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// jobResults summarizes the queue's jobs, reporting unfinished ones as cancelled
+func jobResults(queue *services.DownloadQueue) []downloadResult {
+	results := []downloadResult{}
+	for _, job := range queue.Jobs() {
+		status := job.Status
+		if !status.Finished() {
+			status = services.JobCancelled
+		}
+		results = append(results, downloadResult{URL: job.URL, Status: status, Error: job.Error, Diagnostics: job.Diagnostics})
+	}
+	return results
+}
+
+func resultsExitCode(results []downloadResult) int {
+	for _, result := range results {
+		if result.Status != services.JobDone && result.Status != services.JobSkipped {
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+func envOr(key string, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
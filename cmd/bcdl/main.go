@@ -0,0 +1,95 @@
+// Command bcdl is the headless command-line interface to the Bandcamp
+// downloader. Progress goes to stderr and results are written to stdout as JSON.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes
+const (
+	exitOK        = 0
+	exitFailure   = 1 // The command ran but something failed
+	exitUsage     = 2 // Bad arguments
+	exitCancelled = 130
+)
+
+const usage = `Usage: bcdl <command> [flags] [args]
+
+Commands:
+  scan <artist-url>           List an artist's releases
+  fan <username>              List a fan's collection and wishlist
+  download <album-url>...     Download one or more albums
+  sync <artist-url>           Scan an artist and download every free/NYP release
+  watch <command>             Watch artists for new releases (add, list, remove, check, run)
+  session <command>           Manage the Bandcamp login (import, login, check)
+  serve                       Run the HTTP/JSON API with Server-Sent Events
+  record <scan|download> <url> Record a flow's pages as test fixtures (for developers)
+  validate-profile [dir...]   Check the site profile against recorded fixtures
+
+Run "bcdl <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var code int
+	switch args[0] {
+	case "scan":
+		code = runScan(ctx, args[1:])
+	case "fan":
+		code = runFan(ctx, args[1:])
+	case "download":
+		code = runDownload(ctx, args[1:])
+	case "sync":
+		code = runSync(ctx, args[1:])
+	case "watch":
+		code = runWatch(ctx, args[1:])
+	case "session":
+		code = runSession(ctx, args[1:])
+	case "serve":
+		code = runServe(ctx, args[1:])
+	case "record":
+		code = runRecord(ctx, args[1:])
+	case "validate-profile":
+		code = runValidateProfile(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "bcdl: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	if ctx.Err() != nil && code != exitUsage {
+		return exitCancelled
+	}
+	return code
+}
+
+// writeJSON prints v to stdout
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// logf prints a progress line to stderr
+func logf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
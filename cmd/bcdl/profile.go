@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"bcdl-app/backend/services"
+)
+
+// defaultFixtureDir holds the fixtures of the offline tests, relative to the repository root
+const defaultFixtureDir = "backend/services/testdata/site"
+
+const validateProfileUsage = `Usage: bcdl validate-profile [flags] [fixture-dir...]
+
+Checks a site profile: its format first, then, in the browser, that every
+required selector finds its element on the recorded pages of the fixture
+directories. Without a -profile flag the profile in use is checked. The
+fixture directories default to ` + defaultFixtureDir + `.
+
+Flags:
+`
+
+func runValidateProfile(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("validate-profile", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), validateProfileUsage)
+		fs.PrintDefaults()
+	}
+	path := fs.String("profile", "", "site profile file to check")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{defaultFixtureDir}
+	}
+
+	if *path == "" {
+		*path = services.NewSiteProfileStoreFromEnv().Path()
+		if _, err := os.Stat(*path); err != nil {
+			*path = ""
+		}
+	}
+	profile := services.DefaultSiteProfile()
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil {
+			logf("bcdl: %v", err)
+			return exitFailure
+		}
+		if profile, err = services.LoadSiteProfile(data); err != nil {
+			logf("bcdl: %s is invalid: %v", *path, err)
+			return exitFailure
+		}
+		logf("Checking %s (revision %s)", *path, profile.Revision)
+	} else {
+		logf("Checking the built-in profile (revision %s)", profile.Revision)
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	checks, err := services.CheckSiteProfile(ctx, pwService, profile, dirs)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	if err := writeJSON(checks); err != nil {
+		return exitFailure
+	}
+
+	code := exitOK
+	for _, check := range checks {
+		if check.Missing() {
+			logf("bcdl: %s matched no fixture page", check.Element)
+			code = exitFailure
+		}
+	}
+	return code
+}
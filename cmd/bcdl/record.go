@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"bcdl-app/backend/fixtures"
+	"bcdl-app/backend/models"
+	"bcdl-app/backend/playwright"
+	"bcdl-app/backend/services"
+)
+
+const recordUsage = `Usage: bcdl record [flags] <scan|download> <url>
+
+Runs a scan or download through the browser and records the pages, XHR/JSON
+responses and DOM snapshots it sees into a fixture directory for the offline
+tests. Email addresses and tokens are scrubbed from what is saved.
+
+Flags:
+`
+
+func runRecord(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), recordUsage)
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "", "fixture directory to write (required)")
+	format := fs.String("format", "flac", "audio format to download")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 || *out == "" || (fs.Arg(0) != "scan" && fs.Arg(0) != "download") {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+	kind, url := fs.Arg(0), fs.Arg(1)
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	recorder := fixtures.NewRecorder()
+	pwService.OnNewPage(recorder.Attach)
+
+	switch kind {
+	case "scan":
+		logf("Recording scan of %s", url)
+		scanner := services.NewScannerService(pwService)
+		_, err = scanner.ScanArtist(ctx, url, func(album models.Album) {
+			logf("Found album: %s (%s)", album.Title, album.Status)
+		})
+	case "download":
+		logf("Recording download of %s", url)
+		err = recordDownload(ctx, pwService, recorder, url, *format)
+	}
+	// What was recorded up to a failure is worth keeping, it shows where the flow broke
+	if err != nil {
+		logf("bcdl: %s failed: %v", kind, err)
+	}
+
+	manifest, saveErr := recorder.Save(*out)
+	if saveErr != nil {
+		logf("bcdl: %v", saveErr)
+		return exitFailure
+	}
+	logf("Recorded %d responses and %d DOM snapshots to %s", len(manifest.Routes), len(manifest.Snapshots), *out)
+	if err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// recordDownload downloads url into a scratch directory, snapshotting the
+// page at every step the downloader reports
+func recordDownload(ctx context.Context, pwService *playwright.Service, recorder *fixtures.Recorder, url string, format string) error {
+	dir, err := os.MkdirTemp("", "bcdl-record-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	downloader := services.NewDownloaderService(pwService)
+	downloader.SetPostProcess(services.PostProcessOptions{})
+	return downloader.DownloadAlbum(ctx, url, dir, format, func(p services.Progress) {
+		if p.Message == "" {
+			return // Transfer progress
+		}
+		logf("%s", p.Message)
+		recorder.SnapshotAll(p.Message)
+	})
+}
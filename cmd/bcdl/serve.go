@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"bcdl-app/backend/events"
+	"bcdl-app/backend/server"
+	"bcdl-app/backend/services"
+)
+
+func runServe(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl serve [flags]")
+		fs.PrintDefaults()
+	}
+	var sf scanFlags
+	sf.register(fs)
+	addr := fs.String("addr", envOr("BCDL_SERVER_ADDR", "127.0.0.1:8080"), "address to listen on")
+	token := fs.String("token", envOr("BCDL_SERVER_TOKEN", ""), "bearer token required from clients (empty = no auth)")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	history := services.NewDownloadHistoryFromEnv()
+	downloader := services.NewDownloaderService(pwService)
+	downloader.SetHistory(history)
+	fans := services.NewFanScannerFromEnv()
+	downloader.SetPurchases(fans)
+	queue := services.NewDownloadQueueFromEnv(downloader)
+	if err := queue.Load(); err != nil {
+		logf("bcdl: failed to restore download queue: %v", err)
+	}
+
+	bus := events.NewBus()
+	bus.Subscribe(events.Logger{})
+	events.ForwardQueue(bus, queue)
+
+	scanner := services.WithHistory(services.NewScanner(sf.scanner, sf.concurrency, pwService), history)
+	watchList := services.NewWatchListFromEnv(scanner, queue)
+	events.ForwardWatchList(bus, watchList)
+	session := services.NewSessionFromEnv(pwService, fans)
+	if err := session.Load(); err != nil {
+		logf("bcdl: failed to load session: %v", err)
+	}
+	srv := server.New(scanner, fans, queue, history, watchList, session, bus, *token)
+	queue.Start(ctx)
+	go watchList.Run(ctx)
+
+	if *token == "" {
+		logf("Warning: no token set, the API is open to anyone who can reach %s", *addr)
+	}
+	if err := srv.ListenAndServe(ctx, *addr); err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	// Let cancelled downloads remove their partial files
+	queue.Wait()
+	return exitOK
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"bcdl-app/backend/playwright"
+	"bcdl-app/backend/services"
+)
+
+const sessionUsage = `Usage: bcdl session <command> [args]
+
+Commands:
+  import <file>        Log in with a cookies.txt or Playwright storage-state file
+  login                Log in to Bandcamp in a browser window and save the session
+  check                Check whether the saved session is still logged in
+`
+
+func runSession(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, sessionUsage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "import":
+		return runSessionImport(ctx, args[1:])
+	case "login":
+		return runSessionLogin(ctx, args[1:])
+	case "check":
+		return runSessionCheck(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, sessionUsage)
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "bcdl: unknown session command %q\n\n%s", args[0], sessionUsage)
+	return exitUsage
+}
+
+func runSessionImport(ctx context.Context, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bcdl session import <file>")
+		return exitUsage
+	}
+
+	session := services.NewSessionFromEnv(nil, nil)
+	if err := session.Import(args[0]); err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	return printSessionStatus(ctx, session)
+}
+
+func runSessionLogin(ctx context.Context, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: bcdl session login")
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	logf("Log in to Bandcamp in the browser window that opens")
+	session := services.NewSessionFromEnv(pwService, nil)
+	if err := session.Login(ctx); err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	return printSessionStatus(ctx, session)
+}
+
+func runSessionCheck(ctx context.Context, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: bcdl session check")
+		return exitUsage
+	}
+
+	session := services.NewSessionFromEnv(nil, nil)
+	if err := session.Load(); err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	return printSessionStatus(ctx, session)
+}
+
+// printSessionStatus checks the session and prints its status. Logged-out
+// sessions exit with a failure so scripts can tell
+func printSessionStatus(ctx context.Context, session *services.Session) int {
+	status, err := session.Check(ctx)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	writeJSON(status)
+	if !status.LoggedIn {
+		logf("Not logged in")
+		return exitFailure
+	}
+	logf("Logged in as %s", status.Username)
+	return exitOK
+}
+
+// loadSession logs pwService and fans, either of which may be nil, in with
+// the saved Bandcamp session
+func loadSession(pwService *playwright.Service, fans *services.FanScanner) {
+	if err := services.NewSessionFromEnv(pwService, fans).Load(); err != nil {
+		logf("bcdl: failed to load session: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"bcdl-app/backend/events"
+	"bcdl-app/backend/playwright"
+	"bcdl-app/backend/services"
+)
+
+const watchUsage = `Usage: bcdl watch <command> [flags] [args]
+
+Commands:
+  add <artist-url>     Watch an artist for new releases
+  list                 List watched artists
+  remove <id>          Stop watching an artist
+  check [id...]        Re-scan watched artists now and download new free/NYP releases
+  run                  Re-scan watched artists on their schedules until interrupted
+`
+
+func runWatch(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, watchUsage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "add":
+		return runWatchAdd(args[1:])
+	case "list":
+		return runWatchList(args[1:])
+	case "remove":
+		return runWatchRemove(args[1:])
+	case "check":
+		return runWatchCheck(ctx, args[1:])
+	case "run":
+		return runWatchRun(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, watchUsage)
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "bcdl: unknown watch command %q\n\n%s", args[0], watchUsage)
+	return exitUsage
+}
+
+func runWatchAdd(args []string) int {
+	fs := flag.NewFlagSet("watch add", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl watch add [flags] <artist-url>")
+		fs.PrintDefaults()
+	}
+	var df downloadFlags
+	df.register(fs)
+	schedule := fs.String("schedule", services.DefaultWatchSchedule, `cron schedule, e.g. "0 8 * * *" or "@every 12h"`)
+	noDownload := fs.Bool("no-download", false, "only report new releases, don't queue them")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+	if !df.validate() {
+		return exitUsage
+	}
+
+	artist := services.WatchedArtist{
+		URL:          fs.Arg(0),
+		Dir:          df.dir,
+		Format:       df.format,
+		Schedule:     *schedule,
+		AutoDownload: !*noDownload,
+	}
+	// Keep the artist's own post-processing only when asked for, so it
+	// otherwise follows the defaults
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "organize", "layout", "file-template", "delete-archive":
+			artist.PostProcess = &df.postProcess
+		}
+	})
+
+	watchList := services.NewWatchListFromEnv(nil, nil)
+	added, err := watchList.Add(artist)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitUsage
+	}
+	writeJSON(added)
+	return exitOK
+}
+
+func runWatchList(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: bcdl watch list")
+		return exitUsage
+	}
+	writeJSON(services.NewWatchListFromEnv(nil, nil).Artists())
+	return exitOK
+}
+
+func runWatchRemove(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bcdl watch remove <id>")
+		return exitUsage
+	}
+	if err := services.NewWatchListFromEnv(nil, nil).Remove(args[0]); err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runWatchCheck re-scans the given artists, or all of them, then downloads
+// the new releases and prints the reports
+func runWatchCheck(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("watch check", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl watch check [flags] [id...]")
+		fs.PrintDefaults()
+	}
+	var sf scanFlags
+	sf.register(fs)
+	parallel := fs.Int("parallel", services.DefaultQueueParallelism, "downloads run in parallel")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	queue, watchList := watchServices(pwService, sf, false)
+	queue.SetParallelism(*parallel)
+
+	ids := fs.Args()
+	if len(ids) == 0 {
+		for _, artist := range watchList.Artists() {
+			ids = append(ids, artist.ID)
+		}
+	}
+
+	// Jobs are only queued while scanning; downloads start once every
+	// artist has been checked
+	reports := []services.WatchReport{}
+	code := exitOK
+	queued := 0
+	for _, id := range ids {
+		report, err := watchList.Check(ctx, id)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			logf("bcdl: %v", err)
+			code = exitFailure
+			if report.ArtistID == "" {
+				continue
+			}
+		}
+		reports = append(reports, report)
+		queued += len(report.Queued)
+	}
+
+	finished := make(chan services.DownloadJob, queued)
+	logJobs(queue, finished)
+	queue.Start(ctx)
+	waitForJobs(ctx, finished, queued)
+	queue.Wait()
+
+	results := jobResults(queue)
+	writeJSON(struct {
+		Reports   []services.WatchReport `json:"reports"`
+		Downloads []downloadResult       `json:"downloads"`
+	}{reports, results})
+	if code != exitOK {
+		return code
+	}
+	return resultsExitCode(results)
+}
+
+// runWatchRun re-scans watched artists as they come due, printing each report
+// as JSON
+func runWatchRun(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("watch run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bcdl watch run [flags]")
+		fs.PrintDefaults()
+	}
+	var sf scanFlags
+	sf.register(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		if err == nil {
+			fs.Usage()
+		}
+		return exitUsage
+	}
+
+	pwService, err := startBrowser(true)
+	if err != nil {
+		logf("bcdl: %v", err)
+		return exitFailure
+	}
+	defer pwService.Close()
+
+	queue, watchList := watchServices(pwService, sf, true)
+	if err := queue.Load(); err != nil {
+		logf("bcdl: failed to restore download queue: %v", err)
+	}
+
+	bus := events.NewBus()
+	bus.Subscribe(events.Logger{})
+	bus.Subscribe(events.SubscriberFunc(func(e events.Event) {
+		if e, ok := e.(events.WatchScanned); ok {
+			writeJSON(e.Report)
+		}
+	}))
+	events.ForwardQueue(bus, queue)
+	events.ForwardWatchList(bus, watchList)
+
+	queue.Start(ctx)
+	watchList.Run(ctx)
+	queue.Wait()
+	return exitOK
+}
+
+// watchServices creates the watch list with a scanner and a download queue
+// sharing the download history. The queue is persisted in the data dir when
+// persistQueue is set, otherwise it's kept in memory
+func watchServices(pwService *playwright.Service, sf scanFlags, persistQueue bool) (*services.DownloadQueue, *services.WatchList) {
+	history := services.NewDownloadHistoryFromEnv()
+	downloader := newDownloader(pwService, history)
+
+	queue := services.NewDownloadQueue(downloader, "")
+	if persistQueue {
+		queue = services.NewDownloadQueueFromEnv(downloader)
+	}
+
+	scanner := services.WithHistory(services.NewScanner(sf.scanner, sf.concurrency, pwService), history)
+	return queue, services.NewWatchListFromEnv(scanner, queue)
+}
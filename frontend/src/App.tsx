@@ -84,6 +84,10 @@ function App() {
                 addLog(`Download failed: ${data.error}`, 'error');
             });
 
+            EventsOn("download:cancelled", (data: any) => {
+                addLog(`Download cancelled: ${data.url}`, 'warning');
+            });
+
             EventsOn("log:error", (msg: string) => addLog(msg, 'error'));
         } catch (e) {
             console.warn("Wails runtime not available. Events disabled.");
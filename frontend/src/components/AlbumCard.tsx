@@ -11,7 +11,8 @@ interface AlbumCardProps {
 }
 
 export const AlbumCard: React.FC<AlbumCardProps> = ({ album, isSelected, onToggle }) => {
-    const isPaid = album.status === 'paid';
+    // Owned albums can be downloaded through the fan's purchase page
+    const isPaid = album.status === 'paid' && !album.owned;
 
     return (
         <motion.div
@@ -44,7 +45,9 @@ export const AlbumCard: React.FC<AlbumCardProps> = ({ album, isSelected, onToggl
                         {album.title}
                     </div>
                 </div>
-                <p className="text-slate-400 text-xs truncate">{album.url}</p>
+                <p className="text-slate-400 text-xs truncate">
+                    {[album.artist, album.label, album.releaseDate?.slice(0, 4)].filter(Boolean).join(' · ') || album.url}
+                </p>
 
                 {/* Status Badge */}
                 <div className="mt-2 flex items-center space-x-2">
@@ -54,8 +57,28 @@ export const AlbumCard: React.FC<AlbumCardProps> = ({ album, isSelected, onToggl
                             ? "bg-slate-700 text-slate-400"
                             : "bg-emerald-500/20 text-emerald-400"
                     )}>
-                        {isPaid ? "Paid" : "Free / NYP"}
+                        {isPaid ? (album.price || "Paid") : album.owned ? "Purchased" : "Free / NYP"}
                     </span>
+                    {album.tracks && album.tracks.length > 0 && (
+                        <span className="text-[10px] text-slate-500">
+                            {album.tracks.length} {album.tracks.length === 1 ? "track" : "tracks"}
+                        </span>
+                    )}
+                    {album.owned && (
+                        <span className="text-[10px] px-2 py-0.5 rounded-full font-medium uppercase tracking-wider bg-sky-500/20 text-sky-400">
+                            Owned
+                        </span>
+                    )}
+                    {album.wishlisted && !album.owned && (
+                        <span className="text-[10px] px-2 py-0.5 rounded-full font-medium uppercase tracking-wider bg-amber-500/20 text-amber-400">
+                            Wishlist
+                        </span>
+                    )}
+                    {album.downloaded && (
+                        <span className="text-[10px] px-2 py-0.5 rounded-full font-medium uppercase tracking-wider bg-primary/20 text-primary">
+                            Downloaded
+                        </span>
+                    )}
                 </div>
             </div>
 
@@ -1,12 +1,35 @@
+export interface Track {
+    id?: number;
+    number: number;
+    title: string;
+    duration: number; // Seconds
+}
+
 export interface Album {
+    id?: number;
+    type?: string; // "album" or "track"
     title: string;
     artist: string;
     coverUrl: string;
     url: string;
+    label?: string;
+    catalog?: string;
+    releaseDate?: string; // YYYY-MM-DD
+    tags?: string[];
+    tracks?: Track[];
+    about?: string;
+    credits?: string;
+    minimumPrice?: number;
+    currency?: string;
     isFree: boolean;
     isNyp: boolean;
     price: string;
     status: string; // "free", "nyp", "paid"
+    downloaded: boolean; // Already in the download history
+    owned?: boolean; // In the fan's collection
+    wishlisted?: boolean; // On the fan's wishlist
+    downloadUrl?: string; // The fan's download page for an owned item
+    probeError?: string; // Why the album page couldn't be checked, status is then a guess
 }
 
 export interface LogMessage {
@@ -1,11 +1,64 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {models} from '../models';
+import {services} from '../models';
+
+export function CancelDirectDownload(arg1:string):Promise<void>;
+
+export function CancelDownload(arg1:string):Promise<void>;
+
+export function CheckSession():Promise<services.SessionStatus>;
+
+export function CheckWatchedArtist(arg1:string):Promise<services.WatchReport>;
+
+export function ClearFinishedDownloads():Promise<number>;
 
 export function DownloadAlbum(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function EnqueueDownload(arg1:string,arg2:string,arg3:string):Promise<services.DownloadJob>;
+
+export function EnqueueDownloadWithOptions(arg1:string,arg2:string,arg3:string,arg4:services.PostProcessOptions):Promise<services.DownloadJob>;
+
+export function ForgetDownload(arg1:string):Promise<void>;
+
+export function GetPostProcessing():Promise<services.PostProcessOptions>;
+
+export function ImportSession(arg1:string):Promise<services.SessionStatus>;
+
+export function ListDownloads():Promise<Array<services.DownloadJob>>;
+
+export function ListHistory():Promise<Array<services.HistoryEntry>>;
+
+export function ListWatchedArtists():Promise<Array<services.WatchedArtist>>;
+
+export function Login():Promise<services.SessionStatus>;
+
+export function PauseDownload(arg1:string):Promise<void>;
+
+export function RemoveDownload(arg1:string):Promise<void>;
+
+export function ReorderDownload(arg1:string,arg2:number):Promise<void>;
+
+export function ResumeDownload(arg1:string):Promise<void>;
+
 export function ScanArtist(arg1:string):Promise<Array<models.Album>>;
 
+export function ScanFan(arg1:string,arg2:boolean):Promise<void>;
+
+export function ScanLabel(arg1:string,arg2:boolean):Promise<void>;
+
 export function SelectFolder():Promise<string>;
 
+export function SelectSessionFile():Promise<string>;
+
+export function SetDownloadParallelism(arg1:number):Promise<void>;
+
+export function SetPostProcessing(arg1:services.PostProcessOptions):Promise<void>;
+
 export function StopScan():Promise<void>;
+
+export function UnwatchArtist(arg1:string):Promise<void>;
+
+export function WatchArtist(arg1:string,arg2:string,arg3:string,arg4:string,arg5:boolean):Promise<services.WatchedArtist>;
+
+export function WatchReports():Promise<Array<services.WatchReport>>;
@@ -2,18 +2,122 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function CancelDirectDownload(arg1) {
+  return window['go']['main']['App']['CancelDirectDownload'](arg1);
+}
+
+export function CancelDownload(arg1) {
+  return window['go']['main']['App']['CancelDownload'](arg1);
+}
+
+export function CheckSession() {
+  return window['go']['main']['App']['CheckSession']();
+}
+
+export function CheckWatchedArtist(arg1) {
+  return window['go']['main']['App']['CheckWatchedArtist'](arg1);
+}
+
+export function ClearFinishedDownloads() {
+  return window['go']['main']['App']['ClearFinishedDownloads']();
+}
+
 export function DownloadAlbum(arg1, arg2, arg3) {
   return window['go']['main']['App']['DownloadAlbum'](arg1, arg2, arg3);
 }
 
+export function EnqueueDownload(arg1, arg2, arg3) {
+  return window['go']['main']['App']['EnqueueDownload'](arg1, arg2, arg3);
+}
+
+export function EnqueueDownloadWithOptions(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['EnqueueDownloadWithOptions'](arg1, arg2, arg3, arg4);
+}
+
+export function ForgetDownload(arg1) {
+  return window['go']['main']['App']['ForgetDownload'](arg1);
+}
+
+export function GetPostProcessing() {
+  return window['go']['main']['App']['GetPostProcessing']();
+}
+
+export function ImportSession(arg1) {
+  return window['go']['main']['App']['ImportSession'](arg1);
+}
+
+export function ListDownloads() {
+  return window['go']['main']['App']['ListDownloads']();
+}
+
+export function ListHistory() {
+  return window['go']['main']['App']['ListHistory']();
+}
+
+export function ListWatchedArtists() {
+  return window['go']['main']['App']['ListWatchedArtists']();
+}
+
+export function Login() {
+  return window['go']['main']['App']['Login']();
+}
+
+export function PauseDownload(arg1) {
+  return window['go']['main']['App']['PauseDownload'](arg1);
+}
+
+export function RemoveDownload(arg1) {
+  return window['go']['main']['App']['RemoveDownload'](arg1);
+}
+
+export function ReorderDownload(arg1, arg2) {
+  return window['go']['main']['App']['ReorderDownload'](arg1, arg2);
+}
+
+export function ResumeDownload(arg1) {
+  return window['go']['main']['App']['ResumeDownload'](arg1);
+}
+
 export function ScanArtist(arg1) {
   return window['go']['main']['App']['ScanArtist'](arg1);
 }
 
+export function ScanFan(arg1, arg2) {
+  return window['go']['main']['App']['ScanFan'](arg1, arg2);
+}
+
+export function ScanLabel(arg1, arg2) {
+  return window['go']['main']['App']['ScanLabel'](arg1, arg2);
+}
+
 export function SelectFolder() {
   return window['go']['main']['App']['SelectFolder']();
 }
 
+export function SelectSessionFile() {
+  return window['go']['main']['App']['SelectSessionFile']();
+}
+
+export function SetDownloadParallelism(arg1) {
+  return window['go']['main']['App']['SetDownloadParallelism'](arg1);
+}
+
+export function SetPostProcessing(arg1) {
+  return window['go']['main']['App']['SetPostProcessing'](arg1);
+}
+
 export function StopScan() {
   return window['go']['main']['App']['StopScan']();
 }
+
+export function UnwatchArtist(arg1) {
+  return window['go']['main']['App']['UnwatchArtist'](arg1);
+}
+
+export function WatchArtist(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['WatchArtist'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function WatchReports() {
+  return window['go']['main']['App']['WatchReports']();
+}
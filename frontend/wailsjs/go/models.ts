@@ -1,14 +1,48 @@
 export namespace models {
 	
+	export class Track {
+	    id?: number;
+	    number: number;
+	    title: string;
+	    duration: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Track(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.number = source["number"];
+	        this.title = source["title"];
+	        this.duration = source["duration"];
+	    }
+	}
 	export class Album {
+	    id?: number;
+	    type?: string;
 	    title: string;
 	    artist: string;
 	    coverUrl: string;
 	    url: string;
+	    label?: string;
+	    catalog?: string;
+	    releaseDate?: string;
+	    tags?: string[];
+	    tracks?: Track[];
+	    about?: string;
+	    credits?: string;
+	    minimumPrice?: number;
+	    currency?: string;
 	    isFree: boolean;
 	    isNyp: boolean;
 	    price: string;
 	    status: string;
+	    downloaded: boolean;
+	    owned?: boolean;
+	    wishlisted?: boolean;
+	    downloadUrl?: string;
+	    probeError?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Album(source);
@@ -16,15 +50,407 @@ export namespace models {
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.type = source["type"];
 	        this.title = source["title"];
 	        this.artist = source["artist"];
 	        this.coverUrl = source["coverUrl"];
 	        this.url = source["url"];
+	        this.label = source["label"];
+	        this.catalog = source["catalog"];
+	        this.releaseDate = source["releaseDate"];
+	        this.tags = source["tags"];
+	        this.tracks = this.convertValues(source["tracks"], Track);
+	        this.about = source["about"];
+	        this.credits = source["credits"];
+	        this.minimumPrice = source["minimumPrice"];
+	        this.currency = source["currency"];
 	        this.isFree = source["isFree"];
 	        this.isNyp = source["isNyp"];
 	        this.price = source["price"];
 	        this.status = source["status"];
+	        this.downloaded = source["downloaded"];
+	        this.owned = source["owned"];
+	        this.wishlisted = source["wishlisted"];
+	        this.downloadUrl = source["downloadUrl"];
+	        this.probeError = source["probeError"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LabelArtist {
+	    name: string;
+	    url?: string;
+	    albums: Album[];
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LabelArtist(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.url = source["url"];
+	        this.albums = this.convertValues(source["albums"], Album);
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Label {
+	    name: string;
+	    url: string;
+	    artists: LabelArtist[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Label(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.url = source["url"];
+	        this.artists = this.convertValues(source["artists"], LabelArtist);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+
+export namespace services {
+	
+	export class HistoryEntry {
+	    url: string;
+	    albumId?: number;
+	    artist?: string;
+	    title?: string;
+	    format: string;
+	    path: string;
+	    size: number;
+	    checksum: string;
+	    // Go type: time
+	    downloadedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new HistoryEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.albumId = source["albumId"];
+	        this.artist = source["artist"];
+	        this.title = source["title"];
+	        this.format = source["format"];
+	        this.path = source["path"];
+	        this.size = source["size"];
+	        this.checksum = source["checksum"];
+	        this.downloadedAt = this.convertValues(source["downloadedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PostProcessOptions {
+	    organize: boolean;
+	    layout?: string;
+	    fileTemplate?: string;
+	    deleteArchive: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PostProcessOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.organize = source["organize"];
+	        this.layout = source["layout"];
+	        this.fileTemplate = source["fileTemplate"];
+	        this.deleteArchive = source["deleteArchive"];
+	    }
+	}
+	export class Progress {
+	    phase: string;
+	    message: string;
+	    bytesDone?: number;
+	    bytesTotal?: number;
+	    speed?: number;
+	    eta?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Progress(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.phase = source["phase"];
+	        this.message = source["message"];
+	        this.bytesDone = source["bytesDone"];
+	        this.bytesTotal = source["bytesTotal"];
+	        this.speed = source["speed"];
+	        this.eta = source["eta"];
+	    }
+	}
+	export class DownloadJob {
+	    id: string;
+	    url: string;
+	    dir: string;
+	    format: string;
+	    postProcess?: PostProcessOptions;
+	    ignoreHistory?: boolean;
+	    status: string;
+	    message?: string;
+	    progress?: Progress;
+	    error?: string;
+	    errorKind?: string;
+	    // Go type: time
+	    createdAt: any;
+	    // Go type: time
+	    updatedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new DownloadJob(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.url = source["url"];
+	        this.dir = source["dir"];
+	        this.format = source["format"];
+	        this.postProcess = this.convertValues(source["postProcess"], PostProcessOptions);
+	        this.ignoreHistory = source["ignoreHistory"];
+	        this.status = source["status"];
+	        this.message = source["message"];
+	        this.progress = this.convertValues(source["progress"], Progress);
+	        this.error = source["error"];
+	        this.errorKind = source["errorKind"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SessionStatus {
+	    loggedIn: boolean;
+	    username?: string;
+	    fanId?: number;
+	    source?: string;
+	    // Go type: time
+	    checkedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new SessionStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.loggedIn = source["loggedIn"];
+	        this.username = source["username"];
+	        this.fanId = source["fanId"];
+	        this.source = source["source"];
+	        this.checkedAt = this.convertValues(source["checkedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class WatchReport {
+	    artistId: string;
+	    artistUrl: string;
+	    // Go type: time
+	    scannedAt: any;
+	    total: number;
+	    baseline: boolean;
+	    newReleases: models.Album[];
+	    queued: string[];
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new WatchReport(source);
 	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.artistId = source["artistId"];
+	        this.artistUrl = source["artistUrl"];
+	        this.scannedAt = this.convertValues(source["scannedAt"], null);
+	        this.total = source["total"];
+	        this.baseline = source["baseline"];
+	        this.newReleases = this.convertValues(source["newReleases"], models.Album);
+	        this.queued = source["queued"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class WatchedArtist {
+	    id: string;
+	    url: string;
+	    dir: string;
+	    format: string;
+	    postProcess?: PostProcessOptions;
+	    schedule: string;
+	    autoDownload: boolean;
+	    // Go type: time
+	    addedAt: any;
+	    // Go type: time
+	    lastScan: any;
+	    // Go type: time
+	    nextScan: any;
+	    lastError?: string;
+	    known: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new WatchedArtist(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.url = source["url"];
+	        this.dir = source["dir"];
+	        this.format = source["format"];
+	        this.postProcess = this.convertValues(source["postProcess"], PostProcessOptions);
+	        this.schedule = source["schedule"];
+	        this.autoDownload = source["autoDownload"];
+	        this.addedAt = this.convertValues(source["addedAt"], null);
+	        this.lastScan = this.convertValues(source["lastScan"], null);
+	        this.nextScan = this.convertValues(source["nextScan"], null);
+	        this.lastError = source["lastError"];
+	        this.known = source["known"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+
+	"bcdl-app/backend/events"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// wailsBridge forwards bus events to the frontend under their wire names
+type wailsBridge struct {
+	ctx context.Context
+}
+
+func (b wailsBridge) Handle(e events.Event) {
+	runtime.EventsEmit(b.ctx, e.Name(), e.Payload())
+}